@@ -1,7 +0,0 @@
-CREATE TABLE positions 
-(
-    id          BIGSERIAL       PRIMARY KEY,
-    product_id  BIGSERIAL            REFERENCES products,
-    qty         INTEGER         NOT NULL,
-    price       INTEGER         NOT NULL
-)
\ No newline at end of file
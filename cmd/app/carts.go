@@ -0,0 +1,161 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/carts"
+)
+
+// customerID возвращает идентификатор аутентифицированного покупателя.
+func customerID(request *http.Request) (int64, error) {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		return 0, err
+	}
+	return principal.ID, nil
+}
+
+func (s *Server) handleGetCart(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	cart, err := s.cartsSvc.Cart(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, cart)
+}
+
+// handleReplaceCart заменяет содержимое корзины: {"items": [{"product_id": 1, "qty": 2}]}.
+func (s *Server) handleReplaceCart(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	var body struct {
+		Items []*carts.Item `json:"items"`
+	}
+	err = decodeJSON(request, &body)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	cart, err := s.cartsSvc.Replace(request.Context(), id, body.Items)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, cart)
+}
+
+func (s *Server) handleClearCart(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	err = s.cartsSvc.Clear(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// handleAddCartItem добавляет товар в корзину, увеличивая количество, если он там уже есть.
+func (s *Server) handleAddCartItem(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item := &carts.Item{}
+	err = decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	cart, err := s.cartsSvc.AddItem(request.Context(), id, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, cart)
+}
+
+// handleSetCartItem устанавливает количество товара: {"qty": 3}; 0 убирает товар.
+func (s *Server) handleSetCartItem(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	productID, err := idParam(request, "product_id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item := &carts.Item{}
+	err = decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item.ProductID = productID
+
+	cart, err := s.cartsSvc.SetItem(request.Context(), id, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, cart)
+}
+
+func (s *Server) handleRemoveCartItem(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	productID, err := idParam(request, "product_id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	cart, err := s.cartsSvc.RemoveItem(request.Context(), id, productID)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, cart)
+}
+
+// handleCheckout оформляет корзину покупкой. При изменившихся ценах
+// отвечает 409 price_changed, а корзина получает новые цены.
+func (s *Server) handleCheckout(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	purchase, err := s.cartsSvc.Checkout(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusCreated, purchase)
+}
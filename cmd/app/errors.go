@@ -0,0 +1,232 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/health"
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/passwords"
+	"github.com/Fanisabonu/http/pkg/phones"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// errBadRequest возвращается, когда тело или параметры запроса не удалось разобрать.
+var errBadRequest = errors.New("bad request")
+
+// errInternal - сообщение для ошибок, которые не раскрываются клиенту.
+var errInternal = errors.New("internal error")
+
+// APIError - тело ответа с ошибкой. Code стабилен и предназначен для
+// фронтенда, Message - для человека.
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// errorMapping описывает, как ошибка сервиса превращается в ответ.
+type errorMapping struct {
+	err     error
+	status  int
+	code    string
+	details func(err error) interface{}
+	// header дописывает заголовки ответа, например Retry-After.
+	header func(err error, header http.Header)
+}
+
+// errorRegistry сопоставляет ошибки сервисов HTTP-статусам и кодам.
+// Ошибки проверяются через errors.Is в порядке объявления.
+var errorRegistry = []errorMapping{
+	{err: errBadRequest, status: http.StatusBadRequest, code: "bad_request"},
+	{err: middleware.ErrNoAuthentication, status: http.StatusUnauthorized, code: "unauthenticated"},
+	{err: middleware.ErrForbidden, status: http.StatusForbidden, code: "forbidden"},
+	{err: auth.ErrNoSuchUser, status: http.StatusNotFound, code: "no_such_user"},
+	{err: auth.ErrInvalidPassword, status: http.StatusUnauthorized, code: "invalid_password"},
+	{err: auth.ErrInvalidToken, status: http.StatusUnauthorized, code: "invalid_token"},
+	{err: auth.ErrExpire, status: http.StatusUnauthorized, code: "token_expired"},
+	{err: auth.ErrInvalidCode, status: http.StatusBadRequest, code: "invalid_reset_code"},
+	{err: auth.ErrTooManyAttempts, status: http.StatusTooManyRequests, code: "too_many_attempts", details: retryDetails, header: retryAfter},
+	{err: auth.ErrNoLockout, status: http.StatusNotFound, code: "not_found"},
+	{err: audit.ErrInvalidFilter, status: http.StatusBadRequest, code: "invalid_filter"},
+	{err: passwords.ErrWeakPassword, status: http.StatusBadRequest, code: "weak_password", details: passwordDetails},
+	{err: phones.ErrInvalidPhone, status: http.StatusBadRequest, code: "invalid_phone"},
+	{err: managers.ErrRoles, status: http.StatusBadRequest, code: "invalid_role"},
+	{err: managers.ErrInvalidPlan, status: http.StatusBadRequest, code: "invalid_plan"},
+	{err: managers.ErrPhoneTaken, status: http.StatusConflict, code: "phone_taken"},
+	{err: managers.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: managers.ErrInvalidManager, status: http.StatusBadRequest, code: "invalid_manager"},
+	{err: managers.ErrNoSuchBoss, status: http.StatusBadRequest, code: "invalid_boss"},
+	{err: managers.ErrHierarchyCycle, status: http.StatusConflict, code: "hierarchy_cycle"},
+	{err: managers.ErrInvalidInvite, status: http.StatusUnauthorized, code: "invalid_invite"},
+	{err: customers.ErrPhoneTaken, status: http.StatusConflict, code: "phone_taken"},
+	{err: customers.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: customers.ErrInvalidCode, status: http.StatusBadRequest, code: "invalid_verification_code"},
+	{err: customers.ErrAlreadyVerified, status: http.StatusConflict, code: "phone_already_verified"},
+	{err: customers.ErrResendTooSoon, status: http.StatusTooManyRequests, code: "verification_resend_too_soon", details: retryDetails, header: retryAfter},
+	{err: sales.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: products.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: products.ErrInvalidProduct, status: http.StatusBadRequest, code: "invalid_product"},
+	{err: products.ErrInvalidFilter, status: http.StatusBadRequest, code: "invalid_filter"},
+	{err: purchases.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: sales.ErrInvalidQuantity, status: http.StatusBadRequest, code: "invalid_quantity"},
+	{err: sales.ErrInvalidPrice, status: http.StatusBadRequest, code: "invalid_price"},
+	{err: sales.ErrInvalidReport, status: http.StatusBadRequest, code: "invalid_report"},
+	{err: sales.ErrNoSuchManager, status: http.StatusNotFound, code: "not_found"},
+	{err: sales.ErrNoSuchSale, status: http.StatusNotFound, code: "not_found"},
+	{err: sales.ErrAlreadyCancelled, status: http.StatusConflict, code: "sale_cancelled"},
+	{err: sales.ErrInvalidCancellation, status: http.StatusBadRequest, code: "invalid_cancellation"},
+	{err: sales.ErrInvalidSaleFilter, status: http.StatusBadRequest, code: "invalid_filter"},
+	{err: purchases.ErrInvalidQuantity, status: http.StatusBadRequest, code: "invalid_quantity"},
+	{err: purchases.ErrPhoneNotVerified, status: http.StatusForbidden, code: "phone_not_verified"},
+	{err: carts.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: carts.ErrNoSuchItem, status: http.StatusNotFound, code: "not_in_cart"},
+	{err: carts.ErrInvalidQuantity, status: http.StatusBadRequest, code: "invalid_quantity"},
+	{err: carts.ErrEmptyCart, status: http.StatusConflict, code: "cart_empty"},
+	{err: carts.ErrInsufficientStock, status: http.StatusConflict, code: "insufficient_stock"},
+	{err: carts.ErrPriceChanged, status: http.StatusConflict, code: "price_changed", details: priceDetails},
+	{err: inventory.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: inventory.ErrInvalidMovement, status: http.StatusBadRequest, code: "invalid_movement"},
+	{err: inventory.ErrNegativeStock, status: http.StatusConflict, code: "negative_stock"},
+	{err: returns.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: returns.ErrNoSuchOrigin, status: http.StatusNotFound, code: "not_found"},
+	{err: returns.ErrInvalidReturn, status: http.StatusBadRequest, code: "invalid_return"},
+	{err: returns.ErrUnknownPosition, status: http.StatusBadRequest, code: "unknown_position"},
+	{err: returns.ErrQtyExceeded, status: http.StatusConflict, code: "return_exceeds_sold"},
+	{err: returns.ErrSaleCancelled, status: http.StatusConflict, code: "sale_cancelled"},
+	{err: idempotency.ErrInvalidKey, status: http.StatusBadRequest, code: "invalid_idempotency_key"},
+	{err: idempotency.ErrKeyReused, status: http.StatusUnprocessableEntity, code: "idempotency_key_reused"},
+	{err: idempotency.ErrInProgress, status: http.StatusConflict, code: "request_in_progress"},
+	{err: health.ErrDraining, status: http.StatusServiceUnavailable, code: "draining"},
+	{err: health.ErrDatabase, status: http.StatusServiceUnavailable, code: "database_unavailable"},
+	{err: health.ErrSchemaOutdated, status: http.StatusServiceUnavailable, code: "schema_outdated"},
+	{err: sales.ErrInsufficientStock, status: http.StatusConflict, code: "insufficient_stock", details: stockDetails},
+	{err: purchases.ErrInsufficientStock, status: http.StatusConflict, code: "insufficient_stock", details: stockDetails},
+}
+
+func stockDetails(err error) interface{} {
+	var stockErr *sales.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		return map[string]interface{}{"product_ids": stockErr.ProductIDs}
+	}
+	var purchaseErr *purchases.InsufficientStockError
+	if errors.As(err, &purchaseErr) {
+		return map[string]interface{}{"product_ids": purchaseErr.ProductIDs}
+	}
+	return nil
+}
+
+func priceDetails(err error) interface{} {
+	var priceErr *carts.PriceChangedError
+	if errors.As(err, &priceErr) {
+		return map[string]interface{}{"changes": priceErr.Changes}
+	}
+	return nil
+}
+
+func passwordDetails(err error) interface{} {
+	var policyErr *passwords.PolicyError
+	if errors.As(err, &policyErr) {
+		return map[string]interface{}{"violations": policyErr.Violations}
+	}
+	return nil
+}
+
+func retryDetails(err error) interface{} {
+	delay, ok := retryDelay(err)
+	if ok {
+		return map[string]interface{}{"retry_after": retryAfterSeconds(delay)}
+	}
+	return nil
+}
+
+func retryAfter(err error, header http.Header) {
+	delay, ok := retryDelay(err)
+	if ok {
+		header.Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+	}
+}
+
+// retryDelay достаёт из ошибки, через сколько можно повторить запрос.
+func retryDelay(err error) (time.Duration, bool) {
+	var lockedErr *auth.LockedError
+	if errors.As(err, &lockedErr) {
+		return lockedErr.RetryAfter, true
+	}
+	var resendErr *customers.ResendError
+	if errors.As(err, &resendErr) {
+		return resendErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// retryAfterSeconds округляет задержку вверх до целых секунд.
+func retryAfterSeconds(delay time.Duration) int {
+	return int((delay + time.Second - 1) / time.Second)
+}
+
+// respondError отвечает клиенту ошибкой в формате APIError.
+// Незарегистрированные ошибки считаются внутренними и не раскрываются клиенту.
+func (s *Server) respondError(writer http.ResponseWriter, request *http.Request, err error) {
+	status := http.StatusInternalServerError
+	result := &APIError{
+		Code:      "internal",
+		Message:   errInternal.Error(),
+		RequestID: middleware.RequestIDFrom(request.Context()),
+	}
+
+	matched := false
+	for _, mapping := range errorRegistry {
+		if !errors.Is(err, mapping.err) {
+			continue
+		}
+		status = mapping.status
+		result.Code = mapping.code
+		result.Message = err.Error()
+		if mapping.details != nil {
+			result.Details = mapping.details(err)
+		}
+		if mapping.header != nil {
+			mapping.header(err, writer.Header())
+		}
+		matched = true
+		break
+	}
+	if !matched {
+		log.Printf("request %s: %v", result.RequestID, err)
+	}
+
+	s.respondJSON(writer, status, result)
+}
+
+// respondJSON сериализует value в JSON и отправляет его с указанным статусом.
+func (s *Server) respondJSON(writer http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Print(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_, err = writer.Write(data)
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// HealthStruct - ответ /healthz и /readyz.
+type HealthStruct struct {
+	Status string `json:"status"`
+}
+
+// InternalHandler возвращает обработчик служебных маршрутов для отдельного
+// (внутреннего) listener'а.
+func (s *Server) InternalHandler() http.Handler {
+	router := mux.NewRouter()
+	s.initHealth(router)
+	return router
+}
+
+// initHealth регистрирует служебные маршруты без аутентификации.
+func (s *Server) initHealth(router *mux.Router) {
+	router.HandleFunc("/healthz", s.handleHealthz).Methods("GET")
+	router.HandleFunc("/readyz", s.handleReadyz).Methods("GET")
+	router.HandleFunc("/debug/status", s.handleDebugStatus).Methods("GET")
+}
+
+// handleHealthz отвечает, пока процесс жив.
+func (s *Server) handleHealthz(writer http.ResponseWriter, request *http.Request) {
+	s.respondJSON(writer, http.StatusOK, &HealthStruct{Status: "ok"})
+}
+
+// handleReadyz отвечает 503, пока БД недоступна или идёт остановка.
+func (s *Server) handleReadyz(writer http.ResponseWriter, request *http.Request) {
+	err := s.healthSvc.Ready(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, &HealthStruct{Status: "ready"})
+}
+
+func (s *Server) handleDebugStatus(writer http.ResponseWriter, request *http.Request) {
+	s.respondJSON(writer, http.StatusOK, s.healthSvc.Status(request.Context()))
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Fanisabonu/http/pkg/inventory"
+)
+
+// handleGetProductMovements отдаёт историю движений товара от новых к старым.
+// Параметры: limit и before (id последнего движения предыдущей страницы).
+func (s *Server) handleGetProductMovements(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	query := request.URL.Query()
+	limit, err := intQuery(query.Get("limit"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	before, err := intQuery(query.Get("before"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if limit == nil {
+		limit = new(int)
+	}
+	if before == nil {
+		before = new(int)
+	}
+
+	items, err := s.inventorySvc.History(request.Context(), id, int64(*before), *limit)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
+
+// handleAdjustProductStock проводит поступление (restock) или ручную корректировку (adjustment).
+func (s *Server) handleAdjustProductStock(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	adjustment := &inventory.Adjustment{}
+	err = decodeJSON(request, adjustment)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.inventorySvc.Adjust(request.Context(), id, adjustment)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusCreated, item)
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/audit"
+)
+
+// clientIP возвращает адрес клиента. X-Forwarded-For учитывается только
+// при cfg.TrustForwardedFor: последний адрес в нём добавлен нашим прокси,
+// остальные клиент может подделать. Если заголовок пришёл при выключенной
+// настройке, сервер, скорее всего, стоит за прокси - об этом один раз
+// пишется предупреждение.
+func (s *Server) clientIP(request *http.Request) string {
+	forwarded := request.Header.Get("X-Forwarded-For")
+	if forwarded != "" && s.cfg.TrustForwardedFor {
+		addresses := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+	if forwarded != "" {
+		s.proxyWarning.Do(func() {
+			log.Print("warning: request with X-Forwarded-For while trust_forwarded_for is off; behind a reverse proxy all clients share its address and IP login lockouts block everyone")
+		})
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// handleListLockouts отдаёт телефоны и адреса, вход с которых сейчас запрещён.
+func (s *Server) handleListLockouts(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.authSvc.Lockouts(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
+
+// handleUnlock снимает запрет входа по ключу из списка блокировок.
+func (s *Server) handleUnlock(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	var body struct {
+		Key string `json:"key"`
+	}
+	err = decodeJSON(request, &body)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	err = s.authSvc.Unlock(request.Context(), principal.ID, body.Key)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// handleListAuditEvents отдаёт журнал аудита от новых событий к старым.
+// Параметры: kind, limit и before.
+func (s *Server) handleListAuditEvents(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := &audit.Filter{Kind: audit.Kind(query.Get("kind"))}
+
+	limit, err := intQuery(query.Get("limit"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+	before, err := int64Query(query.Get("before"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if before != nil {
+		filter.Before = *before
+	}
+
+	items, err := s.auditSvc.Events(request.Context(), filter)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/managers"
+)
+
+// handleAcceptInvite задаёт пароль по приглашению и сразу выдаёт токен:
+// {"invite": "...", "password": "..."}.
+func (s *Server) handleAcceptInvite(writer http.ResponseWriter, request *http.Request) {
+	item := &managers.Acceptance{}
+	err := decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	id, err := s.managersSvc.AcceptInvite(request.Context(), item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	token, err := s.authSvc.IssueToken(request.Context(), auth.KindManager, id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, token)
+}
+
+// handleListManagers отдаёт всех менеджеров, включая неактивных.
+func (s *Server) handleListManagers(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.managersSvc.Managers(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
+
+// handleGetManager отдаёт менеджера ему самому, его руководителям и ADMIN.
+func (s *Server) handleGetManager(writer http.ResponseWriter, request *http.Request) {
+	id, err := s.accessibleManagerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item, err := s.managersSvc.Manager(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handlePatchManager меняет имя, телефон, отдел, оклад и план.
+func (s *Server) handlePatchManager(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	patch := &managers.Patch{}
+	err = decodeJSON(request, patch)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.managersSvc.Patch(request.Context(), id, patch)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleDeactivateManager отключает менеджера; его подчинённые переходят к его руководителю.
+func (s *Server) handleDeactivateManager(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item, err := s.managersSvc.Deactivate(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleSetManagerRoles заменяет роли менеджера: {"roles": ["MANAGER"]}.
+func (s *Server) handleSetManagerRoles(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	var body struct {
+		Roles []string `json:"roles"`
+	}
+	err = decodeJSON(request, &body)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.managersSvc.SetRoles(request.Context(), id, body.Roles)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleSetManagerBoss назначает руководителя: {"boss_id": 1}; null убирает его.
+func (s *Server) handleSetManagerBoss(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	var body struct {
+		BossID *int64 `json:"boss_id"`
+	}
+	err = decodeJSON(request, &body)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.managersSvc.SetBoss(request.Context(), id, body.BossID)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleMyTeam отдаёт подчинённых текущего менеджера на всех уровнях.
+func (s *Server) handleMyTeam(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondTeam(writer, request, principal.ID)
+}
+
+// handleGetTeam отдаёт подчинённых менеджера из пути (см. checkManagerAccess).
+func (s *Server) handleGetTeam(writer http.ResponseWriter, request *http.Request) {
+	id, err := s.accessibleManagerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondTeam(writer, request, id)
+}
+
+func (s *Server) respondTeam(writer http.ResponseWriter, request *http.Request, id int64) {
+	items, err := s.managersSvc.Team(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
+
+// accessibleManagerID возвращает id менеджера из пути, если он доступен текущему.
+func (s *Server) accessibleManagerID(request *http.Request) (int64, error) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		return 0, err
+	}
+	return id, s.checkManagerAccess(request, id)
+}
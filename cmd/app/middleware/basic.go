@@ -3,17 +3,25 @@ package middleware
 import (
 	"context"
 	"errors"
-	"log"
 	"net/http"
+
+	"github.com/Fanisabonu/http/pkg/auth"
 )
 
+// ErrForbidden возвращается, когда у пользователя нет ни одной из требуемых ролей.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrorFunc отвечает клиенту ошибкой. Middleware не знают формат ответа
+// и делегируют его серверу.
+type ErrorFunc func(writer http.ResponseWriter, request *http.Request, err error)
+
 type HasAnyRoleFunc func(ctx context.Context, roles ...string) bool
 
-func CheckRole(hasAnyRoleFunc HasAnyRoleFunc, roles ...string) func(http.Handler) http.Handler {
+func CheckRole(onError ErrorFunc, hasAnyRoleFunc HasAnyRoleFunc, roles ...string) func(http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			if !hasAnyRoleFunc(request.Context(), roles...) {
-				http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				onError(writer, request, ErrForbidden)
 				return
 			}
 			handler.ServeHTTP(writer, request)
@@ -33,8 +41,6 @@ func CheckRole(hasAnyRoleFunc HasAnyRoleFunc, roles ...string) func(http.Handler
 
 var ErrNoAuthentication = errors.New("no authentication")
 
-var authenticationContextKey = &contextKey{"authentication context"}
-
 type contextKey struct {
 	name string
 }
@@ -43,44 +49,50 @@ func (c *contextKey) String() string {
 	return c.name
 }
 
-type IDFunc func(ctx context.Context, token string) (int64, error)
+// PrincipalFunc находит пользователя по токену. Для неизвестного токена
+// должна вернуть ошибку, а не пустой Principal.
+type PrincipalFunc func(ctx context.Context, token string) (*auth.Principal, error)
 
-//Authenticate ...
-func Authenticate(idFunc IDFunc) func(http.Handler) http.Handler {
+// Authenticate пропускает дальше только запросы с действующим токеном
+// в заголовке Authorization и кладёт в контекст найденный Principal.
+// Запросы без токена отклоняются с ErrNoAuthentication.
+func Authenticate(onError ErrorFunc, principalFunc PrincipalFunc) func(http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			token := request.Header.Get("Authorization")
+			if token == "" {
+				onError(writer, request, ErrNoAuthentication)
+				return
+			}
 
-			log.Print("мы тут тоже, в бейсике")
-			id, err := idFunc(request.Context(), token)
+			principal, err := principalFunc(request.Context(), token)
 			if err != nil {
-				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				onError(writer, request, err)
+				return
+			}
+			if principal == nil {
+				onError(writer, request, ErrNoAuthentication)
 				return
 			}
 
-			ctx := context.WithValue(request.Context(), authenticationContextKey, id)
-			request = request.WithContext(ctx)
-
+			request = request.WithContext(auth.NewContext(request.Context(), principal))
 			handler.ServeHTTP(writer, request)
 		})
 	}
 }
 
-// Authentication ...
-func Authentication(ctx context.Context) (int64, error) {
-	if value, ok := ctx.Value(authenticationContextKey).(int64); ok {
-		return value, nil
+// Principal возвращает пользователя, прошедшего Authenticate.
+func Principal(ctx context.Context) (*auth.Principal, error) {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return principal, nil
 	}
-	return 0, ErrNoAuthentication
+	return nil, ErrNoAuthentication
 }
 
 // // Basic ...
 // func (mw *Middleware) Basic(next http.Handler) http.Handler {
 // 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 // 		login, password, ok := request.BasicAuth()
-// 		log.Println(login)
-// 		log.Println(password)
-// 		log.Println(ok)
 
 
 // 		result := mw.secService.Auth(request.Context(), login, password)
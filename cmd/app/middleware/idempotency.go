@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Fanisabonu/http/pkg/idempotency"
+)
+
+// IdempotencyKeyHeader - заголовок с ключом идемпотентности запроса.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader выставляется в ответах, повторённых из сохранённых.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyStore резервирует ключи и хранит ответы (см. idempotency.Service).
+type IdempotencyStore interface {
+	Begin(ctx context.Context, scope string, key string, fingerprint string) (*idempotency.Response, error)
+	Complete(ctx context.Context, scope string, key string, response *idempotency.Response) error
+	Release(ctx context.Context, scope string, key string) error
+}
+
+// Idempotency выполняет изменяющие запросы (POST, PUT, PATCH, DELETE) с
+// заголовком Idempotency-Key не больше одного раза: ответ на первый запрос
+// сохраняется, повтор с тем же ключом, методом, путём и телом получает его
+// снова. Ключи принадлежат пользователю, поэтому middleware ставится после
+// Authenticate; запросы без пользователя и без ключа проходят как есть.
+// Ответы 5xx не сохраняются и при панике обработчика ключ тоже освобождается -
+// такой запрос можно повторить с тем же ключом. Если ответ не удалось
+// сохранить, ключ остаётся занятым: повтор получит 409, пока не истечёт
+// резервирование (idempotency.Service), но не выполнит запрос второй раз.
+func Idempotency(onError ErrorFunc, store IdempotencyStore) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			key := request.Header.Get(IdempotencyKeyHeader)
+			principal, err := Principal(request.Context())
+			if key == "" || err != nil || !mutating(request.Method) {
+				handler.ServeHTTP(writer, request)
+				return
+			}
+
+			body, err := ioutil.ReadAll(request.Body)
+			if err != nil {
+				onError(writer, request, err)
+				return
+			}
+			request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			scope := string(principal.Kind) + ":" + strconv.FormatInt(principal.ID, 10)
+			hash := sha256.New()
+			hash.Write([]byte(request.Method + " " + request.URL.RequestURI() + "\n"))
+			hash.Write(body)
+			fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+			saved, err := store.Begin(request.Context(), scope, key, fingerprint)
+			if err != nil {
+				onError(writer, request, err)
+				return
+			}
+			if saved != nil {
+				if saved.ContentType != "" {
+					writer.Header().Set("Content-Type", saved.ContentType)
+				}
+				writer.Header().Set(IdempotentReplayedHeader, "true")
+				writer.WriteHeader(saved.Status)
+				_, err = writer.Write(saved.Body)
+				if err != nil {
+					log.Print(err)
+				}
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: writer, status: http.StatusOK}
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					release(store, scope, key)
+					panic(recovered)
+				}
+			}()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.status >= http.StatusInternalServerError {
+				release(store, scope, key)
+				return
+			}
+			complete(store, scope, key, &idempotency.Response{
+				Status:      recorder.status,
+				ContentType: writer.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			})
+		})
+	}
+}
+
+// completeAttempts - сколько раз пробовать сохранить ответ.
+const completeAttempts = 3
+
+// complete сохраняет ответ, повторяя попытку при ошибке. Запрос уже выполнен,
+// поэтому контекст запроса (его отменяет ушедший клиент) не используется.
+func complete(store IdempotencyStore, scope string, key string, response *idempotency.Response) {
+	var err error
+	for attempt := 0; attempt < completeAttempts; attempt++ {
+		err = store.Complete(context.Background(), scope, key, response)
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("idempotency key %q is left in progress: %v", key, err)
+}
+
+// release освобождает ключ запроса, который не выполнился.
+func release(store IdempotencyStore, scope string, key string) {
+	err := store.Release(context.Background(), scope, key)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder пишет ответ клиенту и запоминает статус и тело.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/storage/memory"
+)
+
+// brokenStore не сохраняет ответы, остальное делает idempotency.Service.
+type brokenStore struct {
+	*idempotency.Service
+}
+
+func (s brokenStore) Complete(ctx context.Context, scope string, key string, response *idempotency.Response) error {
+	return idempotency.ErrInternal
+}
+
+type idempotencyFixture struct {
+	store  IdempotencyStore
+	calls  int
+	status int
+	panics bool
+	errors []error
+}
+
+func newIdempotencyFixture() *idempotencyFixture {
+	store := memory.NewStore()
+	return &idempotencyFixture{
+		store:  idempotency.NewService(memory.NewIdempotencyRepo(store), config.Default(), lifecycle.New()),
+		status: http.StatusCreated,
+	}
+}
+
+// serve выполняет запрос покупателя 1 через Idempotency.
+func (f *idempotencyFixture) serve(key string, body string) *httptest.ResponseRecorder {
+	onError := func(writer http.ResponseWriter, request *http.Request, err error) {
+		f.errors = append(f.errors, err)
+		writer.WriteHeader(http.StatusConflict)
+	}
+	handler := Idempotency(onError, f.store)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		f.calls++
+		if f.panics {
+			panic("handler failed")
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(f.status)
+		_, _ = writer.Write([]byte(`{"call":` + strconv.Itoa(f.calls) + `}`))
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/sales", strings.NewReader(body))
+	request.Header.Set(IdempotencyKeyHeader, key)
+	request = request.WithContext(auth.NewContext(request.Context(), &auth.Principal{Kind: auth.KindCustomer, ID: 1}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (f *idempotencyFixture) lastError() error {
+	if len(f.errors) == 0 {
+		return nil
+	}
+	return f.errors[len(f.errors)-1]
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	first := f.serve("key", `{"qty":1}`)
+	second := f.serve("key", `{"qty":1}`)
+	if f.calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", f.calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("replay headers = %v", second.Header())
+	}
+
+	f.serve("key", `{"qty":2}`)
+	if !errors.Is(f.lastError(), idempotency.ErrKeyReused) || f.calls != 1 {
+		t.Errorf("other body: err = %v, calls = %d; want ErrKeyReused", f.lastError(), f.calls)
+	}
+}
+
+func TestIdempotencyReleasesFailedRequest(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	f.status = http.StatusInternalServerError
+	f.serve("key", `{}`)
+	f.status = http.StatusCreated
+	response := f.serve("key", `{}`)
+	if f.calls != 2 || response.Code != http.StatusCreated {
+		t.Errorf("after 5xx: calls = %d, status = %d; want the request to run again", f.calls, response.Code)
+	}
+}
+
+func TestIdempotencyReleasesOnPanic(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	f.panics = true
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was not propagated")
+			}
+		}()
+		f.serve("key", `{}`)
+	}()
+
+	f.panics = false
+	response := f.serve("key", `{}`)
+	if f.calls != 2 || response.Code != http.StatusCreated {
+		t.Errorf("after panic: calls = %d, status = %d; want the request to run again", f.calls, response.Code)
+	}
+}
+
+func TestIdempotencyKeepsKeyWhenCompleteFails(t *testing.T) {
+	f := newIdempotencyFixture()
+	f.store = brokenStore{f.store.(*idempotency.Service)}
+
+	f.serve("key", `{}`)
+	f.serve("key", `{}`)
+	if f.calls != 1 || !errors.Is(f.lastError(), idempotency.ErrInProgress) {
+		t.Errorf("calls = %d, err = %v; want the key to stay in progress", f.calls, f.lastError())
+	}
+}
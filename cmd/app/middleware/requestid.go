@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader - заголовок, в котором передаётся идентификатор запроса.
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDContextKey = &contextKey{"request id context"}
+
+// RequestID присваивает каждому запросу идентификатор (или берёт его из
+// заголовка X-Request-ID), кладёт в контекст и возвращает в ответе.
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+
+		writer.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(request.Context(), requestIDContextKey, id)
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// RequestIDFrom возвращает идентификатор запроса из контекста
+// или пустую строку, если его нет.
+func RequestIDFrom(ctx context.Context) string {
+	if value, ok := ctx.Value(requestIDContextKey).(string); ok {
+		return value
+	}
+	return ""
+}
+
+func newRequestID() string {
+	buffer := make([]byte, 8)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buffer)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/auth"
+)
+
+// handleChangePassword меняет пароль текущего пользователя (и покупателя,
+// и менеджера). Все его токены отзываются, в ответе - новая пара.
+func (s *Server) handleChangePassword(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item := &auth.PasswordChange{}
+	err = decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	token, err := s.authSvc.ChangePassword(request.Context(), principal, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, token)
+}
+
+func (s *Server) handleCustomerResetCode(writer http.ResponseWriter, request *http.Request) {
+	s.requestResetCode(writer, request, s.authSvc.RequestCustomerReset)
+}
+
+func (s *Server) handleManagerResetCode(writer http.ResponseWriter, request *http.Request) {
+	s.requestResetCode(writer, request, s.authSvc.RequestManagerReset)
+}
+
+// requestResetCode отправляет код сброса пароля. Ответ одинаковый
+// независимо от того, зарегистрирован ли телефон.
+func (s *Server) requestResetCode(writer http.ResponseWriter, request *http.Request, send func(ctx context.Context, phone string) error) {
+	item := &auth.ResetRequest{}
+	err := decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	err = send(request.Context(), item.Phone)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleCustomerResetPassword(writer http.ResponseWriter, request *http.Request) {
+	s.resetPassword(writer, request, s.authSvc.ResetCustomerPassword)
+}
+
+func (s *Server) handleManagerResetPassword(writer http.ResponseWriter, request *http.Request) {
+	s.resetPassword(writer, request, s.authSvc.ResetManagerPassword)
+}
+
+// resetPassword задаёт новый пароль по коду и выдаёт пару токенов.
+func (s *Server) resetPassword(writer http.ResponseWriter, request *http.Request, reset func(ctx context.Context, item *auth.PasswordReset) (*auth.Token, error)) {
+	item := &auth.PasswordReset{}
+	err := decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	token, err := reset(request.Context(), item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, token)
+}
@@ -0,0 +1,44 @@
+package app
+
+import "net/http"
+
+// handleRequestPhoneVerification отправляет покупателю код подтверждения телефона.
+func (s *Server) handleRequestPhoneVerification(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	err = s.customersSvc.RequestVerification(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// handleVerifyPhone подтверждает телефон покупателя кодом из SMS.
+func (s *Server) handleVerifyPhone(writer http.ResponseWriter, request *http.Request) {
+	id, err := customerID(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	err = decodeJSON(request, &body)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.customersSvc.VerifyPhone(request.Context(), id, body.Code)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Fanisabonu/http/pkg/products"
+)
+
+// productFilter разбирает параметры каталога:
+// q, min_price, max_price, sort, limit, cursor и (для менеджеров) include_inactive.
+func productFilter(request *http.Request, staff bool) (*products.Filter, string, error) {
+	query := request.URL.Query()
+	filter := &products.Filter{
+		Query: query.Get("q"),
+		Sort:  products.Sort(query.Get("sort")),
+	}
+
+	var err error
+	filter.MinPrice, err = intQuery(query.Get("min_price"))
+	if err != nil {
+		return nil, "", err
+	}
+	filter.MaxPrice, err = intQuery(query.Get("max_price"))
+	if err != nil {
+		return nil, "", err
+	}
+	limit, err := intQuery(query.Get("limit"))
+	if err != nil {
+		return nil, "", err
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+	if staff && query.Get("include_inactive") != "" {
+		filter.IncludeInactive, err = strconv.ParseBool(query.Get("include_inactive"))
+		if err != nil {
+			return nil, "", errBadRequest
+		}
+	}
+	return filter, query.Get("cursor"), nil
+}
+
+// intQuery разбирает необязательный числовой параметр запроса.
+func intQuery(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	return &parsed, nil
+}
+
+func (s *Server) handleCustomerGetProducts(writer http.ResponseWriter, request *http.Request) {
+	s.listProducts(writer, request, false)
+}
+
+func (s *Server) handleManagerGetProducts(writer http.ResponseWriter, request *http.Request) {
+	s.listProducts(writer, request, true)
+}
+
+func (s *Server) listProducts(writer http.ResponseWriter, request *http.Request, staff bool) {
+	filter, cursor, err := productFilter(request, staff)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	page, err := s.productsSvc.List(request.Context(), filter, cursor)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, page)
+}
+
+func (s *Server) handleGetProductByID(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item, err := s.productsSvc.ByID(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+func (s *Server) handleCreateProduct(writer http.ResponseWriter, request *http.Request) {
+	item := &products.Product{}
+	err := decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	result, err := s.productsSvc.Create(request.Context(), item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusCreated, result)
+}
+
+func (s *Server) handleReplaceProduct(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	item := &products.Product{}
+	err = decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item.ID = id
+
+	result, err := s.productsSvc.Replace(request.Context(), item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, result)
+}
+
+func (s *Server) handlePatchProduct(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	patch := &products.Patch{}
+	err = decodeJSON(request, patch)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	result, err := s.productsSvc.Patch(request.Context(), id, patch)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, result)
+}
+
+// handleRemoveProduct снимает товар с продажи; вернуть его можно через PATCH {"active": true}.
+func (s *Server) handleRemoveProduct(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	result, err := s.productsSvc.Remove(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, result)
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// reportDate - формат параметров from и to.
+const reportDate = "2006-01-02"
+
+// reportPeriod разбирает from и to (включительно, YYYY-MM-DD, UTC) в полуинтервал
+// [from, to+1 день). По умолчанию - с начала текущего месяца по сегодня.
+func reportPeriod(request *http.Request) (time.Time, time.Time, error) {
+	query := request.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, 1-today.Day())
+	to := today
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		from, err = time.Parse(reportDate, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errBadRequest
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		to, err = time.Parse(reportDate, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errBadRequest
+		}
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// handleManagerGetSales отдаёт отчёт о продажах менеджера: group_by = day|week|month|product|customer.
+// Чужой отчёт (manager_id) доступен руководителям менеджера и ADMIN.
+func (s *Server) handleManagerGetSales(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	filter := &sales.ReportFilter{
+		ManagerID: principal.ID,
+		GroupBy:   sales.Grouping(request.URL.Query().Get("group_by")),
+	}
+	if value := request.URL.Query().Get("manager_id"); value != "" {
+		filter.ManagerID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			s.respondError(writer, request, errBadRequest)
+			return
+		}
+		err = s.checkManagerAccess(request, filter.ManagerID)
+		if err != nil {
+			s.respondError(writer, request, err)
+			return
+		}
+	}
+	filter.From, filter.To, err = reportPeriod(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	report, err := s.salesSvc.Report(request.Context(), filter)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, report)
+}
+
+// handleSalesLeaderboard отдаёт рейтинг менеджеров за период.
+func (s *Server) handleSalesLeaderboard(writer http.ResponseWriter, request *http.Request) {
+	from, to, err := reportPeriod(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	result, err := s.salesSvc.Leaderboard(request.Context(), from, to)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, result)
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/returns"
+)
+
+// handleCreateReturn оформляет возврат по продаже или покупке:
+// {"sale_id": 1, "reason": "...", "items": [{"position_id": 2, "qty": 1, "refund": 100}]}.
+// Возврат по продаже может оформить её менеджер, его руководитель или ADMIN.
+func (s *Server) handleCreateReturn(writer http.ResponseWriter, request *http.Request) {
+	item := &returns.Request{}
+	err := decodeJSON(request, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	if item.SaleID != nil {
+		sale, err := s.salesSvc.Sale(request.Context(), *item.SaleID)
+		if err != nil {
+			s.respondError(writer, request, err)
+			return
+		}
+		err = s.checkManagerAccess(request, sale.ManagerID)
+		if err != nil {
+			s.respondError(writer, request, err)
+			return
+		}
+	}
+
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	result, err := s.returnsSvc.Create(request.Context(), principal.ID, item)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusCreated, result)
+}
+
+// handleGetReturn отдаёт возврат с позициями.
+func (s *Server) handleGetReturn(writer http.ResponseWriter, request *http.Request) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	item, err := s.returnsSvc.Return(request.Context(), id)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleListReturns отдаёт возвраты от новых к старым. Параметры: sale_id,
+// purchase_id, limit и before.
+func (s *Server) handleListReturns(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := &returns.Filter{}
+
+	var err error
+	filter.SaleID, err = int64Query(query.Get("sale_id"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	filter.PurchaseID, err = int64Query(query.Get("purchase_id"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	limit, err := intQuery(query.Get("limit"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+	before, err := int64Query(query.Get("before"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if before != nil {
+		filter.Before = *before
+	}
+
+	items, err := s.returnsSvc.Returns(request.Context(), filter)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// saleManagerID возвращает менеджера, чьи продажи запрошены: по умолчанию -
+// текущего, чужие (manager_id) - см. checkManagerAccess.
+func (s *Server) saleManagerID(request *http.Request, value string) (int64, error) {
+	if value == "" {
+		principal, err := middleware.Principal(request.Context())
+		if err != nil {
+			return 0, err
+		}
+		return principal.ID, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errBadRequest
+	}
+	return id, s.checkManagerAccess(request, id)
+}
+
+// checkManagerAccess разрешает доступ к данным менеджера ему самому,
+// его руководителям (на любом уровне) и ADMIN.
+func (s *Server) checkManagerAccess(request *http.Request, managerID int64) error {
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		return err
+	}
+	if managerID == principal.ID || s.authSvc.HasAnyRole(request.Context(), managers.RoleAdmin) {
+		return nil
+	}
+	ok, err := s.managersSvc.IsSubordinate(request.Context(), principal.ID, managerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return middleware.ErrForbidden
+	}
+	return nil
+}
+
+// dateQuery разбирает необязательную дату YYYY-MM-DD (UTC).
+func dateQuery(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(reportDate, value)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	return &parsed, nil
+}
+
+// int64Query разбирает необязательный идентификатор из параметра запроса.
+func int64Query(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	return &parsed, nil
+}
+
+// handleListSales отдаёт продажи от новых к старым. Параметры: manager_id,
+// customer_id, product_id, from и to (включительно), limit и before.
+func (s *Server) handleListSales(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := &sales.SaleFilter{}
+
+	var err error
+	filter.ManagerID, err = s.saleManagerID(request, query.Get("manager_id"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	filter.CustomerID, err = int64Query(query.Get("customer_id"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	filter.ProductID, err = int64Query(query.Get("product_id"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	filter.From, err = dateQuery(query.Get("from"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	filter.To, err = dateQuery(query.Get("to"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if filter.To != nil {
+		to := filter.To.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	limit, err := intQuery(query.Get("limit"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+	before, err := int64Query(query.Get("before"))
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	if before != nil {
+		filter.Before = *before
+	}
+
+	items, err := s.salesSvc.Sales(request.Context(), filter)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, items)
+}
+
+// handleGetSale отдаёт продажу с позициями. Чужую продажу могут смотреть руководители её менеджера и ADMIN.
+func (s *Server) handleGetSale(writer http.ResponseWriter, request *http.Request) {
+	item, err := s.ownSale(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, item)
+}
+
+// handleCancelSale отменяет продажу: {"reason": "..."}. Отменить может
+// менеджер, оформивший продажу, его руководитель или ADMIN.
+func (s *Server) handleCancelSale(writer http.ResponseWriter, request *http.Request) {
+	item, err := s.ownSale(request)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	cancellation := &sales.Cancellation{}
+	err = decodeJSON(request, cancellation)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+
+	principal, err := middleware.Principal(request.Context())
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	result, err := s.salesSvc.Cancel(request.Context(), item.ID, principal.ID, cancellation)
+	if err != nil {
+		s.respondError(writer, request, err)
+		return
+	}
+	s.respondJSON(writer, http.StatusOK, result)
+}
+
+// ownSale загружает продажу из пути и проверяет, что она доступна текущему менеджеру.
+func (s *Server) ownSale(request *http.Request) (*sales.MakeSale, error) {
+	id, err := idParam(request, "id")
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.salesSvc.Sale(request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkManagerAccess(request, item.ManagerID)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -2,38 +2,80 @@ package app
 
 import (
 	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
 	"strconv"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"github.com/Fanisabonu/http/cmd/app/middleware"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/config"
 	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/health"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/sales"
 )
 
 // Server представляет собой логический сервер нашего приложения.
 type Server struct {
 	mux          *mux.Router
-	customersSvc *customers.Service
+	cfg          *config.Config
+	authSvc      AuthService
+	customersSvc CustomersService
+	managersSvc  ManagersService
+	productsSvc  ProductsService
+	salesSvc     SalesService
+	purchasesSvc PurchasesService
+	inventorySvc InventoryService
+	cartsSvc     CartsService
+	returnsSvc   ReturnsService
+	idempotency  IdempotencyService
+	auditSvc     AuditService
+	healthSvc    *health.Service
+	proxyWarning sync.Once
 	// mw *middleware.Middleware
 }
 
 // NewServer ...
-func NewServer(mux *mux.Router, customersSvc *customers.Service,) *Server {
-	return &Server{mux: mux, customersSvc: customersSvc,}
+func NewServer(
+	mux *mux.Router,
+	cfg *config.Config,
+	authSvc AuthService,
+	customersSvc CustomersService,
+	managersSvc ManagersService,
+	productsSvc ProductsService,
+	salesSvc SalesService,
+	purchasesSvc PurchasesService,
+	inventorySvc InventoryService,
+	cartsSvc CartsService,
+	returnsSvc ReturnsService,
+	idempotency IdempotencyService,
+	auditSvc AuditService,
+	healthSvc *health.Service,
+) *Server {
+	return &Server{
+		mux:          mux,
+		cfg:          cfg,
+		authSvc:      authSvc,
+		customersSvc: customersSvc,
+		managersSvc:  managersSvc,
+		productsSvc:  productsSvc,
+		salesSvc:     salesSvc,
+		purchasesSvc: purchasesSvc,
+		inventorySvc: inventorySvc,
+		cartsSvc:     cartsSvc,
+		returnsSvc:   returnsSvc,
+		idempotency:  idempotency,
+		auditSvc:     auditSvc,
+		healthSvc:    healthSvc,
+	}
 }
 
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	s.mux.ServeHTTP(writer, request)
 }
 
-// MyStruct ...
-type MyStruct struct {
-	Status     string `json:"status"`
-	Reason     string `json:"reason"`
-}
-
 // MySecondStruct ...
 type MySecondStruct struct {
 	Status     string `json:"status"`
@@ -42,650 +84,413 @@ type MySecondStruct struct {
 
 // Init инициализирует сервер (регистрирует все Handler'ы)
 func (s *Server) Init() {
-	customersAuthenticateMd := middleware.Authenticate(s.customersSvc.IDByTokenForCustomers)
+	s.mux.Use(middleware.RequestID)
+
+	// служебные маршруты переезжают на внутренний listener, если он настроен
+	if s.cfg.InternalAddr == "" {
+		s.initHealth(s.mux)
+	}
+
+	customersAuthenticateMd := middleware.Authenticate(s.respondError, s.authSvc.PrincipalByCustomerToken)
+	managersAuthenticateMd := middleware.Authenticate(s.respondError, s.authSvc.PrincipalByManagerToken)
+	// Idempotency-Key работает только для аутентифицированных запросов
+	idempotencyMd := middleware.Idempotency(s.respondError, s.idempotency)
+
+	// требования к ролям объявляются на уровне маршрутов
+	managersOnly := middleware.CheckRole(s.respondError, s.authSvc.HasAnyRole, managers.RoleManager)
+	adminsOnly := middleware.CheckRole(s.respondError, s.authSvc.HasAnyRole, managers.RoleAdmin)
+	staffOnly := middleware.CheckRole(s.respondError, s.authSvc.HasAnyRole, managers.RoleManager, managers.RoleAdmin)
+
+	// маршруты без аутентификации регистрируются раньше подроутеров
+	s.mux.HandleFunc("/api/customers", s.handleCustomerRegistration).Methods("POST")
+	s.mux.HandleFunc("/api/customers/token", s.handleCustomerGetToken).Methods("POST")
+	s.mux.HandleFunc("/api/customers/token/validate", s.handleCustomerValidateToken).Methods("POST")
+	s.mux.HandleFunc("/api/customers/token/refresh", s.handleCustomerRefreshToken).Methods("POST")
+	s.mux.HandleFunc("/api/managers/token", s.handleManagerGetToken).Methods("POST")
+	s.mux.HandleFunc("/api/managers/token/refresh", s.handleManagerRefreshToken).Methods("POST")
+	s.mux.HandleFunc("/api/managers/invite/accept", s.handleAcceptInvite).Methods("POST")
+	s.mux.HandleFunc("/api/customers/password/reset-code", s.handleCustomerResetCode).Methods("POST")
+	s.mux.HandleFunc("/api/customers/password/reset", s.handleCustomerResetPassword).Methods("POST")
+	s.mux.HandleFunc("/api/managers/password/reset-code", s.handleManagerResetCode).Methods("POST")
+	s.mux.HandleFunc("/api/managers/password/reset", s.handleManagerResetPassword).Methods("POST")
 
 	customersSubrouter := s.mux.PathPrefix("/api/customers").Subrouter()
-	customersSubrouter.Use(customersAuthenticateMd)
-	customersSubrouter.HandleFunc("", s.handleCustomerRegistration).Methods("POST")
-	customersSubrouter.HandleFunc("/token", s.handleCustomerGetToken).Methods("POST")
-	customersSubrouter.HandleFunc("/token/validate", s.handleCustomerValidateToken).Methods("POST")
+	customersSubrouter.Use(customersAuthenticateMd, idempotencyMd)
+	customersSubrouter.HandleFunc("/token/logout", s.handleLogout).Methods("POST")
+	customersSubrouter.HandleFunc("/token/logout-all", s.handleLogoutAll).Methods("POST")
+	customersSubrouter.HandleFunc("/password", s.handleChangePassword).Methods("PUT")
+	customersSubrouter.HandleFunc("/phone/verification", s.handleRequestPhoneVerification).Methods("POST")
+	customersSubrouter.HandleFunc("/phone/verify", s.handleVerifyPhone).Methods("POST")
 	customersSubrouter.HandleFunc("/products", s.handleCustomerGetProducts).Methods("GET")
 	customersSubrouter.HandleFunc("/purchases", s.handleCustomerGetPurchases).Methods("GET")
 	customersSubrouter.HandleFunc("/purchases", s.handleCustomerMakePurchase).Methods("POST")
-	customersSubrouter.HandleFunc("/active", s.handleGetAllActiveCustomers).Methods("GET")
-	customersSubrouter.HandleFunc("/{id}", s.handleGetCustomerByID).Methods("GET")
-	customersSubrouter.HandleFunc("", s.handleGetAllCustomers).Methods("GET")
-	customersSubrouter.HandleFunc("/{id}", s.handleRemoveCustomerByID).Methods("DELETE")
-	customersSubrouter.HandleFunc("/{id}/block", s.handleBlockCustomerByID).Methods("POST")
-	customersSubrouter.HandleFunc("/{id}/block", s.handleUnblockCustomerByID).Methods("DELETE")
-	
-	managerAuthenticateMd2 := middleware.Authenticate(s.customersSvc.IDByTokenForManagers2)
-	managersSubrouter2 := s.mux.PathPrefix("/api/managers/sales").Subrouter()
-	managersSubrouter2.Use(managerAuthenticateMd2)
-
-	managersSubrouter3 := s.mux.PathPrefix("/api/managers/sales").Subrouter()
-	managersSubrouter3.Use(managerAuthenticateMd2)
-
-	managerAuthenticateMd := middleware.Authenticate(s.customersSvc.IDByTokenForManagers)
+	customersSubrouter.HandleFunc("/cart", s.handleGetCart).Methods("GET")
+	customersSubrouter.HandleFunc("/cart", s.handleReplaceCart).Methods("PUT")
+	customersSubrouter.HandleFunc("/cart", s.handleClearCart).Methods("DELETE")
+	customersSubrouter.HandleFunc("/cart/items", s.handleAddCartItem).Methods("POST")
+	customersSubrouter.HandleFunc("/cart/items/{product_id}", s.handleSetCartItem).Methods("PUT")
+	customersSubrouter.HandleFunc("/cart/items/{product_id}", s.handleRemoveCartItem).Methods("DELETE")
+	customersSubrouter.HandleFunc("/cart/checkout", s.handleCheckout).Methods("POST")
+
+	// администрирование покупателей доступно только менеджерам с ролью ADMIN
+	customersAdminSubrouter := s.mux.PathPrefix("/api/customers").Subrouter()
+	customersAdminSubrouter.Use(managersAuthenticateMd, idempotencyMd)
+	customersAdminSubrouter.Handle("/active", adminsOnly(http.HandlerFunc(s.handleGetAllActiveCustomers))).Methods("GET")
+	customersAdminSubrouter.Handle("/{id}", adminsOnly(http.HandlerFunc(s.handleGetCustomerByID))).Methods("GET")
+	customersAdminSubrouter.Handle("", adminsOnly(http.HandlerFunc(s.handleGetAllCustomers))).Methods("GET")
+	customersAdminSubrouter.Handle("/{id}", adminsOnly(http.HandlerFunc(s.handleRemoveCustomerByID))).Methods("DELETE")
+	customersAdminSubrouter.Handle("/{id}/block", adminsOnly(http.HandlerFunc(s.handleBlockCustomerByID))).Methods("POST")
+	customersAdminSubrouter.Handle("/{id}/block", adminsOnly(http.HandlerFunc(s.handleUnblockCustomerByID))).Methods("DELETE")
+
 	managersSubrouter := s.mux.PathPrefix("/api/managers").Subrouter()
-	managersSubrouter.Use(managerAuthenticateMd)
-	managersSubrouter.HandleFunc("", s.handleManagerRegistration).Methods("POST")
-	managersSubrouter.HandleFunc("/token", s.handleManagerGetToken).Methods("POST")
+	managersSubrouter.Use(managersAuthenticateMd, idempotencyMd)
+	managersSubrouter.HandleFunc("/token/logout", s.handleLogout).Methods("POST")
+	managersSubrouter.HandleFunc("/token/logout-all", s.handleLogoutAll).Methods("POST")
+	managersSubrouter.HandleFunc("/password", s.handleChangePassword).Methods("PUT")
+	managersSubrouter.Handle("", adminsOnly(http.HandlerFunc(s.handleManagerRegistration))).Methods("POST")
+	managersSubrouter.Handle("", adminsOnly(http.HandlerFunc(s.handleListManagers))).Methods("GET")
+	managersSubrouter.Handle("/team", staffOnly(http.HandlerFunc(s.handleMyTeam))).Methods("GET")
+	managersSubrouter.Handle("/{id:[0-9]+}", staffOnly(http.HandlerFunc(s.handleGetManager))).Methods("GET")
+	managersSubrouter.Handle("/{id:[0-9]+}", adminsOnly(http.HandlerFunc(s.handlePatchManager))).Methods("PATCH")
+	managersSubrouter.Handle("/{id:[0-9]+}/deactivate", adminsOnly(http.HandlerFunc(s.handleDeactivateManager))).Methods("POST")
+	managersSubrouter.Handle("/{id:[0-9]+}/roles", adminsOnly(http.HandlerFunc(s.handleSetManagerRoles))).Methods("PUT")
+	managersSubrouter.Handle("/{id:[0-9]+}/boss", adminsOnly(http.HandlerFunc(s.handleSetManagerBoss))).Methods("PUT")
+	managersSubrouter.Handle("/{id:[0-9]+}/team", staffOnly(http.HandlerFunc(s.handleGetTeam))).Methods("GET")
+	managersSubrouter.Handle("/lockouts", adminsOnly(http.HandlerFunc(s.handleListLockouts))).Methods("GET")
+	managersSubrouter.Handle("/lockouts/unlock", adminsOnly(http.HandlerFunc(s.handleUnlock))).Methods("POST")
+	managersSubrouter.Handle("/audit", adminsOnly(http.HandlerFunc(s.handleListAuditEvents))).Methods("GET")
 	// managersSubrouter.HandleFunc("/token/validate", s.handleManagerValidateToken).Methods("POST")
-	managersSubrouter3.HandleFunc("", s.handleManagerGetSales).Methods("GET")
-	managersSubrouter2.HandleFunc("", s.handleManagerMakeSale).Methods("POST")
-	s.mux.HandleFunc("/api/managers/products", s.handleManagerChangeProduct).Methods("POST")
+	managersSubrouter.Handle("/sales", staffOnly(http.HandlerFunc(s.handleManagerGetSales))).Methods("GET")
+	managersSubrouter.Handle("/sales/leaderboard", adminsOnly(http.HandlerFunc(s.handleSalesLeaderboard))).Methods("GET")
+	managersSubrouter.Handle("/sales/list", staffOnly(http.HandlerFunc(s.handleListSales))).Methods("GET")
+	managersSubrouter.Handle("/sales/{id:[0-9]+}", staffOnly(http.HandlerFunc(s.handleGetSale))).Methods("GET")
+	managersSubrouter.Handle("/sales/{id:[0-9]+}/cancel", staffOnly(http.HandlerFunc(s.handleCancelSale))).Methods("POST")
+	managersSubrouter.Handle("/returns", staffOnly(http.HandlerFunc(s.handleListReturns))).Methods("GET")
+	managersSubrouter.Handle("/returns", staffOnly(http.HandlerFunc(s.handleCreateReturn))).Methods("POST")
+	managersSubrouter.Handle("/returns/{id:[0-9]+}", staffOnly(http.HandlerFunc(s.handleGetReturn))).Methods("GET")
+	managersSubrouter.Handle("/sales", managersOnly(http.HandlerFunc(s.handleManagerMakeSale))).Methods("POST")
+	managersSubrouter.Handle("/products", staffOnly(http.HandlerFunc(s.handleManagerGetProducts))).Methods("GET")
+	managersSubrouter.Handle("/products", adminsOnly(http.HandlerFunc(s.handleCreateProduct))).Methods("POST")
+	managersSubrouter.Handle("/products/{id}", staffOnly(http.HandlerFunc(s.handleGetProductByID))).Methods("GET")
+	managersSubrouter.Handle("/products/{id}", adminsOnly(http.HandlerFunc(s.handleReplaceProduct))).Methods("PUT")
+	managersSubrouter.Handle("/products/{id}", adminsOnly(http.HandlerFunc(s.handlePatchProduct))).Methods("PATCH")
+	managersSubrouter.Handle("/products/{id}", adminsOnly(http.HandlerFunc(s.handleRemoveProduct))).Methods("DELETE")
+	managersSubrouter.Handle("/products/{id}/movements", staffOnly(http.HandlerFunc(s.handleGetProductMovements))).Methods("GET")
+	managersSubrouter.Handle("/products/{id}/movements", adminsOnly(http.HandlerFunc(s.handleAdjustProductStock))).Methods("POST")
 	// managersSubrouter.HandleFunc("/customers", s.handleManagerGetCustomers).Methods("GET")
 	// managersSubrouter.HandleFunc("/customers", s.handleManagerChangeCustomer).Methods("POST")
 	// managersSubrouter.HandleFunc("/customers/{id}", s.handleManagerRemoveCustomerByID).Methods("DELETE")
 }
 
-
-func (s *Server) handleManagerGetSales(writer http.ResponseWriter, request *http.Request)  {
-	id, err := middleware.Authentication(request.Context())
+// decodeJSON разбирает тело запроса в item. Ошибка разбора превращается в errBadRequest.
+func decodeJSON(request *http.Request, item interface{}) error {
+	err := json.NewDecoder(request.Body).Decode(item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	if id == 0 {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	result, err := s.customersSvc.GetSales(request.Context(), id)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+		return errBadRequest
 	}
+	return nil
+}
 
-	total := &customers.GetSales{
-		ManagerID: id,
-		Total: result,
+// idParam возвращает числовой параметр пути с именем name.
+func idParam(request *http.Request, name string) (int64, error) {
+	value, ok := mux.Vars(request)[name]
+	if !ok {
+		return 0, errBadRequest
 	}
 
-	data, err := json.Marshal(total)
+	id, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
+		return 0, errBadRequest
 	}
-
-
+	return id, nil
 }
 
-
 func (s *Server) handleManagerMakeSale(writer http.ResponseWriter, request *http.Request)  {
-
-	valueID, err := middleware.Authentication(request.Context())  
+	principal, err := middleware.Principal(request.Context())
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return 
-	}
-
-	if valueID == 0 {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	var item *customers.MakeSale
-
-	err = json.NewDecoder(request.Body).Decode(&item)
-	
+	item := &sales.MakeSale{}
+	err = decodeJSON(request, item)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
-	
-	item.ManagerID = valueID
-
-	sale, err := s.customersSvc.MakeSale(request.Context(), item)
+	item.ManagerID = principal.ID
 
+	sale, err := s.salesSvc.MakeSale(request.Context(), item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
+	s.respondJSON(writer, http.StatusOK, sale)
+}
 
-	data, err := json.Marshal(sale)
+func (s *Server) handleManagerRegistration(writer http.ResponseWriter, request *http.Request)  {
+	item := &managers.Manager{}
+	err := decodeJSON(request, item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
 
-
-}
-
-
-
-func (s *Server) handleManagerChangeProduct(writer http.ResponseWriter, request *http.Request)  {
-	var item *customers.Product
-	err := json.NewDecoder(request.Body).Decode(&item)
+	registration, err := s.managersSvc.RegisterManager(request.Context(), item)
 	if err != nil {
+		s.respondError(writer, request, err)
 		return
 	}
+	s.respondJSON(writer, http.StatusCreated, registration)
+}
 
-	result, err := s.customersSvc.SaveChangeProduct(request.Context(), item)
+func (s *Server) handleManagerGetToken(writer http.ResponseWriter, request *http.Request)  {
+	item := &auth.Auth{}
+	err := decodeJSON(request, item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	data, err := json.Marshal(result)
+	token, err := s.authSvc.TokenForManager(request.Context(), item.Phone, item.Password, s.clientIP(request))
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, token)
 }
 
-
-func (s *Server) handleManagerRegistration(writer http.ResponseWriter, request *http.Request)  {
-	var item *customers.Manager
-	err := json.NewDecoder(request.Body).Decode(&item)
+func (s *Server) handleManagerRefreshToken(writer http.ResponseWriter, request *http.Request) {
+	item := &auth.Refresh{}
+	err := decodeJSON(request, item)
 	if err != nil {
+		s.respondError(writer, request, err)
 		return
 	}
 
-
-	err = s.customersSvc.RegisterManager(request.Context(), item)
+	token, err := s.authSvc.RefreshManagerToken(request.Context(), item.RefreshToken)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
+	s.respondJSON(writer, http.StatusOK, token)
+}
 
-	token, err := s.customersSvc.TokenForManagerRegistr(request.Context(), item.Phone, item.Password)
+func (s *Server) handleCustomerRefreshToken(writer http.ResponseWriter, request *http.Request) {
+	item := &auth.Refresh{}
+	err := decodeJSON(request, item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
 
-
-	result := &customers.Token{
-		Token: token,
-	}
-
-	data, err := json.Marshal(result)
+	token, err := s.authSvc.RefreshCustomerToken(request.Context(), item.RefreshToken)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
-
-
-
+	s.respondJSON(writer, http.StatusOK, token)
 }
 
-
-func (s *Server) handleManagerGetToken(writer http.ResponseWriter, request *http.Request)  {
-	
-	var item *customers.Auth
-
-	err := json.NewDecoder(request.Body).Decode(&item)
-	if err != nil {
-		return
-	}
-
-	token, err := s.customersSvc.TokenForManager(request.Context(), item.Phone, item.Password)
+// handleLogout отзывает токен, с которым пришёл запрос (и покупателя, и менеджера).
+func (s *Server) handleLogout(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
-
-	}
-	
-	result := &customers.Token{
-		Token: token,
 	}
 
-	data, err := json.Marshal(result)
+	err = s.authSvc.RevokeToken(request.Context(), principal)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	writer.WriteHeader(http.StatusNoContent)
 }
 
-
-func (s *Server) handleCustomerMakePurchase(writer http.ResponseWriter, request *http.Request)  {
-	var item *customers.Purchase
-	err := json.NewDecoder(request.Body).Decode(&item)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-	purchase, err := s.customersSvc.MakePurchase(request.Context(), item)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	data, err := json.Marshal(purchase)
+// handleLogoutAll отзывает все токены пользователя.
+func (s *Server) handleLogoutAll(writer http.ResponseWriter, request *http.Request) {
+	principal, err := middleware.Principal(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
+	count, err := s.authSvc.RevokeAllTokens(request.Context(), principal)
 	if err != nil {
-		log.Print(err)
+		s.respondError(writer, request, err)
+		return
 	}
+	s.respondJSON(writer, http.StatusOK, map[string]int64{"revoked": count})
 }
 
-
-func (s *Server) handleCustomerGetPurchases(writer http.ResponseWriter, request *http.Request)  {
-	id, err := middleware.Authentication(request.Context())
+// handleCustomerMakePurchase оформляет заказ от имени аутентифицированного покупателя.
+// В теле передаются только товары и количества, цены берутся из каталога.
+func (s *Server) handleCustomerMakePurchase(writer http.ResponseWriter, request *http.Request)  {
+	principal, err := middleware.Principal(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	items, err := s.customersSvc.Purchases(request.Context(), id)
+	order := &purchases.Order{}
+	err = decodeJSON(request, order)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	data, err := json.Marshal(items)
+	purchase, err := s.purchasesSvc.MakePurchase(request.Context(), principal.ID, order)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusCreated, purchase)
 }
 
-func (s *Server) handleCustomerGetProducts(writer http.ResponseWriter, request *http.Request)  {
-	items, err := s.customersSvc.Products(request.Context())
+func (s *Server) handleCustomerGetPurchases(writer http.ResponseWriter, request *http.Request)  {
+	principal, err := middleware.Principal(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
+	id := principal.ID
 
-	data, err := json.Marshal(items)
+	items, err := s.purchasesSvc.Purchases(request.Context(), id)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, items)
 }
 
-
 func (s *Server) handleCustomerValidateToken(writer http.ResponseWriter, request *http.Request) {
-	var item *customers.Customer
-	
-
-	err := json.NewDecoder(request.Body).Decode(&item)
+	item := &customers.Customer{}
+	err := decodeJSON(request, item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
-	clientsID, err := s.customersSvc.AutenticateCustomer(request.Context(), item.Token)
-
+	clientsID, err := s.authSvc.AutenticateCustomer(request.Context(), item.Token)
 	if err != nil {
-		if err == customers.ErrNoSuchUser {
-			result := &MyStruct{
-				Status: "fail",
-				Reason: "not found",
-			}
-			
-			data, cerr := json.Marshal(result)
-			if cerr != nil {
-				log.Print(err)
-				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-
-			writer.Header().Set("Content-Type", "application/json")
-			writer.WriteHeader(http.StatusNotFound)
-			writer.Write(data)
-			return
-
-		} else if err == customers.ErrExpire {
-			result := &MyStruct{
-				Status: "fail",
-				Reason: "expired",
-			}
-			data, cerr := json.Marshal(result)
-			if cerr != nil {
-				log.Print(err)
-				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-
-			writer.Header().Set("Content-Type", "application/json")
-			writer.WriteHeader(http.StatusBadRequest)
-			writer.Write(data)
-			return
-		}
+		s.respondError(writer, request, err)
+		return
 	}
 
 	result := &MySecondStruct{
 		Status:     "ok",
 		CustomerID: clientsID,
 	}
-	data, cerr := json.Marshal(result)
-	if cerr != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, result)
 }
 
-
-
 func (s *Server) handleCustomerGetToken(writer http.ResponseWriter, request *http.Request) {
-	var item *customers.Customer
-
-	err := json.NewDecoder(request.Body).Decode(&item)
+	item := &customers.Customer{}
+	err := decodeJSON(request, item)
 	if err != nil {
+		s.respondError(writer, request, err)
 		return
 	}
 
-	token, err := s.customersSvc.TokenForCustomer(request.Context(), item.Login, item.Password)
+	token, err := s.authSvc.TokenForCustomer(request.Context(), item.Login, item.Password, s.clientIP(request))
 	if err != nil {
-
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
-
-	}
-	
-	result := &customers.Customer{
-		Token: token,
 	}
-
-	data, err := json.Marshal(result)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
-
+	s.respondJSON(writer, http.StatusOK, token)
 }
 
 func (s *Server) handleCustomerRegistration(writer http.ResponseWriter, request *http.Request) {
-	var item *customers.Registration
-	err := json.NewDecoder(request.Body).Decode(&item)
+	item := &customers.Registration{}
+	err := decodeJSON(request, item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
 	newCustomer, err := s.customersSvc.RegisterCustomer(request.Context(), item)
 	if err != nil {
-		if err == customers.ErrNotFound {
-			log.Print(err)
-			http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	data, err := json.Marshal(newCustomer)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, newCustomer)
 }
 
 func (s *Server) handleGetCustomerByID(writer http.ResponseWriter, request *http.Request) {
-	idParam, ok := mux.Vars(request)["id"]
-	if !ok {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	id, err := idParam(request, "id")
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
 	item, err := s.customersSvc.ByID(request.Context(), id)
-	if errors.Is(err, customers.ErrNotFound) {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	data, err := json.Marshal(item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, item)
 }
 
 func (s *Server) handleGetAllCustomers(writer http.ResponseWriter, request *http.Request) {
 	item, err := s.customersSvc.All(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
-
-	data, err := json.Marshal(item)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, item)
 }
 
 func (s *Server) handleGetAllActiveCustomers(writer http.ResponseWriter, request *http.Request) {
 	item, err := s.customersSvc.AllActive(request.Context())
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
-
-	data, err := json.Marshal(item)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, item)
 }
 
 func (s *Server) handleRemoveCustomerByID(writer http.ResponseWriter, request *http.Request) {
-	customerID, ok := mux.Vars(request)["id"]
-	if !ok {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	convID, err := strconv.ParseInt(customerID, 10, 64)
+	convID, err := idParam(request, "id")
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
 	removedCustomer, err := s.customersSvc.RemoveByID(request.Context(), convID)
 	if err != nil {
-		if err == customers.ErrNotFound {
-			log.Print(err)
-			http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
-	data, err := json.Marshal(removedCustomer)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, removedCustomer)
 }
 
 func (s *Server) handleBlockCustomerByID(writer http.ResponseWriter, request *http.Request) {
-	customerID, ok := mux.Vars(request)["id"]
-	if !ok {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	convID, err := strconv.ParseInt(customerID, 10, 64)
+	convID, err := idParam(request, "id")
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
 	blockedUser, err := s.customersSvc.BlockUser(request.Context(), convID)
 	if err != nil {
-		if err == customers.ErrNotFound {
-			log.Print(err)
-			http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	data, err := json.Marshal(blockedUser)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, blockedUser)
 }
 
 func (s *Server) handleUnblockCustomerByID(writer http.ResponseWriter, request *http.Request) {
-	customerID, ok := mux.Vars(request)["id"]
-	if !ok {
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	convID, err := strconv.ParseInt(customerID, 10, 64)
+	convID, err := idParam(request, "id")
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		s.respondError(writer, request, err)
 		return
 	}
 
 	unblockedUser, err := s.customersSvc.UnblockUser(request.Context(), convID)
 	if err != nil {
-		if err == customers.ErrNotFound {
-			log.Print(err)
-			http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.respondError(writer, request, err)
 		return
 	}
-	data, err := json.Marshal(unblockedUser)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	s.respondJSON(writer, http.StatusOK, unblockedUser)
 }
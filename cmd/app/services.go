@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// Сервисы, от которых зависит Server. Реализации лежат в pkg/* и
+// передаются в NewServer через dig (см. cmd/main.go).
+
+// AuthService выдаёт и проверяет токены, меняет и сбрасывает пароли,
+// управляет блокировками входа.
+type AuthService interface {
+	TokenForCustomer(ctx context.Context, phone string, password string, ip string) (*auth.Token, error)
+	TokenForManager(ctx context.Context, phone string, password string, ip string) (*auth.Token, error)
+	IssueToken(ctx context.Context, kind auth.Kind, ownerID int64) (*auth.Token, error)
+	RefreshCustomerToken(ctx context.Context, refreshToken string) (*auth.Token, error)
+	RefreshManagerToken(ctx context.Context, refreshToken string) (*auth.Token, error)
+	RevokeToken(ctx context.Context, principal *auth.Principal) error
+	RevokeAllTokens(ctx context.Context, principal *auth.Principal) (int64, error)
+	PrincipalByCustomerToken(ctx context.Context, token string) (*auth.Principal, error)
+	PrincipalByManagerToken(ctx context.Context, token string) (*auth.Principal, error)
+	AutenticateCustomer(ctx context.Context, token string) (int64, error)
+	HasAnyRole(ctx context.Context, roles ...string) bool
+	ChangePassword(ctx context.Context, principal *auth.Principal, change *auth.PasswordChange) (*auth.Token, error)
+	RequestCustomerReset(ctx context.Context, phone string) error
+	RequestManagerReset(ctx context.Context, phone string) error
+	ResetCustomerPassword(ctx context.Context, reset *auth.PasswordReset) (*auth.Token, error)
+	ResetManagerPassword(ctx context.Context, reset *auth.PasswordReset) (*auth.Token, error)
+	Lockouts(ctx context.Context) ([]*auth.Lockout, error)
+	Unlock(ctx context.Context, actorID int64, key string) error
+}
+
+// CustomersService управляет покупателями.
+type CustomersService interface {
+	RegisterCustomer(ctx context.Context, registration *customers.Registration) (*customers.Customer, error)
+	All(ctx context.Context) ([]*customers.Customer, error)
+	AllActive(ctx context.Context) ([]*customers.Customer, error)
+	ByID(ctx context.Context, id int64) (*customers.Customer, error)
+	RemoveByID(ctx context.Context, id int64) (*customers.Customer, error)
+	BlockUser(ctx context.Context, id int64) (*customers.Customer, error)
+	UnblockUser(ctx context.Context, id int64) (*customers.Customer, error)
+	RequestVerification(ctx context.Context, id int64) error
+	VerifyPhone(ctx context.Context, id int64, code string) (*customers.Customer, error)
+}
+
+// ManagersService управляет менеджерами и их подчинением.
+type ManagersService interface {
+	RegisterManager(ctx context.Context, item *managers.Manager) (*managers.Registration, error)
+	AcceptInvite(ctx context.Context, acceptance *managers.Acceptance) (int64, error)
+	Manager(ctx context.Context, id int64) (*managers.Manager, error)
+	Managers(ctx context.Context) ([]*managers.Manager, error)
+	Patch(ctx context.Context, id int64, patch *managers.Patch) (*managers.Manager, error)
+	SetRoles(ctx context.Context, id int64, roles []string) (*managers.Manager, error)
+	SetBoss(ctx context.Context, id int64, bossID *int64) (*managers.Manager, error)
+	Deactivate(ctx context.Context, id int64) (*managers.Manager, error)
+	Team(ctx context.Context, id int64) ([]*managers.TeamMember, error)
+	IsSubordinate(ctx context.Context, bossID int64, id int64) (bool, error)
+}
+
+// ProductsService управляет каталогом товаров.
+type ProductsService interface {
+	List(ctx context.Context, filter *products.Filter, cursor string) (*products.Page, error)
+	ByID(ctx context.Context, id int64) (*products.Product, error)
+	Create(ctx context.Context, item *products.Product) (*products.Product, error)
+	Replace(ctx context.Context, item *products.Product) (*products.Product, error)
+	Patch(ctx context.Context, id int64, patch *products.Patch) (*products.Product, error)
+	Remove(ctx context.Context, id int64) (*products.Product, error)
+}
+
+// SalesService оформляет продажи.
+type SalesService interface {
+	MakeSale(ctx context.Context, item *sales.MakeSale) (*sales.MakeSale, error)
+	Sale(ctx context.Context, id int64) (*sales.MakeSale, error)
+	Sales(ctx context.Context, filter *sales.SaleFilter) ([]*sales.MakeSale, error)
+	Cancel(ctx context.Context, id int64, managerID int64, cancellation *sales.Cancellation) (*sales.MakeSale, error)
+	Report(ctx context.Context, filter *sales.ReportFilter) (*sales.Report, error)
+	Leaderboard(ctx context.Context, from time.Time, to time.Time) (*sales.Leaderboard, error)
+}
+
+// PurchasesService оформляет покупки.
+type PurchasesService interface {
+	MakePurchase(ctx context.Context, customerID int64, order *purchases.Order) (*purchases.Purchase, error)
+	Purchases(ctx context.Context, customerID int64) ([]*purchases.Purchase, error)
+}
+
+// InventoryService ведёт журнал движений склада.
+type InventoryService interface {
+	Adjust(ctx context.Context, productID int64, adjustment *inventory.Adjustment) (*inventory.Movement, error)
+	History(ctx context.Context, productID int64, before int64, limit int) ([]*inventory.Movement, error)
+}
+
+// CartsService управляет корзинами покупателей.
+type CartsService interface {
+	Cart(ctx context.Context, customerID int64) (*carts.Cart, error)
+	AddItem(ctx context.Context, customerID int64, item *carts.Item) (*carts.Cart, error)
+	SetItem(ctx context.Context, customerID int64, item *carts.Item) (*carts.Cart, error)
+	RemoveItem(ctx context.Context, customerID int64, productID int64) (*carts.Cart, error)
+	Replace(ctx context.Context, customerID int64, items []*carts.Item) (*carts.Cart, error)
+	Clear(ctx context.Context, customerID int64) error
+	Checkout(ctx context.Context, customerID int64) (*purchases.Purchase, error)
+}
+
+// ReturnsService оформляет возвраты по продажам и покупкам.
+type ReturnsService interface {
+	Create(ctx context.Context, managerID int64, request *returns.Request) (*returns.Return, error)
+	Return(ctx context.Context, id int64) (*returns.Return, error)
+	Returns(ctx context.Context, filter *returns.Filter) ([]*returns.Return, error)
+}
+
+// IdempotencyService хранит ответы на запросы с Idempotency-Key
+// (подходит как middleware.IdempotencyStore).
+type IdempotencyService interface {
+	Begin(ctx context.Context, scope string, key string, fingerprint string) (*idempotency.Response, error)
+	Complete(ctx context.Context, scope string, key string, response *idempotency.Response) error
+	Release(ctx context.Context, scope string, key string) error
+}
+
+// AuditService отдаёт журнал аудита.
+type AuditService interface {
+	Events(ctx context.Context, filter *audit.Filter) ([]*audit.Event, error)
+}
@@ -1,4 +0,0 @@
--- DELETE FROM users;
-
-
-INSERT INTO users (name, phone, password, roles) VALUES ('vasya', '+992000000001', '$2a$10$n.1kcMvH9iRNkapI6wObS.xmw2GHzMN/dql2gPnJHZcYwkgjhLvVW', '{"MANAGER", "ADMIN"}');
\ No newline at end of file
@@ -1,13 +1,15 @@
 module github.com/Fanisabonu/http
 
-go 1.15
+go 1.16
 
 require (
 	github.com/gorilla/mux v1.8.0
+	github.com/jackc/pgconn v1.7.2
 	github.com/jackc/pgx/v4 v4.9.2
 	go.uber.org/dig v1.10.0
 	golang.org/x/crypto v0.0.0-20201208171446-5f87f3452ae9
 	golang.org/x/text v0.3.4 // indirect
 	// golang.org/x/tools v0.0.0-20191030062658-86caa796c7ab // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrInvalidFilter возвращается при некорректных параметрах списка событий.
+var ErrInvalidFilter = errors.New("invalid audit filter")
+
+// Ограничения размера страницы списка событий.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Kind - вид события.
+type Kind string
+
+const (
+	// KindLoginLockout - вход по телефону или с адреса заблокирован после неудачных попыток.
+	KindLoginLockout Kind = "login_lockout"
+	// KindLoginUnlock - администратор снял блокировку входа.
+	KindLoginUnlock Kind = "login_unlock"
+)
+
+// Event - событие журнала аудита. Subject - то, к чему относится событие
+// (например, ключ блокировки входа), ActorID - менеджер, который его вызвал.
+type Event struct {
+	ID      int64                  `json:"id"`
+	Kind    Kind                   `json:"kind"`
+	Subject string                 `json:"subject"`
+	ActorID *int64                 `json:"actor_id,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Created time.Time              `json:"created"`
+}
+
+// Filter - параметры списка событий. Пустой Kind - события всех видов.
+type Filter struct {
+	Kind   Kind
+	Before int64
+	Limit  int
+}
+
+// EventRepo хранит журнал аудита.
+type EventRepo interface {
+	// Add сохраняет событие и заполняет его ID и Created.
+	Add(ctx context.Context, item *Event) error
+	// List возвращает до filter.Limit событий с id < filter.Before
+	// (0 - с последнего), от новых к старым.
+	List(ctx context.Context, filter *Filter) ([]*Event, error)
+}
+
+// Service ведёт журнал аудита событий безопасности.
+type Service struct {
+	events EventRepo
+}
+
+// NewService создаёт сервис.
+func NewService(events EventRepo) *Service {
+	return &Service{events: events}
+}
+
+// Record записывает событие в журнал и в лог.
+func (s *Service) Record(ctx context.Context, item *Event) error {
+	log.Printf("audit: %s %s %v", item.Kind, item.Subject, item.Details)
+	err := s.events.Add(ctx, item)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// Events возвращает страницу событий от новых к старым. Для следующей
+// страницы нужно передать Before = id последнего полученного события.
+func (s *Service) Events(ctx context.Context, filter *Filter) ([]*Event, error) {
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxLimit || filter.Before < 0 {
+		return nil, ErrInvalidFilter
+	}
+
+	items, err := s.events.List(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrTooManyAttempts возвращается, когда вход или запрос кода сброса временно
+// запрещён после слишком частых попыток.
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+// ErrNoLockout возвращается, когда по ключу нет неудачных попыток входа.
+var ErrNoLockout = errors.New("lockout not found")
+
+// LockedError сообщает, через сколько можно повторить вход.
+// errors.Is(err, ErrTooManyAttempts) для неё истинно.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrTooManyAttempts.
+func (e *LockedError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Auditor записывает события в журнал аудита (см. audit.Service).
+type Auditor interface {
+	Record(ctx context.Context, item *audit.Event) error
+}
+
+// Префиксы ключей: попытки входа с IP-адреса и запросы кодов сброса пароля.
+// Остальные ключи - попытки входа по телефону.
+const (
+	ipKeyPrefix    = "ip:"
+	resetKeyPrefix = "reset:"
+)
+
+func phoneKey(kind Kind, phone string) string {
+	return string(kind) + ":" + phone
+}
+
+// checkAttempts возвращает *LockedError, если вход по какому-то из keys запрещён.
+func (s *Service) checkAttempts(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	blocked, err := s.attempts.Blocked(ctx, keys)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	if blocked > 0 {
+		return &LockedError{RetryAfter: blocked}
+	}
+	return nil
+}
+
+// countAttempt засчитывает попытку входа по каждому из keys до проверки
+// пароля и сразу запрещает вход так, как если бы она оказалась неудачной
+// (см. lockFor). Поэтому параллельные запросы не обходят ограничения:
+// каждый из них получает свой номер попытки, а следующие ждут запрета.
+// Счётчики меняются в одной транзакции: если вход по какому-то ключу уже
+// запрещён, попытка не засчитывается ни по одному ключу и возвращается
+// *LockedError. Возвращает число попыток подряд по каждому ключу.
+func (s *Service) countAttempt(ctx context.Context, keys []string) ([]int, error) {
+	failures := make([]int, len(keys))
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		for i, key := range keys {
+			count, blocked, err := s.attempts.Attempt(ctx, key, s.loginLockout)
+			if err != nil {
+				return err
+			}
+			if blocked > 0 {
+				return &LockedError{RetryAfter: blocked}
+			}
+			failures[i] = count
+
+			duration := s.lockFor(key, count)
+			if duration > 0 {
+				err = s.attempts.Lock(ctx, key, duration)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		return nil, err
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return failures, nil
+}
+
+// lockFor возвращает, на сколько запрещается вход по ключу после failures
+// неудач подряд: по телефону - на время, растущее вдвое с каждой неудачей,
+// а после исчерпания попыток - на loginLockout. С IP-адреса входят многие
+// пользователи (NAT, прокси), поэтому адрес блокируется только после
+// loginIPAttempts неудач, без задержек до этого.
+func (s *Service) lockFor(key string, failures int) time.Duration {
+	if strings.HasPrefix(key, ipKeyPrefix) {
+		if failures >= s.loginIPAttempts {
+			return s.loginLockout
+		}
+		return 0
+	}
+	if failures >= s.loginAttempts {
+		return s.loginLockout
+	}
+	return s.backoff(failures)
+}
+
+// failAttempt записывает в журнал аудита блокировку ключей, по которым
+// неудачная попытка исчерпала лимит. Сама попытка уже засчитана в countAttempt.
+// О блокировке сообщается один раз, а не при каждой попытке после неё.
+func (s *Service) failAttempt(ctx context.Context, keys []string, failures []int) {
+	for i, key := range keys {
+		limit := s.loginAttempts
+		if strings.HasPrefix(key, ipKeyPrefix) {
+			limit = s.loginIPAttempts
+		}
+		if failures[i] != limit {
+			continue
+		}
+		_ = s.auditor.Record(ctx, &audit.Event{
+			Kind:    audit.KindLoginLockout,
+			Subject: key,
+			Details: map[string]interface{}{"failures": failures[i], "seconds": int(s.loginLockout.Seconds())},
+		})
+	}
+}
+
+// passAttempt отменяет запрет, поставленный countAttempt для удачной попытки:
+// счётчик телефона сбрасывается, а с адреса только снимается эта попытка,
+// чтобы успешный вход в свой аккаунт не обнулял перебор чужих паролей.
+// Ошибки хранилища только логируются, чтобы не менять ответ на попытку входа.
+func (s *Service) passAttempt(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		var err error
+		if strings.HasPrefix(key, ipKeyPrefix) {
+			err = s.attempts.Forgive(ctx, key)
+		} else {
+			err = s.attempts.Reset(ctx, key)
+		}
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Print(err)
+		}
+	}
+}
+
+// backoff возвращает запрет входа после failures неудач подряд:
+// loginBackoff, удвоенный failures-1 раз, но не больше loginBackoffMax.
+func (s *Service) backoff(failures int) time.Duration {
+	delay := float64(s.loginBackoff) * math.Pow(2, float64(failures-1))
+	if delay >= float64(s.loginBackoffMax) {
+		return s.loginBackoffMax
+	}
+	return time.Duration(delay)
+}
+
+// Lockouts возвращает телефоны и адреса, вход с которых сейчас запрещён.
+func (s *Service) Lockouts(ctx context.Context) ([]*Lockout, error) {
+	items, err := s.attempts.Locked(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// Unlock снимает запрет входа по ключу и сбрасывает счётчик неудач.
+// Разблокировка записывается в журнал аудита от имени менеджера actorID.
+// Если по ключу нет неудачных попыток, возвращается ErrNoLockout.
+func (s *Service) Unlock(ctx context.Context, actorID int64, key string) error {
+	err := s.attempts.Reset(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNoLockout
+	}
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return s.auditor.Record(ctx, &audit.Event{Kind: audit.KindLoginUnlock, Subject: key, ActorID: &actorID})
+}
+
+// PurgeStaleAttempts удаляет неудачные попытки входа и запросы кодов сброса,
+// которые уже не учитываются.
+func (s *Service) PurgeStaleAttempts(ctx context.Context) (int64, error) {
+	window := s.loginLockout
+	if s.resetWindow > window {
+		window = s.resetWindow
+	}
+	count, err := s.attempts.RemoveStale(ctx, window)
+	if err != nil {
+		log.Print(err)
+		return count, ErrInternal
+	}
+	return count, nil
+}
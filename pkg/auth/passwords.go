@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/otp"
+	"github.com/Fanisabonu/http/pkg/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordChange - запрос на смену пароля аутентифицированным пользователем.
+type PasswordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// ResetRequest - запрос кода сброса пароля.
+type ResetRequest struct {
+	Phone string `json:"phone"`
+}
+
+// PasswordReset - новый пароль вместе с кодом, отправленным на телефон.
+type PasswordReset struct {
+	Phone    string `json:"phone"`
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// ChangePassword меняет пароль пользователя principal, отзывает все его токены
+// (в том числе текущий) и выдаёт новую пару.
+// Если старый пароль неверен, возвращается ErrInvalidPassword,
+// если новый не соответствует политике - passwords.ErrWeakPassword.
+func (s *Service) ChangePassword(ctx context.Context, principal *Principal, change *PasswordChange) (*Token, error) {
+	credentials, err := s.credentials.CredentialsByID(ctx, principal.Kind, principal.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoSuchUser
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(credentials.PasswordHash), []byte(change.OldPassword))
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	var result *Token
+	err = s.setPassword(ctx, principal.Kind, principal.ID, change.NewPassword, func(ctx context.Context) error {
+		result, err = s.issueToken(ctx, principal.Kind, principal.ID)
+		return err
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return result, nil
+}
+
+// RequestCustomerReset отправляет покупателю код сброса пароля.
+func (s *Service) RequestCustomerReset(ctx context.Context, phone string) error {
+	return s.requestReset(ctx, KindCustomer, phone)
+}
+
+// RequestManagerReset отправляет менеджеру код сброса пароля.
+func (s *Service) RequestManagerReset(ctx context.Context, phone string) error {
+	return s.requestReset(ctx, KindManager, phone)
+}
+
+// ResetCustomerPassword задаёт покупателю новый пароль по коду и выдаёт пару токенов.
+func (s *Service) ResetCustomerPassword(ctx context.Context, reset *PasswordReset) (*Token, error) {
+	return s.resetPassword(ctx, KindCustomer, reset)
+}
+
+// ResetManagerPassword задаёт менеджеру новый пароль по коду и выдаёт пару токенов.
+func (s *Service) ResetManagerPassword(ctx context.Context, reset *PasswordReset) (*Token, error) {
+	return s.resetPassword(ctx, KindManager, reset)
+}
+
+// RevokeCustomerTokens отзывает все токены покупателя, например после того,
+// как администратор сменил ему пароль.
+func (s *Service) RevokeCustomerTokens(ctx context.Context, id int64) error {
+	_, err := s.RevokeAllTokens(ctx, &Principal{Kind: KindCustomer, ID: id})
+	return err
+}
+
+// requestReset сохраняет новый код сброса и отправляет его на телефон.
+// Чтобы по ответу нельзя было узнать, зарегистрирован ли телефон,
+// для некорректных телефонов, неизвестных и заблокированных пользователей
+// ошибка не возвращается. Частые запросы на один телефон получают *LockedError.
+func (s *Service) requestReset(ctx context.Context, kind Kind, phone string) error {
+	phone, err := s.normalizer.Normalize(phone)
+	if err != nil {
+		return nil
+	}
+	err = s.throttleReset(ctx, resetKeyPrefix+phoneKey(kind, phone))
+	if err != nil {
+		return err
+	}
+
+	credentials, err := s.credentials.CredentialsByPhone(ctx, kind, phone)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	if !credentials.Active {
+		return nil
+	}
+
+	code, err := otp.Generate()
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	err = s.resets.Save(ctx, kind, credentials.ID, otp.Hash(code), s.resetTTL)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+
+	err = s.notifier.Notify(ctx, &notifications.Message{
+		Phone: phone,
+		Text:  fmt.Sprintf("Код для сброса пароля: %s. Он действует %d мин.", code, int(s.resetTTL.Minutes())),
+	})
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// throttleReset засчитывает запрос кода сброса по ключу телефона: следующий
+// код можно запросить через resetCooldown, а после resetRequests запросов -
+// только через resetWindow. Ограничение действует и для незарегистрированных
+// телефонов, чтобы по нему нельзя было их отличить.
+func (s *Service) throttleReset(ctx context.Context, key string) error {
+	err := s.checkAttempts(ctx, []string{key})
+	if err != nil {
+		return err
+	}
+
+	requests, err := s.attempts.Fail(ctx, key, s.resetWindow)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	duration := s.resetCooldown
+	if requests >= s.resetRequests {
+		duration = s.resetWindow
+	}
+	err = s.attempts.Lock(ctx, key, duration)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// resetPassword проверяет код и меняет пароль. После resetAttempts неверных
+// попыток код удаляется, и нужно запросить новый.
+func (s *Service) resetPassword(ctx context.Context, kind Kind, reset *PasswordReset) (*Token, error) {
+	err := s.policy.Check(reset.Password)
+	if err != nil {
+		return nil, err
+	}
+	phone, err := s.normalizer.Normalize(reset.Phone)
+	if err != nil {
+		return nil, ErrInvalidCode
+	}
+
+	var result *Token
+	wrongCode := false
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		credentials, err := s.credentials.CredentialsByPhone(ctx, kind, phone)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrInvalidCode
+		}
+		if err != nil {
+			return err
+		}
+		if !credentials.Active {
+			return ErrInvalidCode
+		}
+
+		code, err := s.resets.ByOwner(ctx, kind, credentials.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrInvalidCode
+		}
+		if err != nil {
+			return err
+		}
+		if code.Expired {
+			return ErrInvalidCode
+		}
+		if code.Hash != otp.Hash(reset.Code) {
+			// неудачная попытка должна сохраниться, поэтому транзакция
+			// не откатывается, а ErrInvalidCode возвращается после неё
+			wrongCode = true
+			if code.Attempts+1 >= s.resetAttempts {
+				return s.resets.Remove(ctx, kind, credentials.ID)
+			}
+			return s.resets.Fail(ctx, kind, credentials.ID)
+		}
+
+		return s.setPassword(ctx, kind, credentials.ID, reset.Password, func(ctx context.Context) error {
+			result, err = s.issueToken(ctx, kind, credentials.ID)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	if wrongCode {
+		return nil, ErrInvalidCode
+	}
+	return result, nil
+}
+
+// setPassword проверяет пароль по политике, сохраняет его хэш, удаляет код
+// сброса и все токены пользователя, после чего вызывает then в той же транзакции.
+func (s *Service) setPassword(ctx context.Context, kind Kind, id int64, password string, then func(ctx context.Context) error) error {
+	err := s.policy.Check(password)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		err := s.credentials.SetPassword(ctx, kind, id, string(hash))
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNoSuchUser
+		}
+		if err != nil {
+			return err
+		}
+		err = s.resets.Remove(ctx, kind, id)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		_, err = s.tokens.RemoveByOwner(ctx, kind, id)
+		if err != nil {
+			return err
+		}
+		return then(ctx)
+	})
+}
+
+// PurgeExpiredResetCodes удаляет истёкшие коды сброса пароля.
+func (s *Service) PurgeExpiredResetCodes(ctx context.Context) (int64, error) {
+	count, err := s.resets.RemoveExpired(ctx)
+	if err != nil {
+		log.Print(err)
+		return count, ErrInternal
+	}
+	return count, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Kind - тип аутентифицированного пользователя.
+type Kind string
+
+const (
+	// KindCustomer - покупатель (таблица customers).
+	KindCustomer Kind = "customer"
+	// KindManager - менеджер (таблица users).
+	KindManager Kind = "manager"
+)
+
+// Principal описывает того, кто выполняет запрос.
+type Principal struct {
+	ID        int64     `json:"id"`
+	Kind      Kind      `json:"kind"`
+	Roles     []string  `json:"roles"`
+	TokenID   int64     `json:"token_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasAnyRole проверяет, есть ли у пользователя хотя бы одна из ролей.
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	for _, value := range p.Roles {
+		for _, role := range roles {
+			if value == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type contextKey struct {
+	name string
+}
+
+func (c *contextKey) String() string {
+	return c.name
+}
+
+var principalContextKey = &contextKey{"principal context"}
+
+// NewContext возвращает копию ctx с principal внутри.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+// FromContext достаёт principal из контекста.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Credentials - данные для проверки пароля при входе.
+type Credentials struct {
+	ID           int64
+	PasswordHash string
+	Active       bool
+}
+
+// TokenRecord - сохранённый токен вместе с данными владельца.
+// Expired и RefreshExpired вычисляются по часам хранилища.
+type TokenRecord struct {
+	ID             int64
+	Kind           Kind
+	OwnerID        int64
+	Roles          []string
+	Expire         time.Time
+	Expired        bool
+	RefreshExpired bool
+}
+
+// NewTokenRecord - токен, который нужно сохранить. Сами токены в хранилище
+// не попадают, только их хэши.
+type NewTokenRecord struct {
+	Kind        Kind
+	OwnerID     int64
+	Hash        string
+	RefreshHash string
+	TTL         time.Duration
+	RefreshTTL  time.Duration
+}
+
+// ResetCode - одноразовый код сброса пароля. Хранится только хэш кода,
+// Expired вычисляется по часам хранилища.
+type ResetCode struct {
+	Kind     Kind
+	OwnerID  int64
+	Hash     string
+	Attempts int
+	Expired  bool
+}
+
+// CredentialRepo ищет и меняет учётные данные покупателей и менеджеров.
+// Если пользователь не найден, возвращает storage.ErrNotFound.
+type CredentialRepo interface {
+	CredentialsByPhone(ctx context.Context, kind Kind, phone string) (*Credentials, error)
+	CredentialsByID(ctx context.Context, kind Kind, id int64) (*Credentials, error)
+	// SetPassword меняет хэш пароля; у менеджера заодно гасит приглашение.
+	SetPassword(ctx context.Context, kind Kind, id int64, hash string) error
+}
+
+// ResetRepo хранит коды сброса пароля, не больше одного на пользователя.
+// Если код не найден, возвращает storage.ErrNotFound.
+type ResetRepo interface {
+	// Save сохраняет код, заменяя предыдущий код пользователя.
+	Save(ctx context.Context, kind Kind, ownerID int64, hash string, ttl time.Duration) error
+	// ByOwner ищет код пользователя. Внутри транзакции запись блокируется до её конца.
+	ByOwner(ctx context.Context, kind Kind, ownerID int64) (*ResetCode, error)
+	// Fail увеличивает счётчик неудачных попыток ввода кода.
+	Fail(ctx context.Context, kind Kind, ownerID int64) error
+	Remove(ctx context.Context, kind Kind, ownerID int64) error
+	RemoveExpired(ctx context.Context) (int64, error)
+}
+
+// TokenRepo хранит токены покупателей и менеджеров.
+// Если токен не найден, возвращает storage.ErrNotFound.
+type TokenRepo interface {
+	// Create сохраняет токен и возвращает время его истечения.
+	Create(ctx context.Context, item *NewTokenRecord) (time.Time, error)
+	// ByToken ищет токен по хэшу; токены заблокированных владельцев не находятся.
+	ByToken(ctx context.Context, kind Kind, hash string) (*TokenRecord, error)
+	// ByRefreshToken ищет токен по хэшу refresh-токена. Внутри транзакции
+	// запись блокируется до её конца.
+	ByRefreshToken(ctx context.Context, kind Kind, hash string) (*TokenRecord, error)
+	Remove(ctx context.Context, kind Kind, id int64, ownerID int64) error
+	RemoveByOwner(ctx context.Context, kind Kind, ownerID int64) (int64, error)
+	// RemoveExpired удаляет токены, у которых истёк и refresh-токен.
+	RemoveExpired(ctx context.Context) (int64, error)
+}
+
+// Lockout - неудачные попытки входа по ключу: "customer:<телефон>",
+// "manager:<телефон>" или "ip:<адрес>". Пока не наступил LockedUntil,
+// вход по ключу запрещён. Ключи "reset:customer:<телефон>" и
+// "reset:manager:<телефон>" считают запросы кодов сброса пароля.
+type Lockout struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// AttemptRepo хранит неудачные попытки входа.
+// Если ключа нет, возвращает storage.ErrNotFound.
+type AttemptRepo interface {
+	// Blocked возвращает, сколько ещё действует самый долгий из запретов
+	// входа по keys; 0 - вход разрешён.
+	Blocked(ctx context.Context, keys []string) (time.Duration, error)
+	// Fail засчитывает неудачную попытку и возвращает число неудач подряд.
+	// Если предыдущая неудача была раньше window, счёт начинается заново.
+	Fail(ctx context.Context, key string, window time.Duration) (int, error)
+	// Attempt засчитывает попытку входа и возвращает число попыток подряд.
+	// Если вход по ключу запрещён, попытка не засчитывается, а вторым
+	// значением возвращается, сколько ещё действует запрет.
+	// Если предыдущая попытка была раньше window, счёт начинается заново.
+	Attempt(ctx context.Context, key string, window time.Duration) (int, time.Duration, error)
+	// Forgive не засчитывает последнюю попытку: уменьшает счётчик и снимает запрет.
+	Forgive(ctx context.Context, key string) error
+	// Lock запрещает вход по ключу на duration.
+	Lock(ctx context.Context, key string, duration time.Duration) error
+	// Reset забывает неудачные попытки по ключу и снимает запрет.
+	Reset(ctx context.Context, key string) error
+	// Locked возвращает ключи, вход по которым сейчас запрещён, начиная с самых долгих запретов.
+	Locked(ctx context.Context) ([]*Lockout, error)
+	// RemoveStale удаляет незаблокированные ключи без неудач за последние window.
+	RemoveStale(ctx context.Context, window time.Duration) (int64, error)
+}
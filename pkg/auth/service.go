@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/passwords"
+	"github.com/Fanisabonu/http/pkg/phones"
+	"github.com/Fanisabonu/http/pkg/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoSuchUser возвращается, когда не найден пользователь с таким телефоном.
+var ErrNoSuchUser = errors.New("no such user")
+
+// ErrInvalidPassword возвращается, когда пароль введён неверно. При входе
+// она же возвращается для незарегистрированного телефона.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// ErrInvalidToken возвращается, когда токен введён неверно
+var ErrInvalidToken = errors.New("Invalid Token")
+
+// ErrExpire возвращается, когда время токена истекло.
+var ErrExpire = errors.New("Token Expired")
+
+// ErrInvalidCode возвращается, когда код сброса пароля неверный,
+// истёк или исчерпаны попытки его ввода.
+var ErrInvalidCode = errors.New("invalid or expired reset code")
+
+// Auth - запрос на вход по телефону и паролю.
+type Auth struct {
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+// Token - пара токенов, выдаваемая при входе и при обновлении.
+type Token struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expire       time.Time `json:"expire,omitempty"`
+}
+
+// Refresh - запрос на обновление пары токенов.
+type Refresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Service выдаёт, обновляет и проверяет токены покупателей и менеджеров,
+// а также меняет и сбрасывает их пароли.
+type Service struct {
+	tx              storage.Transactor
+	tokens          TokenRepo
+	credentials     CredentialRepo
+	resets          ResetRepo
+	attempts        AttemptRepo
+	policy          *passwords.Policy
+	normalizer      *phones.Normalizer
+	notifier        notifications.Notifier
+	auditor         Auditor
+	tokenTTL        time.Duration
+	refreshTTL      time.Duration
+	bcryptCost      int
+	resetTTL        time.Duration
+	resetAttempts   int
+	resetCooldown   time.Duration
+	resetRequests   int
+	resetWindow     time.Duration
+	loginAttempts   int
+	loginIPAttempts int
+	loginBackoff    time.Duration
+	loginBackoffMax time.Duration
+	loginLockout    time.Duration
+	// dummyHash сравнивается с паролем при входе незарегистрированного
+	// пользователя, чтобы ответ занимал столько же времени
+	dummyHash []byte
+}
+
+// NewService создаёт сервис и регистрирует в lc фоновую очистку токенов.
+func NewService(tx storage.Transactor, tokens TokenRepo, credentials CredentialRepo, resets ResetRepo, attempts AttemptRepo, policy *passwords.Policy, normalizer *phones.Normalizer, notifier notifications.Notifier, auditor Auditor, cfg *config.Config, lc *lifecycle.Lifecycle) *Service {
+	s := &Service{
+		tx:              tx,
+		tokens:          tokens,
+		credentials:     credentials,
+		resets:          resets,
+		attempts:        attempts,
+		policy:          policy,
+		normalizer:      normalizer,
+		notifier:        notifier,
+		auditor:         auditor,
+		tokenTTL:        cfg.TokenTTL,
+		refreshTTL:      cfg.RefreshTTL,
+		bcryptCost:      cfg.BcryptCost,
+		resetTTL:        cfg.ResetCodeTTL,
+		resetAttempts:   cfg.ResetCodeAttempts,
+		resetCooldown:   cfg.ResetCodeCooldown,
+		resetRequests:   cfg.ResetCodeMaxRequests,
+		resetWindow:     cfg.ResetCodeWindow,
+		loginAttempts:   cfg.LoginMaxAttempts,
+		loginIPAttempts: cfg.LoginIPMaxAttempts,
+		loginBackoff:    cfg.LoginBackoffBase,
+		loginBackoffMax: cfg.LoginBackoffMax,
+		loginLockout:    cfg.LoginLockout,
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), cfg.BcryptCost)
+	if err != nil {
+		log.Print(err)
+	}
+	s.dummyHash = hash
+	lc.Append(s.tokenPurgerHook(cfg.TokenPurgeInterval))
+	return s
+}
+
+// HasAnyRole проверяет, есть ли у аутентифицированного менеджера
+// хотя бы одна из ролей. Подходит как middleware.HasAnyRoleFunc.
+// Роли загружаются из users.roles при аутентификации.
+func (s *Service) HasAnyRole(ctx context.Context, roles ...string) bool {
+	principal, ok := FromContext(ctx)
+	if !ok || principal.Kind != KindManager {
+		return false
+	}
+	return principal.HasAnyRole(roles...)
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{ErrNoSuchUser, ErrInvalidPassword, ErrInvalidToken, ErrExpire, ErrInvalidCode, passwords.ErrWeakPassword, ErrInternal} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
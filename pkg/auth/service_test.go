@@ -0,0 +1,315 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+const (
+	phone    = "+992901234567"
+	password = "secret-password-42"
+)
+
+// codePattern находит код сброса в тексте сообщения.
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+// retryAfter возвращает задержку из *auth.LockedError или ноль для других ошибок.
+func retryAfter(err error) time.Duration {
+	var locked *auth.LockedError
+	if errors.As(err, &locked) {
+		return locked.RetryAfter
+	}
+	return 0
+}
+
+func auditEvents(t *testing.T, env *memtest.Env, kind audit.Kind) []*audit.Event {
+	t.Helper()
+	items, err := env.Audit.Events(context.Background(), &audit.Filter{Kind: kind})
+	if err != nil {
+		t.Fatalf("audit events: %v", err)
+	}
+	return items
+}
+
+func TestTokenForCustomer(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	customer := env.Customer(t, phone, password)
+
+	token, err := env.Auth.TokenForCustomer(ctx, phone, password, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("TokenForCustomer: %v", err)
+	}
+	principal, err := env.Auth.PrincipalByCustomerToken(ctx, token.Token)
+	if err != nil || principal.Kind != auth.KindCustomer || principal.ID != customer.ID {
+		t.Errorf("principal = %+v, %v; want customer %d", principal, err, customer.ID)
+	}
+
+	_, err = env.Auth.TokenForCustomer(ctx, phone, "wrong", "10.0.0.2")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Errorf("wrong password: err = %v, want ErrInvalidPassword", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, "+992901234568", password, "10.0.0.1")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Errorf("unknown phone: err = %v, want ErrInvalidPassword", err)
+	}
+
+	// токен перестаёт действовать через TokenTTL по часам хранилища
+	env.Advance(env.Config.TokenTTL)
+	_, err = env.Auth.PrincipalByCustomerToken(ctx, token.Token)
+	if !errors.Is(err, auth.ErrExpire) {
+		t.Errorf("expired token: err = %v, want ErrExpire", err)
+	}
+}
+
+func TestResetCustomerPassword(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	env.Customer(t, phone, password)
+	old, err := env.Auth.TokenForCustomer(ctx, phone, password, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("TokenForCustomer: %v", err)
+	}
+
+	err = env.Auth.RequestCustomerReset(ctx, phone)
+	if err != nil || len(env.Notifier.Messages) != 1 {
+		t.Fatalf("RequestCustomerReset: %v, messages %d", err, len(env.Notifier.Messages))
+	}
+	code := codePattern.FindString(env.Notifier.Messages[0].Text)
+
+	_, err = env.Auth.ResetCustomerPassword(ctx, &auth.PasswordReset{Phone: phone, Code: "000000", Password: "new-password-42"})
+	if !errors.Is(err, auth.ErrInvalidCode) {
+		t.Fatalf("wrong code: err = %v, want ErrInvalidCode", err)
+	}
+	_, err = env.Auth.ResetCustomerPassword(ctx, &auth.PasswordReset{Phone: phone, Code: code, Password: "new-password-42"})
+	if err != nil {
+		t.Fatalf("ResetCustomerPassword: %v", err)
+	}
+
+	// сброс отзывает прежние токены, а код нельзя использовать повторно
+	_, err = env.Auth.PrincipalByCustomerToken(ctx, old.Token)
+	if err == nil {
+		t.Error("old token is still valid")
+	}
+	_, err = env.Auth.ResetCustomerPassword(ctx, &auth.PasswordReset{Phone: phone, Code: code, Password: "other-password-42"})
+	if !errors.Is(err, auth.ErrInvalidCode) {
+		t.Errorf("reused code: err = %v, want ErrInvalidCode", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, "new-password-42", "10.0.0.1")
+	if err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+
+	// для незарегистрированного телефона ошибка не возвращается и код не отправляется
+	err = env.Auth.RequestCustomerReset(ctx, "+992901234568")
+	if err != nil || len(env.Notifier.Messages) != 1 {
+		t.Errorf("unknown phone: %v, messages %d", err, len(env.Notifier.Messages))
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	env := memtest.New()
+	env.Customer(t, phone, password)
+	ctx := context.Background()
+
+	_, err := env.Auth.TokenForCustomer(ctx, phone, "wrong", "")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Fatalf("err = %v, want ErrInvalidPassword", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if retry := retryAfter(err); retry != time.Second {
+		t.Fatalf("err = %v, want retry after 1s", err)
+	}
+
+	env.Advance(time.Second)
+	_, err = env.Auth.TokenForCustomer(ctx, phone, "wrong", "")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Fatalf("err = %v, want ErrInvalidPassword", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if retry := retryAfter(err); retry != 2*time.Second {
+		t.Fatalf("err = %v, want retry after 2s", err)
+	}
+
+	// успешный вход сбрасывает счётчик телефона
+	env.Advance(2 * time.Second)
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if err != nil {
+		t.Fatalf("TokenForCustomer: %v", err)
+	}
+	_, _ = env.Auth.TokenForCustomer(ctx, phone, "wrong", "")
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if retry := retryAfter(err); retry != time.Second {
+		t.Errorf("err = %v, want retry after 1s", err)
+	}
+}
+
+func TestLoginConcurrent(t *testing.T) {
+	env := memtest.New()
+	env.Customer(t, phone, password)
+	ctx := context.Background()
+
+	// попытка засчитывается до проверки пароля, поэтому из одновременных
+	// запросов пароль проверяется только у первого, остальные ждут запрета
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.Auth.TokenForCustomer(ctx, phone, "wrong", "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	invalid := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, auth.ErrInvalidPassword):
+			invalid++
+		case retryAfter(err) == 0:
+			t.Errorf("err = %v, want ErrInvalidPassword or a lockout", err)
+		}
+	}
+	if invalid != 1 {
+		t.Errorf("passwords checked = %d, want 1", invalid)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	env := memtest.New()
+	cfg := env.Config
+	env.Customer(t, phone, password)
+	ctx := context.Background()
+
+	for i := 0; i < cfg.LoginMaxAttempts; i++ {
+		_, err := env.Auth.TokenForCustomer(ctx, phone, "wrong", "")
+		if !errors.Is(err, auth.ErrInvalidPassword) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidPassword", i+1, err)
+		}
+		env.Advance(cfg.LoginBackoffMax)
+	}
+	_, err := env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if retry := retryAfter(err); retry != cfg.LoginLockout-cfg.LoginBackoffMax {
+		t.Fatalf("err = %v, want lockout", err)
+	}
+	events := auditEvents(t, env, audit.KindLoginLockout)
+	if len(events) != 1 || events[0].Subject != "customer:"+phone {
+		t.Fatalf("lockout events = %+v, want one for the phone", events)
+	}
+
+	lockouts, err := env.Auth.Lockouts(ctx)
+	if err != nil || len(lockouts) != 1 {
+		t.Fatalf("Lockouts = %v, %v; want one", lockouts, err)
+	}
+	err = env.Auth.Unlock(ctx, 7, lockouts[0].Key)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "")
+	if err != nil {
+		t.Errorf("after unlock: %v", err)
+	}
+	events = auditEvents(t, env, audit.KindLoginUnlock)
+	if len(events) != 1 || events[0].ActorID == nil || *events[0].ActorID != 7 {
+		t.Errorf("unlock events = %+v, want one by manager 7", events)
+	}
+
+	err = env.Auth.Unlock(ctx, 7, lockouts[0].Key)
+	if !errors.Is(err, auth.ErrNoLockout) {
+		t.Errorf("second unlock: err = %v, want ErrNoLockout", err)
+	}
+}
+
+func TestLoginIPLimit(t *testing.T) {
+	env := memtest.New(func(cfg *config.Config) {
+		cfg.LoginIPMaxAttempts = 3
+	})
+	cfg := env.Config
+	env.Customer(t, phone, password)
+	ctx := context.Background()
+	others := []string{"+992901234561", "+992901234562", "+992901234563"}
+
+	// до исчерпания попыток адрес не блокируется, даже на время
+	for _, other := range others[:2] {
+		_, err := env.Auth.TokenForCustomer(ctx, other, password, "10.0.0.1")
+		if !errors.Is(err, auth.ErrInvalidPassword) {
+			t.Fatalf("err = %v, want ErrInvalidPassword", err)
+		}
+	}
+	_, err := env.Auth.TokenForCustomer(ctx, phone, password, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("below the IP limit: %v", err)
+	}
+
+	_, err = env.Auth.TokenForCustomer(ctx, others[2], password, "10.0.0.1")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Fatalf("err = %v, want ErrInvalidPassword", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "10.0.0.1")
+	if retry := retryAfter(err); retry != cfg.LoginLockout {
+		t.Errorf("at the IP limit: err = %v, want lockout", err)
+	}
+	_, err = env.Auth.TokenForCustomer(ctx, phone, password, "10.0.0.2")
+	if err != nil {
+		t.Errorf("other address: %v", err)
+	}
+	events := auditEvents(t, env, audit.KindLoginLockout)
+	if len(events) != 1 || events[0].Subject != "ip:10.0.0.1" {
+		t.Errorf("lockout events = %+v, want one for the address", events)
+	}
+}
+
+func TestRequestResetThrottle(t *testing.T) {
+	env := memtest.New(func(cfg *config.Config) {
+		cfg.ResetCodeMaxRequests = 2
+	})
+	cfg := env.Config
+	env.Customer(t, phone, password)
+	ctx := context.Background()
+
+	err := env.Auth.RequestCustomerReset(ctx, phone)
+	if err != nil || len(env.Notifier.Messages) != 1 {
+		t.Fatalf("RequestCustomerReset: %v, messages %d", err, len(env.Notifier.Messages))
+	}
+	err = env.Auth.RequestCustomerReset(ctx, phone)
+	if retry := retryAfter(err); retry != cfg.ResetCodeCooldown {
+		t.Fatalf("err = %v, want retry after the cooldown", err)
+	}
+
+	env.Advance(cfg.ResetCodeCooldown)
+	err = env.Auth.RequestCustomerReset(ctx, phone)
+	if err != nil || len(env.Notifier.Messages) != 2 {
+		t.Fatalf("after the cooldown: %v, messages %d", err, len(env.Notifier.Messages))
+	}
+	env.Advance(cfg.ResetCodeCooldown)
+	err = env.Auth.RequestCustomerReset(ctx, phone)
+	if retry := retryAfter(err); retry != cfg.ResetCodeWindow-cfg.ResetCodeCooldown {
+		t.Errorf("over the limit: err = %v, want retry after the window", err)
+	}
+	if len(env.Notifier.Messages) != 2 {
+		t.Errorf("messages = %d, want 2", len(env.Notifier.Messages))
+	}
+
+	// незарегистрированный телефон ограничивается так же
+	unknown := "+992901234568"
+	err = env.Auth.RequestCustomerReset(ctx, unknown)
+	if err != nil {
+		t.Fatalf("unknown phone: %v", err)
+	}
+	err = env.Auth.RequestCustomerReset(ctx, unknown)
+	if retry := retryAfter(err); retry != cfg.ResetCodeCooldown {
+		t.Errorf("unknown phone: err = %v, want retry after the cooldown", err)
+	}
+}
@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Префиксы токенов позволяют отличить токен покупателя от токена менеджера
+// (и от refresh-токена) по виду, например в логах.
+const (
+	customerTokenPrefix        = "cus_"
+	customerRefreshTokenPrefix = "cusr_"
+	managerTokenPrefix         = "mgr_"
+	managerRefreshTokenPrefix  = "mgrr_"
+)
+
+// tokenEntropy - количество случайных байт в токене.
+const tokenEntropy = 32
+
+// newToken генерирует токен вида <prefix><base64url от 32 случайных байт>.
+func newToken(prefix string) (string, error) {
+	buffer := make([]byte, tokenEntropy)
+	n, err := rand.Read(buffer)
+	if n != len(buffer) || err != nil {
+		log.Print(err)
+		return "", ErrInternal
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// hashToken возвращает SHA-256 токена в hex - только он и хранится в БД.
+func hashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// issueToken создаёт новую пару токенов для владельца ownerID.
+func (s *Service) issueToken(ctx context.Context, kind Kind, ownerID int64) (*Token, error) {
+	prefix, refreshPrefix := customerTokenPrefix, customerRefreshTokenPrefix
+	if kind == KindManager {
+		prefix, refreshPrefix = managerTokenPrefix, managerRefreshTokenPrefix
+	}
+
+	token, err := newToken(prefix)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := newToken(refreshPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	expire, err := s.tokens.Create(ctx, &NewTokenRecord{
+		Kind:        kind,
+		OwnerID:     ownerID,
+		Hash:        hashToken(token),
+		RefreshHash: hashToken(refreshToken),
+		TTL:         s.tokenTTL,
+		RefreshTTL:  s.refreshTTL,
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	return &Token{Token: token, RefreshToken: refreshToken, Expire: expire}, nil
+}
+
+// IssueToken выдаёт пару токенов пользователю без проверки пароля,
+// например только что зарегистрированному менеджеру.
+func (s *Service) IssueToken(ctx context.Context, kind Kind, ownerID int64) (*Token, error) {
+	return s.issueToken(ctx, kind, ownerID)
+}
+
+// TokenForManager генерирует токен для менеджера, входящего с адреса ip.
+// Если менеджер не найден или пароль не верен, возвращается ошибка ErrInvalidPassword.
+// Если вход временно запрещён, возвращается *LockedError.
+func (s *Service) TokenForManager(ctx context.Context, phone string, password string, ip string) (*Token, error) {
+	return s.login(ctx, KindManager, phone, password, ip)
+}
+
+// TokenForCustomer генерирует токен для пользователя, входящего с адреса ip.
+// Если пользователь не найден или пароль не верен, возвращается ошибка ErrInvalidPassword.
+// Если вход временно запрещён, возвращается *LockedError.
+// Если происходит другая ошибка, вовзращается ErrInternal.
+func (s *Service) TokenForCustomer(ctx context.Context, phone string, password string, ip string) (*Token, error) {
+	return s.login(ctx, KindCustomer, phone, password, ip)
+}
+
+// login проверяет пароль и выдаёт пару токенов. Телефон приводится к E.164,
+// некорректный телефон и заблокированные пользователи считаются ненайденными.
+// Чтобы ни ответ, ни время ответа не выдавали, зарегистрирован ли телефон,
+// для ненайденного пользователя пароль сравнивается с dummyHash и
+// возвращается та же ErrInvalidPassword.
+// Попытка засчитывается по телефону и по адресу до проверки пароля
+// (см. countAttempt), успешный вход её отменяет (см. passAttempt).
+func (s *Service) login(ctx context.Context, kind Kind, phone string, password string, ip string) (*Token, error) {
+	keys := make([]string, 0, 2)
+	phone, err := s.normalizer.Normalize(phone)
+	if err == nil {
+		keys = append(keys, phoneKey(kind, phone))
+	}
+	if ip != "" {
+		keys = append(keys, ipKeyPrefix+ip)
+	}
+	err = s.checkAttempts(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := s.countAttempt(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := s.dummyHash
+	var ownerID int64
+	if phone != "" {
+		credentials, err := s.credentials.CredentialsByPhone(ctx, kind, phone)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Print(err)
+			return nil, ErrInternal
+		}
+		if err == nil && credentials.Active {
+			hash = []byte(credentials.PasswordHash)
+			ownerID = credentials.ID
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if err != nil || ownerID == 0 {
+		s.failAttempt(ctx, keys, failures)
+		return nil, ErrInvalidPassword
+	}
+
+	s.passAttempt(ctx, keys)
+	return s.issueToken(ctx, kind, ownerID)
+}
+
+// RefreshCustomerToken меняет refresh-токен покупателя на новую пару токенов.
+func (s *Service) RefreshCustomerToken(ctx context.Context, refreshToken string) (*Token, error) {
+	return s.refreshToken(ctx, KindCustomer, refreshToken)
+}
+
+// RefreshManagerToken меняет refresh-токен менеджера на новую пару токенов.
+func (s *Service) RefreshManagerToken(ctx context.Context, refreshToken string) (*Token, error) {
+	return s.refreshToken(ctx, KindManager, refreshToken)
+}
+
+// refreshToken отзывает старую пару токенов и выдаёт новую. Refresh-токен
+// одноразовый: повторное использование вернёт ErrInvalidToken.
+func (s *Service) refreshToken(ctx context.Context, kind Kind, refreshToken string) (*Token, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var result *Token
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		record, err := s.tokens.ByRefreshToken(ctx, kind, hashToken(refreshToken))
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if record.RefreshExpired {
+			return ErrExpire
+		}
+
+		err = s.tokens.Remove(ctx, kind, record.ID, record.OwnerID)
+		if err != nil {
+			return err
+		}
+
+		result, err = s.issueToken(ctx, kind, record.OwnerID)
+		return err
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return result, nil
+}
+
+// RevokeToken отзывает токен, которым аутентифицирован principal (logout).
+func (s *Service) RevokeToken(ctx context.Context, principal *Principal) error {
+	err := s.tokens.Remove(ctx, principal.Kind, principal.TokenID, principal.ID)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// RevokeAllTokens отзывает все токены пользователя ("выйти на всех устройствах")
+// и возвращает количество отозванных токенов.
+func (s *Service) RevokeAllTokens(ctx context.Context, principal *Principal) (int64, error) {
+	count, err := s.tokens.RemoveByOwner(ctx, principal.Kind, principal.ID)
+	if err != nil {
+		log.Print(err)
+		return 0, ErrInternal
+	}
+	return count, nil
+}
+
+// PurgeExpiredTokens удаляет токены, у которых истёк и refresh-токен,
+// и возвращает количество удалённых строк.
+func (s *Service) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	count, err := s.tokens.RemoveExpired(ctx)
+	if err != nil {
+		log.Print(err)
+		return count, ErrInternal
+	}
+	return count, nil
+}
+
+// tokenPurgerHook запускает RunTokenPurger на время жизни приложения.
+func (s *Service) tokenPurgerHook(interval time.Duration) lifecycle.Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	return lifecycle.Hook{
+		Name: "token purger",
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				s.RunTokenPurger(ctx, interval)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
+
+// RunTokenPurger раз в interval удаляет просроченные токены, коды сброса
+// пароля и устаревшие неудачные попытки входа, пока не отменён ctx.
+func (s *Service) RunTokenPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// очистки независимы: ошибка одной не должна пропускать остальные,
+			// сами ошибки уже записаны в лог методами Purge*
+			purge(ctx, "expired tokens", s.PurgeExpiredTokens)
+			purge(ctx, "expired reset codes", s.PurgeExpiredResetCodes)
+			purge(ctx, "stale login attempts", s.PurgeStaleAttempts)
+		}
+	}
+}
+
+// purge выполняет одну очистку и пишет в лог, сколько записей удалено.
+func purge(ctx context.Context, what string, fn func(ctx context.Context) (int64, error)) {
+	count, err := fn(ctx)
+	if err == nil && count > 0 {
+		log.Printf("purged %d %s", count, what)
+	}
+}
+
+// PrincipalByManagerToken возвращает менеджера, которому принадлежит токен.
+// Права доступа здесь не проверяются - это делают guard'ы маршрутов (см. HasAnyRole).
+// Если токен не найден, возвращается ErrInvalidToken, если истёк - ErrExpire.
+func (s *Service) PrincipalByManagerToken(ctx context.Context, token string) (*Principal, error) {
+	return s.principalByToken(ctx, KindManager, token)
+}
+
+// PrincipalByCustomerToken возвращает покупателя, которому принадлежит токен.
+// Если токен не найден, возвращается ErrInvalidToken, если истёк - ErrExpire.
+func (s *Service) PrincipalByCustomerToken(ctx context.Context, token string) (*Principal, error) {
+	return s.principalByToken(ctx, KindCustomer, token)
+}
+
+func (s *Service) principalByToken(ctx context.Context, kind Kind, token string) (*Principal, error) {
+	record, err := s.tokens.ByToken(ctx, kind, hashToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	if record.Expired {
+		return nil, ErrExpire
+	}
+
+	roles := record.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return &Principal{
+		ID:        record.OwnerID,
+		Kind:      kind,
+		Roles:     roles,
+		TokenID:   record.ID,
+		ExpiresAt: record.Expire,
+	}, nil
+}
+
+// AutenticateCustomer проводит процедуру аутентификации покупателя,
+// возвращая в случае успеха его id.
+// Если токен не найден, возвращается ошибка ErrNoSuchUser.
+// Если токен истёк, возвращается ошибка ErrExpire.
+// Если происходит другая ошибка, вовзращается ErrInternal.
+func (s *Service) AutenticateCustomer(ctx context.Context, token string) (id int64, err error) {
+	principal, err := s.PrincipalByCustomerToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return 0, ErrNoSuchUser
+	}
+	if err != nil {
+		return 0, err
+	}
+	return principal.ID, nil
+}
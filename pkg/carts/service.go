@@ -0,0 +1,355 @@
+package carts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда товар не найден или снят с продажи.
+var ErrNotFound = errors.New("product not found")
+
+// ErrNoSuchItem возвращается, когда товара нет в корзине.
+var ErrNoSuchItem = errors.New("item is not in cart")
+
+// ErrInvalidQuantity возвращается, когда указано некорректное количество.
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// ErrInsufficientStock возвращается, когда в корзину кладут больше, чем есть на складе.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrEmptyCart возвращается при оформлении пустой корзины.
+var ErrEmptyCart = errors.New("cart is empty")
+
+// ErrPriceChanged возвращается при оформлении, если цены изменились
+// с момента добавления товаров в корзину.
+var ErrPriceChanged = errors.New("prices have changed")
+
+// PriceChange - изменение цены товара в корзине.
+type PriceChange struct {
+	ProductID int64 `json:"product_id"`
+	OldPrice  int   `json:"old_price"`
+	NewPrice  int   `json:"new_price"`
+}
+
+// PriceChangedError содержит изменившиеся цены. errors.Is(err, ErrPriceChanged) для неё истинно.
+type PriceChangedError struct {
+	Changes []*PriceChange
+}
+
+func (e *PriceChangedError) Error() string {
+	return ErrPriceChanged.Error()
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrPriceChanged.
+func (e *PriceChangedError) Unwrap() error {
+	return ErrPriceChanged
+}
+
+// Line - строка корзины. Price - цена на момент добавления, CurrentPrice
+// и Available - текущее состояние каталога.
+type Line struct {
+	ProductID    int64     `json:"product_id"`
+	Name         string    `json:"name"`
+	Price        int       `json:"price"`
+	Qty          int       `json:"qty"`
+	CurrentPrice int       `json:"current_price"`
+	PriceChanged bool      `json:"price_changed"`
+	Available    bool      `json:"available"`
+	Updated      time.Time `json:"updated"`
+}
+
+// Cart - корзина покупателя. Total считается по ценам строк.
+type Cart struct {
+	CustomerID int64   `json:"customer_id"`
+	Items      []*Line `json:"items"`
+	Total      int     `json:"total"`
+}
+
+// Item - товар и количество в запросе на изменение корзины.
+type Item struct {
+	ProductID int64 `json:"product_id"`
+	Qty       int   `json:"qty"`
+}
+
+// CartRepo хранит строки корзин: ProductID, Price, Qty и Updated.
+type CartRepo interface {
+	// Lines возвращает строки корзины в порядке product_id.
+	Lines(ctx context.Context, customerID int64) ([]*Line, error)
+	// SetLine добавляет строку или заменяет количество и цену существующей.
+	SetLine(ctx context.Context, customerID int64, line *Line) error
+	// RemoveLine удаляет строку, если её нет - возвращает storage.ErrNotFound.
+	RemoveLine(ctx context.Context, customerID int64, productID int64) error
+	Clear(ctx context.Context, customerID int64) error
+}
+
+// Catalog читает товары. Внутри транзакции строка товара блокируется до её конца.
+type Catalog interface {
+	ByID(ctx context.Context, id int64) (*products.Product, error)
+}
+
+// Purchaser оформляет заказ (см. purchases.Service).
+type Purchaser interface {
+	MakePurchase(ctx context.Context, customerID int64, order *purchases.Order) (*purchases.Purchase, error)
+}
+
+// Service управляет корзинами покупателей.
+type Service struct {
+	tx        storage.Transactor
+	carts     CartRepo
+	catalog   Catalog
+	purchases Purchaser
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, carts CartRepo, catalog Catalog, purchases Purchaser) *Service {
+	return &Service{tx: tx, carts: carts, catalog: catalog, purchases: purchases}
+}
+
+// Cart возвращает корзину с текущими ценами и доступностью товаров.
+func (s *Service) Cart(ctx context.Context, customerID int64) (*Cart, error) {
+	lines, err := s.carts.Lines(ctx, customerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	cart := &Cart{CustomerID: customerID, Items: lines}
+	for _, line := range lines {
+		product, err := s.catalog.ByID(ctx, line.ProductID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Print(err)
+			return nil, ErrInternal
+		}
+		if product != nil {
+			line.Name = product.Name
+			line.CurrentPrice = product.Price
+			line.PriceChanged = product.Price != line.Price
+			line.Available = product.Active && product.Qty >= line.Qty
+		}
+		cart.Total += line.Price * line.Qty
+	}
+	return cart, nil
+}
+
+// AddItem кладёт товар в корзину; если он уже там, количество увеличивается.
+// Цена строки обновляется до текущей.
+func (s *Service) AddItem(ctx context.Context, customerID int64, item *Item) (*Cart, error) {
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		lines, err := s.carts.Lines(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		qty := item.Qty
+		for _, line := range lines {
+			if line.ProductID == item.ProductID {
+				qty += line.Qty
+			}
+		}
+		return s.setLine(ctx, customerID, &Item{ProductID: item.ProductID, Qty: qty}, item.Qty)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.Cart(ctx, customerID)
+}
+
+// SetItem устанавливает количество товара в корзине; 0 убирает товар.
+func (s *Service) SetItem(ctx context.Context, customerID int64, item *Item) (*Cart, error) {
+	if item.Qty == 0 {
+		return s.RemoveItem(ctx, customerID, item.ProductID)
+	}
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		return s.setLine(ctx, customerID, item, item.Qty)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.Cart(ctx, customerID)
+}
+
+// RemoveItem убирает товар из корзины.
+func (s *Service) RemoveItem(ctx context.Context, customerID int64, productID int64) (*Cart, error) {
+	err := s.carts.RemoveLine(ctx, customerID, productID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoSuchItem
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return s.Cart(ctx, customerID)
+}
+
+// Replace заменяет содержимое корзины целиком (PUT).
+func (s *Service) Replace(ctx context.Context, customerID int64, items []*Item) (*Cart, error) {
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		err := s.carts.Clear(ctx, customerID)
+		if err != nil {
+			return err
+		}
+
+		merged := make(map[int64]*Item)
+		for _, item := range items {
+			if item == nil {
+				return ErrInvalidQuantity
+			}
+			if value, ok := merged[item.ProductID]; ok {
+				value.Qty += item.Qty
+				continue
+			}
+			value := *item
+			merged[item.ProductID] = &value
+		}
+		for _, item := range merged {
+			err = s.setLine(ctx, customerID, item, item.Qty)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.Cart(ctx, customerID)
+}
+
+// Clear очищает корзину.
+func (s *Service) Clear(ctx context.Context, customerID int64) error {
+	err := s.carts.Clear(ctx, customerID)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// Checkout оформляет корзину одной покупкой и очищает её в той же транзакции.
+// Если цена какого-то товара изменилась с момента добавления, покупка не
+// оформляется: возвращается *PriceChangedError, а цены в корзине обновляются,
+// чтобы покупатель мог проверить их и повторить оформление.
+func (s *Service) Checkout(ctx context.Context, customerID int64) (*purchases.Purchase, error) {
+	var result *purchases.Purchase
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		lines, err := s.carts.Lines(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return ErrEmptyCart
+		}
+
+		order := &purchases.Order{}
+		changes := make([]*PriceChange, 0)
+		for _, line := range lines {
+			product, err := s.catalog.ByID(ctx, line.ProductID)
+			if errors.Is(err, storage.ErrNotFound) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if product.Price != line.Price {
+				changes = append(changes, &PriceChange{ProductID: line.ProductID, OldPrice: line.Price, NewPrice: product.Price})
+			}
+			order.Items = append(order.Items, &purchases.OrderItem{ProductID: line.ProductID, Qty: line.Qty})
+		}
+		if len(changes) > 0 {
+			return &PriceChangedError{Changes: changes}
+		}
+
+		result, err = s.purchases.MakePurchase(ctx, customerID, order)
+		if err != nil {
+			return err
+		}
+		return s.carts.Clear(ctx, customerID)
+	})
+
+	var changed *PriceChangedError
+	if errors.As(err, &changed) {
+		s.refreshPrices(ctx, customerID, changed.Changes)
+		return nil, err
+	}
+	if err != nil {
+		return nil, internal(err)
+	}
+	return result, nil
+}
+
+// refreshPrices переносит новые цены в строки корзины.
+func (s *Service) refreshPrices(ctx context.Context, customerID int64, changes []*PriceChange) {
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		lines, err := s.carts.Lines(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		prices := make(map[int64]int, len(changes))
+		for _, change := range changes {
+			prices[change.ProductID] = change.NewPrice
+		}
+		for _, line := range lines {
+			price, ok := prices[line.ProductID]
+			if !ok {
+				continue
+			}
+			line.Price = price
+			err = s.carts.SetLine(ctx, customerID, line)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// setLine проверяет товар и остаток и сохраняет строку с текущей ценой.
+// Количество, на которое строка выросла, должно быть положительным.
+func (s *Service) setLine(ctx context.Context, customerID int64, item *Item, added int) error {
+	if item.Qty <= 0 || added <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	product, err := s.catalog.ByID(ctx, item.ProductID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !product.Active {
+		return ErrNotFound
+	}
+	if product.Qty < item.Qty {
+		return ErrInsufficientStock
+	}
+
+	return s.carts.SetLine(ctx, customerID, &Line{ProductID: item.ProductID, Price: product.Price, Qty: item.Qty})
+}
+
+// internal пропускает ошибки сервиса и оформления покупки как есть,
+// а ошибки хранилища логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{
+		ErrNotFound, ErrNoSuchItem, ErrInvalidQuantity, ErrInsufficientStock, ErrEmptyCart, ErrPriceChanged, ErrInternal,
+		purchases.ErrNotFound, purchases.ErrInsufficientStock, purchases.ErrInvalidQuantity, purchases.ErrPhoneNotVerified, purchases.ErrInternal,
+	} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
@@ -0,0 +1,115 @@
+package carts_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+func TestCheckout(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 5)
+	ctx := context.Background()
+
+	_, err := env.Carts.AddItem(ctx, customer.ID, &carts.Item{ProductID: tea.ID, Qty: 2})
+	if err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	purchase, err := env.Carts.Checkout(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if purchase.Total != 200 {
+		t.Errorf("total = %d, want 200", purchase.Total)
+	}
+
+	cart, err := env.Carts.Cart(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("Cart: %v", err)
+	}
+	if len(cart.Items) != 0 {
+		t.Errorf("cart items = %d, want empty cart after checkout", len(cart.Items))
+	}
+	_, err = env.Carts.Checkout(ctx, customer.ID)
+	if !errors.Is(err, carts.ErrEmptyCart) {
+		t.Errorf("err = %v, want ErrEmptyCart", err)
+	}
+}
+
+func TestCheckoutPriceChanged(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 5)
+	coffee := env.Product(t, "coffee", 250, 5)
+	ctx := context.Background()
+
+	_, err := env.Carts.Replace(ctx, customer.ID, []*carts.Item{{ProductID: tea.ID, Qty: 1}, {ProductID: coffee.ID, Qty: 1}})
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	price := 120
+	_, err = env.Products.Patch(ctx, tea.ID, &products.Patch{Price: &price})
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+
+	_, err = env.Carts.Checkout(ctx, customer.ID)
+	var changed *carts.PriceChangedError
+	if !errors.As(err, &changed) || !errors.Is(err, carts.ErrPriceChanged) {
+		t.Fatalf("err = %v, want PriceChangedError", err)
+	}
+	if len(changed.Changes) != 1 || *changed.Changes[0] != (carts.PriceChange{ProductID: tea.ID, OldPrice: 100, NewPrice: 120}) {
+		t.Errorf("changes = %+v, want tea 100 -> 120", changed.Changes)
+	}
+
+	// покупка не оформлена, а цены в корзине обновлены до текущих
+	cart, err := env.Carts.Cart(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("Cart: %v", err)
+	}
+	if len(cart.Items) != 2 || cart.Total != 120+250 {
+		t.Errorf("cart = %+v, want 2 items and total 370", cart)
+	}
+	for _, line := range cart.Items {
+		if line.PriceChanged {
+			t.Errorf("line %d still has a changed price", line.ProductID)
+		}
+	}
+	item, err := env.Products.ByID(ctx, tea.ID)
+	if err != nil || item.Qty != 5 {
+		t.Errorf("tea = %+v, %v, want qty 5", item, err)
+	}
+
+	purchase, err := env.Carts.Checkout(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("second Checkout: %v", err)
+	}
+	if purchase.Total != 370 {
+		t.Errorf("total = %d, want 370", purchase.Total)
+	}
+}
+
+func TestAddItemInsufficientStock(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 2)
+	ctx := context.Background()
+
+	_, err := env.Carts.AddItem(ctx, customer.ID, &carts.Item{ProductID: tea.ID, Qty: 2})
+	if err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	_, err = env.Carts.AddItem(ctx, customer.ID, &carts.Item{ProductID: tea.ID, Qty: 1})
+	if !errors.Is(err, carts.ErrInsufficientStock) {
+		t.Errorf("err = %v, want ErrInsufficientStock", err)
+	}
+	_, err = env.Carts.Replace(ctx, customer.ID, []*carts.Item{nil})
+	if !errors.Is(err, carts.ErrInvalidQuantity) {
+		t.Errorf("err = %v, want ErrInvalidQuantity", err)
+	}
+}
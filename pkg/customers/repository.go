@@ -0,0 +1,40 @@
+package customers
+
+import (
+	"context"
+	"time"
+)
+
+// Verification - код подтверждения телефона покупателя.
+type Verification struct {
+	Hash     string
+	Attempts int
+	Expired  bool
+}
+
+// CustomerRepo хранит покупателей. Если покупатель не найден, возвращает
+// storage.ErrNotFound, если телефон занят - storage.ErrConflict.
+type CustomerRepo interface {
+	All(ctx context.Context) ([]*Customer, error)
+	AllActive(ctx context.Context) ([]*Customer, error)
+	ByID(ctx context.Context, id int64) (*Customer, error)
+	Create(ctx context.Context, item *Customer, passwordHash string) (*Customer, error)
+	// Upsert добавляет покупателя, а если телефон занят - обновляет его имя.
+	Upsert(ctx context.Context, item *Customer, passwordHash string) (*Customer, error)
+	// Update меняет имя и телефон, а если passwordHash не пуст - и пароль.
+	// При смене телефона подтверждение и код подтверждения сбрасываются.
+	Update(ctx context.Context, item *Customer, passwordHash string) (*Customer, error)
+	Remove(ctx context.Context, id int64) error
+	SetActive(ctx context.Context, id int64, active bool) error
+	// SetVerification сохраняет новый код подтверждения телефона (хэш) со сроком ttl.
+	// Если предыдущий код отправлен меньше cooldown назад, код не меняется,
+	// а возвращается время, через которое можно отправить новый.
+	SetVerification(ctx context.Context, id int64, hash string, ttl time.Duration, cooldown time.Duration) (time.Duration, error)
+	// Verification возвращает текущий код подтверждения или storage.ErrNotFound,
+	// если его нет. Внутри транзакции строка покупателя блокируется до её конца.
+	Verification(ctx context.Context, id int64) (*Verification, error)
+	FailVerification(ctx context.Context, id int64) error
+	ClearVerification(ctx context.Context, id int64) error
+	// MarkVerified отмечает телефон подтверждённым и удаляет код.
+	MarkVerified(ctx context.Context, id int64) error
+}
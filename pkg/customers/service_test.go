@@ -0,0 +1,123 @@
+package customers_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+var codePattern = regexp.MustCompile(`\d{4,}`)
+
+// lastCode возвращает код из последнего отправленного сообщения.
+func lastCode(t *testing.T, env *memtest.Env) string {
+	t.Helper()
+	messages := env.Notifier.Messages
+	if len(messages) == 0 {
+		t.Fatal("no messages were sent")
+	}
+	return codePattern.FindString(messages[len(messages)-1].Text)
+}
+
+func TestRequestVerificationCooldown(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	id := env.Customer(t, "+992901234567", "Correct-Horse-42").ID
+
+	err := env.Customers.RequestVerification(ctx, id)
+	if err != nil {
+		t.Fatalf("RequestVerification: %v", err)
+	}
+	old := lastCode(t, env)
+
+	env.Advance(env.Config.VerificationResendCooldown - time.Second)
+	err = env.Customers.RequestVerification(ctx, id)
+	var resend *customers.ResendError
+	if !errors.As(err, &resend) || !errors.Is(err, customers.ErrResendTooSoon) || resend.RetryAfter != time.Second {
+		t.Fatalf("err = %v, want ResendError with retry after 1s", err)
+	}
+	if len(env.Notifier.Messages) != 1 {
+		t.Errorf("messages = %d, want 1", len(env.Notifier.Messages))
+	}
+
+	env.Advance(time.Second)
+	err = env.Customers.RequestVerification(ctx, id)
+	if err != nil {
+		t.Fatalf("after the cooldown: %v", err)
+	}
+	code := lastCode(t, env)
+
+	// новый код заменяет прежний
+	if old != code {
+		_, err = env.Customers.VerifyPhone(ctx, id, old)
+		if !errors.Is(err, customers.ErrInvalidCode) {
+			t.Errorf("old code: err = %v, want ErrInvalidCode", err)
+		}
+	}
+	item, err := env.Customers.VerifyPhone(ctx, id, code)
+	if err != nil || !item.PhoneVerified {
+		t.Fatalf("VerifyPhone = %+v, %v; want a verified phone", item, err)
+	}
+	err = env.Customers.RequestVerification(ctx, id)
+	if !errors.Is(err, customers.ErrAlreadyVerified) {
+		t.Errorf("err = %v, want ErrAlreadyVerified", err)
+	}
+}
+
+func TestRequestVerificationSendFails(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	id := env.Customer(t, "+992901234567", "Correct-Horse-42").ID
+	env.Notifier.Failures = len(env.Notifier.Messages) + 1
+
+	err := env.Customers.RequestVerification(ctx, id)
+	if !errors.Is(err, customers.ErrInternal) {
+		t.Fatalf("err = %v, want ErrInternal", err)
+	}
+	lost := lastCode(t, env)
+
+	// неотправленный код не сохраняется и не запускает resendCooldown
+	err = env.Customers.RequestVerification(ctx, id)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	code := lastCode(t, env)
+	if lost != code {
+		_, err = env.Customers.VerifyPhone(ctx, id, lost)
+		if !errors.Is(err, customers.ErrInvalidCode) {
+			t.Errorf("lost code: err = %v, want ErrInvalidCode", err)
+		}
+	}
+	item, err := env.Customers.VerifyPhone(ctx, id, code)
+	if err != nil || !item.PhoneVerified {
+		t.Fatalf("VerifyPhone = %+v, %v; want a verified phone", item, err)
+	}
+}
+
+func TestVerifyPhoneAttempts(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	id := env.Customer(t, "+992901234567", "Correct-Horse-42").ID
+
+	err := env.Customers.RequestVerification(ctx, id)
+	if err != nil {
+		t.Fatalf("RequestVerification: %v", err)
+	}
+	code := lastCode(t, env)
+
+	for i := 0; i < env.Config.VerificationCodeAttempts; i++ {
+		_, err = env.Customers.VerifyPhone(ctx, id, "wrong")
+		if !errors.Is(err, customers.ErrInvalidCode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCode", i+1, err)
+		}
+	}
+	// после исчерпания попыток код удалён, даже верный уже не подходит
+	_, err = env.Customers.VerifyPhone(ctx, id, code)
+	if !errors.Is(err, customers.ErrInvalidCode) {
+		t.Errorf("err = %v, want ErrInvalidCode", err)
+	}
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/migrations"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrDraining возвращается, пока приложение завершает работу.
+var ErrDraining = errors.New("shutting down")
+
+// ErrDatabase возвращается, когда БД не отвечает.
+var ErrDatabase = errors.New("database is unavailable")
+
+// ErrSchemaOutdated возвращается, когда в БД применены не все миграции,
+// вкомпилированные в бинарник.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// pingTimeout ограничивает проверки, чтобы оркестратор не ждал зависшую БД.
+const pingTimeout = 2 * time.Second
+
+// Service отвечает на вопросы оркестратора: жив ли процесс и готов ли он принимать трафик.
+type Service struct {
+	pool          *pgxpool.Pool
+	lc            *lifecycle.Lifecycle
+	started       time.Time
+	latestVersion int64
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool, lc *lifecycle.Lifecycle) *Service {
+	return &Service{pool: pool, lc: lc, started: time.Now(), latestVersion: migrations.Latest()}
+}
+
+// PoolStatus - статистика пула соединений (pgxpool.Stat).
+type PoolStatus struct {
+	AcquiredConns int32 `json:"acquired_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	TotalConns    int32 `json:"total_conns"`
+	MaxConns      int32 `json:"max_conns"`
+	AcquireCount  int64 `json:"acquire_count"`
+	EmptyAcquires int64 `json:"empty_acquire_count"`
+}
+
+// Status - подробное состояние сервиса для /debug/status.
+type Status struct {
+	Ready         bool       `json:"ready"`
+	Reason        string     `json:"reason,omitempty"`
+	Draining      bool       `json:"draining"`
+	Uptime        string     `json:"uptime"`
+	SchemaVersion int64      `json:"schema_version"`
+	LatestVersion int64      `json:"latest_schema_version"`
+	Pool          PoolStatus `json:"pool"`
+}
+
+// Draining сообщает, началась ли остановка приложения.
+func (s *Service) Draining() bool {
+	select {
+	case <-s.lc.Stopping():
+		return true
+	default:
+		return false
+	}
+}
+
+// Ready проверяет, что приложение не останавливается, БД отвечает,
+// а все миграции применены.
+func (s *Service) Ready(ctx context.Context) error {
+	if s.Draining() {
+		return ErrDraining
+	}
+
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < s.latestVersion {
+		return ErrSchemaOutdated
+	}
+	return nil
+}
+
+// Status собирает состояние сервиса. Ошибка готовности не считается
+// ошибкой Status - она попадает в поле Reason.
+func (s *Service) Status(ctx context.Context) *Status {
+	stat := s.pool.Stat()
+	result := &Status{
+		Draining:      s.Draining(),
+		Uptime:        time.Since(s.started).Round(time.Second).String(),
+		LatestVersion: s.latestVersion,
+		Pool: PoolStatus{
+			AcquiredConns: stat.AcquiredConns(),
+			IdleConns:     stat.IdleConns(),
+			TotalConns:    stat.TotalConns(),
+			MaxConns:      stat.MaxConns(),
+			AcquireCount:  stat.AcquireCount(),
+			EmptyAcquires: stat.EmptyAcquireCount(),
+		},
+	}
+
+	version, err := s.schemaVersion(ctx)
+	if err == nil {
+		result.SchemaVersion = version
+	}
+
+	err = s.Ready(ctx)
+	result.Ready = err == nil
+	if err != nil {
+		result.Reason = err.Error()
+	}
+	return result
+}
+
+func (s *Service) schemaVersion(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	var version int64
+	err := s.pool.QueryRow(ctx, `
+		SELECT coalesce(max(version), 0) FROM schema_migrations
+	`).Scan(&version)
+	if err != nil {
+		log.Print(err)
+		return 0, ErrDatabase
+	}
+	return version, nil
+}
@@ -0,0 +1,189 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrInvalidKey возвращается, когда ключ пустой или длиннее MaxKeyLength.
+var ErrInvalidKey = errors.New("invalid idempotency key")
+
+// ErrKeyReused возвращается, когда ключ повторно использован с другим запросом.
+var ErrKeyReused = errors.New("idempotency key was used with a different request")
+
+// ErrInProgress возвращается, когда запрос с этим ключом ещё выполняется.
+var ErrInProgress = errors.New("request with this idempotency key is in progress")
+
+// MaxKeyLength ограничивает длину ключа.
+const MaxKeyLength = 255
+
+// Response - сохранённый ответ на первый запрос с ключом.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Record - ключ идемпотентности. Scope отделяет ключи разных пользователей,
+// Fingerprint - отпечаток запроса (метод, путь и тело). Response == nil,
+// пока первый запрос выполняется. TTL - срок резервирования ключа.
+type Record struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Response    *Response
+	TTL         time.Duration
+}
+
+// KeyRepo хранит ключи. Просроченные ключи считаются отсутствующими.
+type KeyRepo interface {
+	// Reserve сохраняет ключ без ответа на item.TTL. Если действующий ключ
+	// уже есть, возвращает storage.ErrConflict; просроченный заменяется.
+	Reserve(ctx context.Context, item *Record) error
+	// ByKey возвращает действующий ключ или storage.ErrNotFound.
+	ByKey(ctx context.Context, scope string, key string) (*Record, error)
+	// Complete сохраняет ответ и продлевает срок ключа до ttl от текущего момента.
+	Complete(ctx context.Context, scope string, key string, response *Response, ttl time.Duration) error
+	Remove(ctx context.Context, scope string, key string) error
+	RemoveExpired(ctx context.Context) (int64, error)
+}
+
+// Service помнит ответы на запросы с Idempotency-Key, чтобы повтор запроса
+// после таймаута не выполнял его второй раз.
+//
+// Выполняющийся запрос держит ключ lockTTL, а сохранённый ответ хранится ttl.
+// Если процесс упадёт посреди запроса, ключ освободится через lockTTL.
+type Service struct {
+	keys    KeyRepo
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// NewService создаёт сервис и регистрирует в lc фоновую очистку ключей.
+func NewService(keys KeyRepo, cfg *config.Config, lc *lifecycle.Lifecycle) *Service {
+	s := &Service{keys: keys, ttl: cfg.IdempotencyTTL, lockTTL: cfg.IdempotencyLockTTL}
+	lc.Append(s.purgerHook(cfg.IdempotencyPurgeInterval))
+	return s
+}
+
+// Begin резервирует ключ за запросом. Если ключ новый, возвращает nil:
+// запрос нужно выполнить и вызвать Complete или Release. Если ответ на
+// такой же запрос уже сохранён, возвращает его для повтора.
+func (s *Service) Begin(ctx context.Context, scope string, key string, fingerprint string) (*Response, error) {
+	if key == "" || len(key) > MaxKeyLength {
+		return nil, ErrInvalidKey
+	}
+
+	err := s.keys.Reserve(ctx, &Record{Scope: scope, Key: key, Fingerprint: fingerprint, TTL: s.lockTTL})
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, storage.ErrConflict) {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	item, err := s.keys.ByKey(ctx, scope, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		// ключ истёк между Reserve и ByKey - повтор увидит его свободным
+		return nil, ErrInProgress
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	if item.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if item.Response == nil {
+		return nil, ErrInProgress
+	}
+	return item.Response, nil
+}
+
+// Complete сохраняет ответ на запрос, зарезервированный Begin, на ttl.
+func (s *Service) Complete(ctx context.Context, scope string, key string, response *Response) error {
+	err := s.keys.Complete(ctx, scope, key, response, s.ttl)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// Release освобождает ключ, если ответ сохранять не нужно (например, при
+// внутренней ошибке), чтобы клиент мог повторить запрос.
+func (s *Service) Release(ctx context.Context, scope string, key string) error {
+	err := s.keys.Remove(ctx, scope, key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// PurgeExpired удаляет просроченные ключи и возвращает их количество.
+func (s *Service) PurgeExpired(ctx context.Context) (int64, error) {
+	count, err := s.keys.RemoveExpired(ctx)
+	if err != nil {
+		log.Print(err)
+		return count, ErrInternal
+	}
+	return count, nil
+}
+
+// purgerHook запускает RunPurger на время жизни приложения.
+func (s *Service) purgerHook(interval time.Duration) lifecycle.Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	return lifecycle.Hook{
+		Name: "idempotency key purger",
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				s.RunPurger(ctx, interval)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
+
+// RunPurger раз в interval удаляет просроченные ключи, пока не отменён ctx.
+func (s *Service) RunPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.PurgeExpired(ctx)
+			if err != nil {
+				continue
+			}
+			if count > 0 {
+				log.Printf("purged %d expired idempotency keys", count)
+			}
+		}
+	}
+}
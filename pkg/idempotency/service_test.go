@@ -0,0 +1,123 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+func begin(t *testing.T, env *memtest.Env, key string, fingerprint string) (*idempotency.Response, error) {
+	t.Helper()
+	return env.Idempotency.Begin(context.Background(), "customer:1", key, fingerprint)
+}
+
+func TestBeginReplay(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+
+	response, err := begin(t, env, "key", "POST /sales")
+	if response != nil || err != nil {
+		t.Fatalf("Begin = %v, %v; want a new key", response, err)
+	}
+	_, err = begin(t, env, "key", "POST /sales")
+	if !errors.Is(err, idempotency.ErrInProgress) {
+		t.Fatalf("err = %v, want ErrInProgress", err)
+	}
+
+	saved := &idempotency.Response{Status: 201, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+	err = env.Idempotency.Complete(ctx, "customer:1", "key", saved)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// ответ хранится дольше, чем держится ключ выполняющегося запроса
+	env.Advance(env.Config.IdempotencyLockTTL)
+	response, err = begin(t, env, "key", "POST /sales")
+	if err != nil || response == nil || response.Status != 201 || string(response.Body) != `{"id":1}` {
+		t.Fatalf("Begin = %+v, %v; want the saved response", response, err)
+	}
+
+	env.Advance(env.Config.IdempotencyTTL)
+	response, err = begin(t, env, "key", "POST /sales")
+	if response != nil || err != nil {
+		t.Errorf("after the TTL: Begin = %v, %v; want a new key", response, err)
+	}
+}
+
+func TestBeginConflict(t *testing.T) {
+	env := memtest.New()
+
+	_, err := begin(t, env, "key", "POST /sales")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	_, err = begin(t, env, "key", "POST /purchases")
+	if !errors.Is(err, idempotency.ErrKeyReused) {
+		t.Errorf("err = %v, want ErrKeyReused", err)
+	}
+
+	// ключи разных пользователей не пересекаются
+	response, err := env.Idempotency.Begin(context.Background(), "customer:2", "key", "POST /purchases")
+	if response != nil || err != nil {
+		t.Errorf("other scope: Begin = %v, %v; want a new key", response, err)
+	}
+}
+
+func TestBeginLockExpires(t *testing.T) {
+	env := memtest.New()
+
+	_, err := begin(t, env, "key", "POST /sales")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	env.Advance(env.Config.IdempotencyLockTTL - time.Second)
+	_, err = begin(t, env, "key", "POST /sales")
+	if !errors.Is(err, idempotency.ErrInProgress) {
+		t.Fatalf("err = %v, want ErrInProgress", err)
+	}
+
+	// ключ упавшего запроса освобождается через IdempotencyLockTTL
+	env.Advance(time.Second)
+	response, err := begin(t, env, "key", "POST /sales")
+	if response != nil || err != nil {
+		t.Errorf("Begin = %v, %v; want a new key", response, err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+
+	_, err := begin(t, env, "key", "POST /sales")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	err = env.Idempotency.Release(ctx, "customer:1", "key")
+	if err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	response, err := begin(t, env, "key", "POST /purchases")
+	if response != nil || err != nil {
+		t.Errorf("Begin = %v, %v; want a new key", response, err)
+	}
+
+	err = env.Idempotency.Release(ctx, "customer:1", "missing")
+	if err != nil {
+		t.Errorf("Release of a missing key: %v", err)
+	}
+}
+
+func TestBeginInvalidKey(t *testing.T) {
+	env := memtest.New()
+
+	for _, key := range []string{"", strings.Repeat("k", idempotency.MaxKeyLength+1)} {
+		_, err := begin(t, env, key, "POST /sales")
+		if !errors.Is(err, idempotency.ErrInvalidKey) {
+			t.Errorf("key of %d bytes: err = %v, want ErrInvalidKey", len(key), err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда товар не найден.
+var ErrNotFound = errors.New("product not found")
+
+// ErrInvalidMovement возвращается, когда движение нельзя провести:
+// нулевое изменение, неизвестный тип или корректировка без причины.
+var ErrInvalidMovement = errors.New("invalid stock movement")
+
+// ErrNegativeStock возвращается, когда после движения остаток стал бы отрицательным.
+var ErrNegativeStock = errors.New("stock cannot become negative")
+
+// Ограничения размера страницы истории.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Kind - тип движения товара.
+type Kind string
+
+const (
+	// KindInitial - остаток, который был до появления журнала (см. миграцию 0004).
+	KindInitial Kind = "initial"
+	// KindRestock - поступление на склад.
+	KindRestock Kind = "restock"
+	// KindSale - списание при продаже.
+	KindSale Kind = "sale"
+	// KindReturn - возврат товара на склад.
+	KindReturn Kind = "return"
+	// KindAdjustment - ручная корректировка (инвентаризация, порча и т.п.).
+	KindAdjustment Kind = "adjustment"
+	// KindCancellation - возврат на склад при отмене продажи.
+	KindCancellation Kind = "cancellation"
+)
+
+// Movement - запись журнала: изменение остатка товара на Delta.
+// QtyAfter - остаток после движения.
+type Movement struct {
+	ID         int64     `json:"id"`
+	ProductID  int64     `json:"product_id"`
+	Kind       Kind      `json:"kind"`
+	Delta      int       `json:"delta"`
+	QtyAfter   int       `json:"qty_after"`
+	Reason     string    `json:"reason,omitempty"`
+	ActorKind  auth.Kind `json:"actor_kind,omitempty"`
+	ActorID    *int64    `json:"actor_id,omitempty"`
+	SaleID     *int64    `json:"sale_id,omitempty"`
+	PurchaseID *int64    `json:"purchase_id,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// Adjustment - запрос менеджера на изменение остатка.
+type Adjustment struct {
+	Kind   Kind   `json:"kind"`
+	Delta  int    `json:"delta"`
+	Reason string `json:"reason"`
+}
+
+// MovementRepo хранит журнал и остатки. Если товар не найден, возвращает storage.ErrNotFound.
+type MovementRepo interface {
+	// LockQty возвращает остаток товара; внутри транзакции строка товара
+	// блокируется до её конца.
+	LockQty(ctx context.Context, productID int64) (int, error)
+	SetQty(ctx context.Context, productID int64, qty int) error
+	// Create сохраняет движение и заполняет ID и Created.
+	Create(ctx context.Context, item *Movement) error
+	// ByProduct возвращает до limit движений товара с id < before (0 - с последнего),
+	// от новых к старым.
+	ByProduct(ctx context.Context, productID int64, before int64, limit int) ([]*Movement, error)
+}
+
+// Service ведёт журнал движений товаров. Остаток products.qty меняется
+// только здесь и всегда вместе с записью в журнале.
+type Service struct {
+	tx        storage.Transactor
+	movements MovementRepo
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, movements MovementRepo) *Service {
+	return &Service{tx: tx, movements: movements}
+}
+
+// Record проводит движение: меняет остаток и пишет запись в журнал в одной
+// транзакции (или в транзакции вызывающего, если ctx её несёт).
+// Если автор не указан, им считается аутентифицированный пользователь из ctx.
+func (s *Service) Record(ctx context.Context, item *Movement) (*Movement, error) {
+	if item.Delta == 0 {
+		return nil, ErrInvalidMovement
+	}
+	if item.ActorID == nil {
+		if principal, ok := auth.FromContext(ctx); ok {
+			id := principal.ID
+			item.ActorKind, item.ActorID = principal.Kind, &id
+		}
+	}
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		qty, err := s.movements.LockQty(ctx, item.ProductID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		item.QtyAfter = qty + item.Delta
+		if item.QtyAfter < 0 {
+			return ErrNegativeStock
+		}
+		err = s.movements.SetQty(ctx, item.ProductID, item.QtyAfter)
+		if err != nil {
+			return err
+		}
+		return s.movements.Create(ctx, item)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return item, nil
+}
+
+// Adjust проводит поступление или ручную корректировку от менеджера.
+// Поступление должно быть положительным, у корректировки должна быть причина.
+func (s *Service) Adjust(ctx context.Context, productID int64, adjustment *Adjustment) (*Movement, error) {
+	reason := strings.TrimSpace(adjustment.Reason)
+	switch adjustment.Kind {
+	case KindRestock:
+		if adjustment.Delta <= 0 {
+			return nil, ErrInvalidMovement
+		}
+	case KindAdjustment:
+		if reason == "" {
+			return nil, ErrInvalidMovement
+		}
+	default:
+		return nil, ErrInvalidMovement
+	}
+
+	return s.Record(ctx, &Movement{
+		ProductID: productID,
+		Kind:      adjustment.Kind,
+		Delta:     adjustment.Delta,
+		Reason:    reason,
+	})
+}
+
+// History возвращает движения товара от новых к старым. Для следующей
+// страницы нужно передать before = id последнего полученного движения.
+func (s *Service) History(ctx context.Context, productID int64, before int64, limit int) ([]*Movement, error) {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 0 || limit > MaxLimit || before < 0 {
+		return nil, ErrInvalidMovement
+	}
+
+	items, err := s.movements.ByProduct(ctx, productID, before, limit)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{ErrNotFound, ErrInvalidMovement, ErrNegativeStock, ErrInternal} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Hook - пара callback'ов, которые сервис регистрирует при создании.
+// OnStart не должен блокироваться: долгую работу нужно запускать в горутине.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle хранит hook'и сервисов, созданных через dig, и запускает их:
+// Start - в порядке регистрации, Stop - в обратном.
+type Lifecycle struct {
+	mu       sync.Mutex
+	hooks    []Hook
+	started  int
+	failed   chan error
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// New создаёт пустой Lifecycle.
+func New() *Lifecycle {
+	return &Lifecycle{failed: make(chan error, 1), stopping: make(chan struct{})}
+}
+
+// Append регистрирует hook.
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start вызывает OnStart всех hook'ов. Если какой-то из них вернул ошибку,
+// уже запущенные останавливаются и ошибка возвращается.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := append([]Hook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	for i, hook := range hooks {
+		if hook.OnStart != nil {
+			err := hook.OnStart(ctx)
+			if err != nil {
+				if stopErr := l.Stop(ctx); stopErr != nil {
+					log.Print(stopErr)
+				}
+				return fmt.Errorf("start %s: %w", hook.Name, err)
+			}
+		}
+		l.mu.Lock()
+		l.started = i + 1
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop вызывает OnStop запущенных hook'ов в обратном порядке. Ошибки
+// не прерывают остановку, а собираются в одну.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stopping)
+	})
+
+	l.mu.Lock()
+	hooks := append([]Hook(nil), l.hooks[:l.started]...)
+	l.started = 0
+	l.mu.Unlock()
+
+	messages := make([]string, 0)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		err := hook.OnStop(ctx)
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("stop %s: %v", hook.Name, err))
+		}
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// Fail сообщает, что фоновая работа сервиса завершилась с ошибкой
+// и приложение нужно останавливать.
+func (l *Lifecycle) Fail(err error) {
+	select {
+	case l.failed <- err:
+	default:
+	}
+}
+
+// Failed возвращает канал, в который приходит первая ошибка из Fail.
+func (l *Lifecycle) Failed() <-chan error {
+	return l.failed
+}
+
+// Stopping возвращает канал, который закрывается в начале остановки,
+// до вызова OnStop. По нему можно, например, перестать считаться готовым.
+func (l *Lifecycle) Stopping() <-chan struct{} {
+	return l.stopping
+}
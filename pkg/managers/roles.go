@@ -0,0 +1,68 @@
+package managers
+
+import (
+	"context"
+	"log"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+)
+
+// Роли пользователей (users.roles). Полный список ролей и их прав хранится
+// в таблице roles.
+const (
+	RoleManager = "MANAGER"
+	RoleAdmin   = "ADMIN"
+)
+
+// Permissions возвращает права, которые дают роли пользователя.
+func (s *Service) Permissions(ctx context.Context, id int64) ([]string, error) {
+	permissions, err := s.managers.Permissions(ctx, id)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return permissions, nil
+}
+
+// HasAnyPermission проверяет, даёт ли хотя бы одна из ролей аутентифицированного
+// пользователя одно из прав. Подходит как middleware.HasAnyRoleFunc.
+func (s *Service) HasAnyPermission(ctx context.Context, permissions ...string) bool {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Kind != auth.KindManager {
+		return false
+	}
+
+	userPermissions, err := s.Permissions(ctx, principal.ID)
+	if err != nil {
+		return false
+	}
+	return containsAny(userPermissions, permissions)
+}
+
+// validateRoles проверяет, что все роли заведены в таблице roles.
+func (s *Service) validateRoles(ctx context.Context, roles []string) error {
+	if len(roles) == 0 {
+		return ErrRoles
+	}
+
+	ok, err := s.managers.RolesExist(ctx, roles)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	if !ok {
+		return ErrRoles
+	}
+	return nil
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, item := range wanted {
+			if value == item {
+				return true
+			}
+		}
+	}
+	return false
+}
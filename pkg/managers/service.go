@@ -0,0 +1,472 @@
+package managers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/passwords"
+	"github.com/Fanisabonu/http/pkg/phones"
+	"github.com/Fanisabonu/http/pkg/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда менеджер не найден.
+var ErrNotFound = errors.New("manager not found")
+
+// ErrRoles возвращается, когда у менеджера нет ролей или роль не заведена.
+var ErrRoles = errors.New("Invalid Role")
+
+// ErrInvalidPlan возвращается, когда план продаж менеджера отрицательный.
+var ErrInvalidPlan = errors.New("plan must not be negative")
+
+// ErrInvalidManager возвращается, когда у менеджера нет имени или телефона
+// или оклад отрицательный.
+var ErrInvalidManager = errors.New("invalid manager")
+
+// ErrPhoneTaken возвращается, когда менеджер с таким телефоном уже есть.
+var ErrPhoneTaken = errors.New("phone is already registered")
+
+// ErrNoSuchBoss возвращается, когда руководитель не найден или неактивен.
+var ErrNoSuchBoss = errors.New("boss not found")
+
+// ErrHierarchyCycle возвращается, когда менеджера подчиняют ему самому
+// или его подчинённому.
+var ErrHierarchyCycle = errors.New("manager cannot report to themselves or to a subordinate")
+
+// ErrInvalidInvite возвращается, когда приглашение не найдено или истекло.
+var ErrInvalidInvite = errors.New("invalid or expired invite")
+
+// InviteTTL - срок действия приглашения.
+const InviteTTL = 72 * time.Hour
+
+// Manager - менеджер (таблица users). Plan - месячный план продаж,
+// BossID - непосредственный руководитель.
+type Manager struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Phone      string    `json:"phone"`
+	Password   string    `json:"password"`
+	Token      string    `json:"token"`
+	Roles      []string  `json:"roles"`
+	Plan       int64     `json:"plan"`
+	Salary     int64     `json:"salary"`
+	Department string    `json:"department"`
+	BossID     *int64    `json:"boss_id"`
+	Active     bool      `json:"active"`
+	Created    time.Time `json:"created"`
+}
+
+// Patch - частичное изменение менеджера: nil-поля не меняются.
+// Роли и руководитель меняются отдельно (SetRoles, SetBoss).
+type Patch struct {
+	Name       *string `json:"name"`
+	Phone      *string `json:"phone"`
+	Department *string `json:"department"`
+	Salary     *int64  `json:"salary"`
+	Plan       *int64  `json:"plan"`
+}
+
+// Registration - результат регистрации. Invite заполнено, если пароль
+// не был задан: менеджер задаёт его сам через AcceptInvite.
+type Registration struct {
+	Manager *Manager `json:"manager"`
+	Invite  *Invite  `json:"invite,omitempty"`
+}
+
+// Invite - одноразовое приглашение. Token показывается только при создании.
+type Invite struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Acceptance - запрос на принятие приглашения.
+type Acceptance struct {
+	Invite   string `json:"invite"`
+	Password string `json:"password"`
+}
+
+// TeamMember - подчинённый. Level - расстояние от руководителя (1 - прямой подчинённый).
+type TeamMember struct {
+	Manager
+	Level int `json:"level"`
+}
+
+// ManagerRepo хранит менеджеров и их роли. Если менеджер не найден,
+// возвращает storage.ErrNotFound.
+type ManagerRepo interface {
+	// Create добавляет менеджера и заполняет его ID и Created; если телефон
+	// занят, возвращает storage.ErrConflict. Пустой passwordHash - без пароля.
+	Create(ctx context.Context, item *Manager, passwordHash string) error
+	// ByID внутри транзакции блокирует строку менеджера до её конца.
+	ByID(ctx context.Context, id int64) (*Manager, error)
+	List(ctx context.Context) ([]*Manager, error)
+	// Update сохраняет имя, телефон, отдел, оклад, план, роли и руководителя;
+	// если телефон занят, возвращает storage.ErrConflict.
+	Update(ctx context.Context, item *Manager) error
+	Deactivate(ctx context.Context, id int64) error
+	// ReassignSubordinates переподчиняет прямых подчинённых fromID менеджеру toID.
+	ReassignSubordinates(ctx context.Context, fromID int64, toID *int64) error
+	// Subordinates возвращает всех подчинённых рекурсивно, включая неактивных,
+	// по уровню и id.
+	Subordinates(ctx context.Context, id int64) ([]*TeamMember, error)
+	// SetInvite сохраняет хэш приглашения со сроком ttl и возвращает срок.
+	SetInvite(ctx context.Context, id int64, hash string, ttl time.Duration) (time.Time, error)
+	// ByInvite возвращает id активного менеджера с действующим приглашением.
+	ByInvite(ctx context.Context, hash string) (int64, error)
+	// SetPassword сохраняет хэш пароля и удаляет приглашение.
+	SetPassword(ctx context.Context, id int64, hash string) error
+	// Permissions возвращает права, которые дают роли активного менеджера.
+	Permissions(ctx context.Context, id int64) ([]string, error)
+	// RolesExist проверяет, что все роли заведены.
+	RolesExist(ctx context.Context, roles []string) (bool, error)
+}
+
+// Service управляет менеджерами.
+type Service struct {
+	tx         storage.Transactor
+	managers   ManagerRepo
+	policy     *passwords.Policy
+	normalizer *phones.Normalizer
+	bcryptCost int
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, managers ManagerRepo, policy *passwords.Policy, normalizer *phones.Normalizer, cfg *config.Config) *Service {
+	return &Service{tx: tx, managers: managers, policy: policy, normalizer: normalizer, bcryptCost: cfg.BcryptCost}
+}
+
+// RegisterManager добавляет менеджера с указанными ролями. Если пароль
+// задан, менеджер сразу может войти; иначе создаётся приглашение.
+// Телефон приводится к E.164; если это невозможно, возвращается phones.ErrInvalidPhone.
+func (s *Service) RegisterManager(ctx context.Context, item *Manager) (*Registration, error) {
+	item.Name, item.Phone = strings.TrimSpace(item.Name), strings.TrimSpace(item.Phone)
+	if item.Name == "" || item.Phone == "" || item.Salary < 0 {
+		return nil, ErrInvalidManager
+	}
+	if item.Plan < 0 {
+		return nil, ErrInvalidPlan
+	}
+	phone, err := s.normalizer.Normalize(item.Phone)
+	if err != nil {
+		return nil, err
+	}
+	item.Phone = phone
+	err = s.validateRoles(ctx, item.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash := ""
+	if item.Password != "" {
+		err = s.policy.Check(item.Password)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(item.Password), s.bcryptCost)
+		if err != nil {
+			log.Print(err)
+			return nil, ErrInternal
+		}
+		passwordHash = string(hash)
+	}
+
+	result := &Registration{Manager: item}
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if item.BossID != nil {
+			err := s.checkBoss(ctx, *item.BossID)
+			if err != nil {
+				return err
+			}
+		}
+		err := s.managers.Create(ctx, item, passwordHash)
+		if errors.Is(err, storage.ErrConflict) {
+			return ErrPhoneTaken
+		}
+		if err != nil {
+			return err
+		}
+		if passwordHash != "" {
+			return nil
+		}
+
+		token, hash, err := newInvite()
+		if err != nil {
+			return err
+		}
+		expires, err := s.managers.SetInvite(ctx, item.ID, hash, InviteTTL)
+		if err != nil {
+			return err
+		}
+		result.Invite = &Invite{Token: token, Expires: expires}
+		return nil
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	item.Password = ""
+	item.Active = true
+	return result, nil
+}
+
+// AcceptInvite задаёт пароль по приглашению и возвращает id менеджера.
+// Приглашение одноразовое, пароль проверяется по политике.
+func (s *Service) AcceptInvite(ctx context.Context, acceptance *Acceptance) (int64, error) {
+	err := s.policy.Check(acceptance.Password)
+	if err != nil {
+		return 0, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(acceptance.Password), s.bcryptCost)
+	if err != nil {
+		log.Print(err)
+		return 0, ErrInternal
+	}
+
+	var id int64
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		id, err = s.managers.ByInvite(ctx, hashInvite(acceptance.Invite))
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrInvalidInvite
+		}
+		if err != nil {
+			return err
+		}
+		return s.managers.SetPassword(ctx, id, string(hash))
+	})
+	if err != nil {
+		return 0, internal(err)
+	}
+	return id, nil
+}
+
+// Manager возвращает менеджера.
+func (s *Service) Manager(ctx context.Context, id int64) (*Manager, error) {
+	item, err := s.managers.ByID(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return item, nil
+}
+
+// Managers возвращает всех менеджеров, включая неактивных, в порядке id.
+func (s *Service) Managers(ctx context.Context) ([]*Manager, error) {
+	items, err := s.managers.List(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// Patch меняет переданные поля менеджера.
+func (s *Service) Patch(ctx context.Context, id int64, patch *Patch) (*Manager, error) {
+	return s.update(ctx, id, func(ctx context.Context, item *Manager) error {
+		if patch.Name != nil {
+			item.Name = strings.TrimSpace(*patch.Name)
+		}
+		if patch.Phone != nil {
+			phone, err := s.normalizer.Normalize(*patch.Phone)
+			if err != nil {
+				return err
+			}
+			item.Phone = phone
+		}
+		if patch.Department != nil {
+			item.Department = strings.TrimSpace(*patch.Department)
+		}
+		if patch.Salary != nil {
+			item.Salary = *patch.Salary
+		}
+		if patch.Plan != nil {
+			item.Plan = *patch.Plan
+		}
+		if item.Name == "" || item.Phone == "" || item.Salary < 0 {
+			return ErrInvalidManager
+		}
+		if item.Plan < 0 {
+			return ErrInvalidPlan
+		}
+		return nil
+	})
+}
+
+// SetRoles заменяет роли менеджера. Новые роли действуют со следующего запроса.
+func (s *Service) SetRoles(ctx context.Context, id int64, roles []string) (*Manager, error) {
+	err := s.validateRoles(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(ctx, id, func(ctx context.Context, item *Manager) error {
+		item.Roles = roles
+		return nil
+	})
+}
+
+// SetBoss назначает руководителя; nil убирает его. Руководитель должен быть
+// активен и не может быть самим менеджером или его подчинённым.
+func (s *Service) SetBoss(ctx context.Context, id int64, bossID *int64) (*Manager, error) {
+	return s.update(ctx, id, func(ctx context.Context, item *Manager) error {
+		item.BossID = bossID
+		if bossID == nil {
+			return nil
+		}
+		if *bossID == id {
+			return ErrHierarchyCycle
+		}
+		err := s.checkBoss(ctx, *bossID)
+		if err != nil {
+			return err
+		}
+		team, err := s.managers.Subordinates(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, member := range team {
+			if member.ID == *bossID {
+				return ErrHierarchyCycle
+			}
+		}
+		return nil
+	})
+}
+
+// Deactivate отключает менеджера: его токены перестают действовать, а прямые
+// подчинённые переходят к его руководителю.
+func (s *Service) Deactivate(ctx context.Context, id int64) (*Manager, error) {
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		item, err := s.managers.ByID(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		err = s.managers.ReassignSubordinates(ctx, id, item.BossID)
+		if err != nil {
+			return err
+		}
+		return s.managers.Deactivate(ctx, id)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.Manager(ctx, id)
+}
+
+// Team возвращает активных подчинённых менеджера на всех уровнях.
+func (s *Service) Team(ctx context.Context, id int64) ([]*TeamMember, error) {
+	items, err := s.managers.Subordinates(ctx, id)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	result := make([]*TeamMember, 0, len(items))
+	for _, item := range items {
+		if item.Active {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// IsSubordinate проверяет, что id входит в команду bossID на любом уровне.
+func (s *Service) IsSubordinate(ctx context.Context, bossID int64, id int64) (bool, error) {
+	items, err := s.managers.Subordinates(ctx, bossID)
+	if err != nil {
+		log.Print(err)
+		return false, ErrInternal
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// update загружает менеджера в транзакции, применяет change и сохраняет.
+func (s *Service) update(ctx context.Context, id int64, change func(ctx context.Context, item *Manager) error) (*Manager, error) {
+	var result *Manager
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		item, err := s.managers.ByID(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		err = change(ctx, item)
+		if err != nil {
+			return err
+		}
+		err = s.managers.Update(ctx, item)
+		if errors.Is(err, storage.ErrConflict) {
+			return ErrPhoneTaken
+		}
+		result = item
+		return err
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return result, nil
+}
+
+func (s *Service) checkBoss(ctx context.Context, bossID int64) error {
+	boss, err := s.managers.ByID(ctx, bossID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNoSuchBoss
+	}
+	if err != nil {
+		return err
+	}
+	if !boss.Active {
+		return ErrNoSuchBoss
+	}
+	return nil
+}
+
+// newInvite возвращает токен приглашения и его хэш для хранения.
+func newInvite() (string, string, error) {
+	buf := make([]byte, 24)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, hashInvite(token), nil
+}
+
+func hashInvite(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{
+		ErrNotFound, ErrRoles, ErrInvalidPlan, ErrInvalidManager, ErrPhoneTaken, ErrNoSuchBoss,
+		ErrHierarchyCycle, ErrInvalidInvite, passwords.ErrWeakPassword, phones.ErrInvalidPhone, ErrInternal,
+	} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
@@ -0,0 +1,134 @@
+package managers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+// manager добавляет активного менеджера с руководителем bossID (0 - без руководителя).
+func manager(t *testing.T, env *memtest.Env, phone string, bossID int64) int64 {
+	t.Helper()
+	item := &managers.Manager{Name: phone, Phone: phone}
+	if bossID != 0 {
+		item.BossID = &bossID
+	}
+	return env.Manager(t, item).ID
+}
+
+func boss(t *testing.T, env *memtest.Env, id int64) *int64 {
+	t.Helper()
+	item, err := env.Managers.Manager(context.Background(), id)
+	if err != nil {
+		t.Fatalf("manager %d: %v", id, err)
+	}
+	return item.BossID
+}
+
+func TestSetBoss(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	head := manager(t, env, "+992901000001", 0)
+	other := manager(t, env, "+992901000002", 0)
+	lead := manager(t, env, "+992901000003", head)
+
+	item, err := env.Managers.SetBoss(ctx, lead, &other)
+	if err != nil {
+		t.Fatalf("SetBoss: %v", err)
+	}
+	if item.BossID == nil || *item.BossID != other {
+		t.Errorf("boss = %v, want %d", item.BossID, other)
+	}
+	ok, err := env.Managers.IsSubordinate(ctx, other, lead)
+	if err != nil || !ok {
+		t.Errorf("IsSubordinate = %v, %v; want true", ok, err)
+	}
+
+	_, err = env.Managers.SetBoss(ctx, lead, nil)
+	if err != nil {
+		t.Fatalf("SetBoss(nil): %v", err)
+	}
+	if boss := boss(t, env, lead); boss != nil {
+		t.Errorf("boss = %d, want none", *boss)
+	}
+}
+
+func TestSetBossCycle(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	head := manager(t, env, "+992901000001", 0)
+	lead := manager(t, env, "+992901000002", head)
+	junior := manager(t, env, "+992901000003", lead)
+
+	tests := []struct {
+		name   string
+		id     int64
+		bossID int64
+	}{
+		{"self", lead, lead},
+		{"direct subordinate", head, lead},
+		{"indirect subordinate", head, junior},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := env.Managers.SetBoss(ctx, test.id, &test.bossID)
+			if !errors.Is(err, managers.ErrHierarchyCycle) {
+				t.Errorf("err = %v, want ErrHierarchyCycle", err)
+			}
+		})
+	}
+	if boss := boss(t, env, head); boss != nil {
+		t.Errorf("head boss = %d, want none", *boss)
+	}
+}
+
+func TestSetBossInvalid(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	head := manager(t, env, "+992901000001", 0)
+	former := manager(t, env, "+992901000002", 0)
+	lead := manager(t, env, "+992901000003", head)
+	missing := lead + 100
+
+	_, err := env.Managers.Deactivate(ctx, former)
+	if err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	for _, bossID := range []int64{former, missing} {
+		_, err = env.Managers.SetBoss(ctx, lead, &bossID)
+		if !errors.Is(err, managers.ErrNoSuchBoss) {
+			t.Errorf("boss %d: err = %v, want ErrNoSuchBoss", bossID, err)
+		}
+	}
+	if boss := boss(t, env, lead); boss == nil || *boss != head {
+		t.Errorf("boss = %v, want %d", boss, head)
+	}
+
+	_, err = env.Managers.SetBoss(ctx, missing, &head)
+	if !errors.Is(err, managers.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeactivateReassignsTeam(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	head := manager(t, env, "+992901000001", 0)
+	lead := manager(t, env, "+992901000002", head)
+	junior := manager(t, env, "+992901000003", lead)
+
+	_, err := env.Managers.Deactivate(ctx, lead)
+	if err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	if boss := boss(t, env, junior); boss == nil || *boss != head {
+		t.Errorf("junior boss = %v, want %d", boss, head)
+	}
+	team, err := env.Managers.Team(ctx, head)
+	if err != nil || len(team) != 1 || team[0].ID != junior {
+		t.Errorf("Team = %v, %v; want only the junior", team, err)
+	}
+}
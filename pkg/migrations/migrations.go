@@ -0,0 +1,299 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// lockID - ключ advisory lock'а, под которым выполняются миграции,
+// чтобы несколько одновременно стартующих экземпляров не мигрировали вместе.
+const lockID = 7_340_192_001
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrNoDown возвращается, когда у миграции нет down-файла.
+var ErrNoDown = errors.New("migration has no down file")
+
+// Migration - одна версия схемы.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - состояние одной миграции в БД.
+type Status struct {
+	Version int64      `json:"version"`
+	Name    string     `json:"name"`
+	Applied *time.Time `json:"applied"`
+}
+
+// Migrator применяет и откатывает миграции, вкомпилированные в бинарник.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []*Migration
+}
+
+// NewMigrator создаёт мигратор.
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Latest возвращает номер последней вкомпилированной миграции.
+func Latest() int64 {
+	migrations, err := load()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func load() ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := files.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up применяет все непримененные миграции и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	result := make([]*Migration, 0)
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = apply(ctx, conn, migration.Up, `
+				INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+			`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("migration %d_%s applied", migration.Version, migration.Name)
+			result = append(result, migration)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Down откатывает steps последних применённых миграций.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	result := make([]*Migration, 0)
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(result) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrNoDown)
+			}
+			err = apply(ctx, conn, migration.Down, `
+				DELETE FROM schema_migrations WHERE version = $1
+			`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("migration %d_%s reverted", migration.Version, migration.Name)
+			result = append(result, migration)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Status возвращает все известные миграции с датой применения.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	result := make([]*Status, 0, len(m.migrations))
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			item := &Status{Version: migration.Version, Name: migration.Name}
+			if at, ok := applied[migration.Version]; ok {
+				at := at
+				item.Applied = &at
+			}
+			result = append(result, item)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// locked выполняет fn на отдельном соединении под advisory lock'ом.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+
+	err = ensureTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable создаёт schema_migrations. Базы, созданные init-скриптами
+// до появления миграций, помечаются как находящиеся на версии 1.
+func ensureTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version     BIGINT              PRIMARY KEY,
+			applied     TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		return err
+	}
+
+	tag, err := conn.Exec(ctx, `
+		INSERT INTO schema_migrations (version, name)
+		SELECT 1, 'init'
+		WHERE to_regclass('customers') IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM schema_migrations)
+	`)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		log.Print("existing schema found, marked as migration 1")
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// apply выполняет SQL миграции и обновление schema_migrations в одной транзакции.
+func apply(ctx context.Context, conn *pgxpool.Conn, script string, record string, args ...interface{}) (err error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(ctx); rerr != nil && rerr != pgx.ErrTxClosed {
+				log.Print(rerr)
+			}
+			return
+		}
+		err = tx.Commit(ctx)
+	}()
+
+	// без аргументов pgx использует simple protocol, поэтому в файле
+	// может быть несколько выражений
+	_, err = tx.Exec(ctx, script)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, record, args...)
+	return err
+}
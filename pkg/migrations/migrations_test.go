@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+
+	// версии идут подряд с 1, иначе Down откатит не то, что ожидается
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if strings.TrimSpace(migration.Up) == "" {
+			t.Errorf("migration %d has an empty up file", migration.Version)
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %d has no down file", migration.Version)
+		}
+	}
+	if latest := Latest(); latest != int64(len(migrations)) {
+		t.Errorf("Latest = %d, want %d", latest, len(migrations))
+	}
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS sale_positions;
+DROP TABLE IF EXISTS sales;
+DROP TABLE IF EXISTS managers_tokens;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS purchases;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS customers_tokens;
+DROP TABLE IF EXISTS managers;
+DROP TABLE IF EXISTS customers;
@@ -1,3 +1,5 @@
+-- Исходная схема (docker-entrypoint-initdb.d/schema.sql до появления миграций).
+
 CREATE TABLE customers
 (
     id          BIGSERIAL           PRIMARY KEY,
@@ -88,4 +90,4 @@ CREATE TABLE sale_positions
     price       INTEGER             NOT NULL CHECK ( price >= 0 ),
     qty         INTEGER             NOT NULL DEFAULT 0 CHECK ( qty >= 0),
     created     TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
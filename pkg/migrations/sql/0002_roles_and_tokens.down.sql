@@ -0,0 +1,9 @@
+ALTER TABLE managers_tokens DROP COLUMN IF EXISTS refresh_expire;
+ALTER TABLE managers_tokens DROP COLUMN IF EXISTS refresh_token;
+ALTER TABLE managers_tokens DROP COLUMN IF EXISTS id;
+
+ALTER TABLE customers_tokens DROP COLUMN IF EXISTS refresh_expire;
+ALTER TABLE customers_tokens DROP COLUMN IF EXISTS refresh_token;
+ALTER TABLE customers_tokens DROP COLUMN IF EXISTS id;
+
+DROP TABLE IF EXISTS roles;
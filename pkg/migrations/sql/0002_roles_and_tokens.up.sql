@@ -0,0 +1,23 @@
+-- Роли с правами, идентификаторы и refresh-токены в таблицах токенов.
+-- IF NOT EXISTS - потому что базы, созданные init-скриптами, уже содержат эти изменения.
+
+CREATE TABLE IF NOT EXISTS roles
+(
+    name        TEXT                PRIMARY KEY,
+    permissions TEXT[]              NOT NULL DEFAULT '{}'
+);
+
+INSERT INTO roles (name, permissions) VALUES
+    ('MANAGER', '{"sales.read", "sales.write", "products.read"}'),
+    ('ADMIN', '{"products.read", "products.write", "customers.read", "customers.write", "managers.write"}')
+ON CONFLICT (name) DO NOTHING;
+
+-- token и refresh_token хранят SHA-256 (hex) от выданных токенов,
+-- сами токены в БД не попадают
+ALTER TABLE customers_tokens ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY;
+ALTER TABLE customers_tokens ADD COLUMN IF NOT EXISTS refresh_token TEXT UNIQUE;
+ALTER TABLE customers_tokens ADD COLUMN IF NOT EXISTS refresh_expire TIMESTAMP;
+
+ALTER TABLE managers_tokens ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY;
+ALTER TABLE managers_tokens ADD COLUMN IF NOT EXISTS refresh_token TEXT UNIQUE;
+ALTER TABLE managers_tokens ADD COLUMN IF NOT EXISTS refresh_expire TIMESTAMP;
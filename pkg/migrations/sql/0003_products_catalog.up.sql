@@ -0,0 +1,4 @@
+-- Индексы для сортировки и keyset-пагинации каталога.
+
+CREATE INDEX products_name_id_idx ON products (name, id);
+CREATE INDEX products_price_id_idx ON products (price, id);
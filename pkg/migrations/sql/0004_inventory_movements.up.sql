@@ -0,0 +1,25 @@
+-- Журнал движений товаров: каждое изменение products.qty записывается сюда.
+
+CREATE TABLE inventory_movements
+(
+    id          BIGSERIAL           PRIMARY KEY,
+    product_id  BIGINT              NOT NULL REFERENCES products,
+    kind        TEXT                NOT NULL CHECK ( kind IN ('initial', 'restock', 'sale', 'return', 'adjustment') ),
+    delta       INTEGER             NOT NULL CHECK ( delta <> 0 ),
+    qty_after   INTEGER             NOT NULL CHECK ( qty_after >= 0 ),
+    reason      TEXT                NOT NULL DEFAULT '',
+    actor_kind  TEXT,
+    actor_id    BIGINT,
+    sale_id     BIGINT              REFERENCES sales,
+    purchase_id BIGINT              REFERENCES purchases,
+    created     TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX inventory_movements_product_id_idx ON inventory_movements (product_id, id);
+
+-- Остатки, накопленные до появления журнала, записываются одним движением,
+-- чтобы сумма delta по товару совпадала с products.qty.
+INSERT INTO inventory_movements (product_id, kind, delta, qty_after, reason)
+SELECT id, 'initial', qty, qty, 'balance before inventory ledger'
+FROM products
+WHERE qty > 0;
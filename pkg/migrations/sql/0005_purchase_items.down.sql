@@ -0,0 +1,16 @@
+-- Из покупок с несколькими позициями сохраняется только первая.
+
+DROP INDEX IF EXISTS purchases_customer_id_idx;
+
+ALTER TABLE purchases
+    ADD COLUMN product_id BIGINT REFERENCES products,
+    ADD COLUMN name TEXT,
+    ADD COLUMN qty INTEGER,
+    ADD COLUMN price INTEGER;
+
+UPDATE purchases p
+SET product_id = i.product_id, name = i.name, qty = i.qty, price = i.price
+FROM (SELECT DISTINCT ON (purchase_id) * FROM purchase_items ORDER BY purchase_id, id) i
+WHERE i.purchase_id = p.id;
+
+DROP TABLE purchase_items;
@@ -0,0 +1,27 @@
+-- Покупка становится заказом с позициями: товар, количество и цена
+-- переезжают из purchases в purchase_items.
+
+CREATE TABLE purchase_items
+(
+    id          BIGSERIAL           PRIMARY KEY,
+    purchase_id BIGINT              NOT NULL REFERENCES purchases,
+    product_id  BIGINT              NOT NULL REFERENCES products,
+    name        TEXT                NOT NULL,
+    qty         INTEGER             NOT NULL CHECK ( qty > 0 ),
+    price       INTEGER             NOT NULL CHECK ( price > 0 )
+);
+
+CREATE INDEX purchase_items_purchase_id_idx ON purchase_items (purchase_id);
+
+INSERT INTO purchase_items (purchase_id, product_id, name, qty, price)
+SELECT id, product_id, name, qty, price
+FROM purchases
+WHERE product_id IS NOT NULL AND qty > 0;
+
+ALTER TABLE purchases
+    DROP COLUMN product_id,
+    DROP COLUMN name,
+    DROP COLUMN qty,
+    DROP COLUMN price;
+
+CREATE INDEX purchases_customer_id_idx ON purchases (customer_id, id);
@@ -0,0 +1,11 @@
+-- Корзина покупателя: по строке на товар, price - цена на момент добавления.
+
+CREATE TABLE cart_items
+(
+    customer_id BIGINT              NOT NULL REFERENCES customers,
+    product_id  BIGINT              NOT NULL REFERENCES products,
+    qty         INTEGER             NOT NULL CHECK ( qty > 0 ),
+    price       INTEGER             NOT NULL CHECK ( price > 0 ),
+    updated     TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, product_id)
+);
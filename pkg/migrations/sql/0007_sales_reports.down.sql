@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS sales_manager_id_created_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
@@ -0,0 +1,11 @@
+-- Месячный план менеджера. Приложение хранит менеджеров в users, поэтому
+-- план переносится из устаревшей таблицы managers (совпадение по телефону).
+
+ALTER TABLE users ADD COLUMN plan INTEGER NOT NULL DEFAULT 0 CHECK ( plan >= 0 );
+
+UPDATE users u
+SET plan = m.plan
+FROM managers m
+WHERE m.phone = u.phone AND m.plan >= 0;
+
+CREATE INDEX sales_manager_id_created_idx ON sales (manager_id, created);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS sale_positions_sale_id_idx;
+
+-- движения отменённых продаж остаются в журнале как возвраты
+UPDATE inventory_movements SET kind = 'return' WHERE kind = 'cancellation';
+
+ALTER TABLE inventory_movements DROP CONSTRAINT inventory_movements_kind_check;
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_kind_check
+    CHECK ( kind IN ('initial', 'restock', 'sale', 'return', 'adjustment') );
+
+ALTER TABLE sales
+    DROP COLUMN cancel_reason,
+    DROP COLUMN cancelled_by,
+    DROP COLUMN cancelled;
@@ -0,0 +1,13 @@
+-- Отмена продажи: строки продажи остаются, продажа помечается отменённой,
+-- а товар возвращается на склад движением cancellation.
+
+ALTER TABLE sales
+    ADD COLUMN cancelled     TIMESTAMP,
+    ADD COLUMN cancelled_by  BIGINT REFERENCES users,
+    ADD COLUMN cancel_reason TEXT;
+
+ALTER TABLE inventory_movements DROP CONSTRAINT inventory_movements_kind_check;
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_kind_check
+    CHECK ( kind IN ('initial', 'restock', 'sale', 'return', 'adjustment', 'cancellation') );
+
+CREATE INDEX sale_positions_sale_id_idx ON sale_positions (sale_id);
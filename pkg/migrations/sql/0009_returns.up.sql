@@ -0,0 +1,33 @@
+-- Возвраты по позициям продаж или покупок. Позиции исходных документов
+-- не меняются, возвращённое количество считается по return_items.
+
+CREATE TABLE returns
+(
+    id          BIGSERIAL           PRIMARY KEY,
+    manager_id  BIGINT              NOT NULL REFERENCES users,
+    sale_id     BIGINT              REFERENCES sales,
+    purchase_id BIGINT              REFERENCES purchases,
+    reason      TEXT                NOT NULL,
+    refund      BIGINT              NOT NULL CHECK ( refund >= 0 ),
+    created     TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ( (sale_id IS NULL) <> (purchase_id IS NULL) )
+);
+
+CREATE INDEX returns_sale_id_idx ON returns (sale_id);
+CREATE INDEX returns_purchase_id_idx ON returns (purchase_id);
+
+CREATE TABLE return_items
+(
+    id               BIGSERIAL      PRIMARY KEY,
+    return_id        BIGINT         NOT NULL REFERENCES returns,
+    sale_position_id BIGINT         REFERENCES sale_positions,
+    purchase_item_id BIGINT         REFERENCES purchase_items,
+    product_id       BIGINT         NOT NULL REFERENCES products,
+    qty              INTEGER        NOT NULL CHECK ( qty > 0 ),
+    refund           BIGINT         NOT NULL CHECK ( refund >= 0 ),
+    CHECK ( (sale_position_id IS NULL) <> (purchase_item_id IS NULL) )
+);
+
+CREATE INDEX return_items_return_id_idx ON return_items (return_id);
+CREATE INDEX return_items_sale_position_id_idx ON return_items (sale_position_id);
+CREATE INDEX return_items_purchase_item_id_idx ON return_items (purchase_item_id);
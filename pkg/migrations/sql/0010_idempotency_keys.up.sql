@@ -0,0 +1,17 @@
+-- Ответы на запросы с заголовком Idempotency-Key. Пока первый запрос
+-- выполняется, status, content_type и body пустые.
+
+CREATE TABLE idempotency_keys
+(
+    scope        TEXT                NOT NULL,
+    key          TEXT                NOT NULL,
+    fingerprint  TEXT                NOT NULL,
+    status       INTEGER,
+    content_type TEXT,
+    body         BYTEA,
+    created      TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires      TIMESTAMP           NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_idx ON idempotency_keys (expires);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS users_boss_id_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS invite_expires,
+    DROP COLUMN IF EXISTS invite_hash,
+    DROP COLUMN IF EXISTS department,
+    DROP COLUMN IF EXISTS boss_id;
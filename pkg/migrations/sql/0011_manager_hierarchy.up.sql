@@ -0,0 +1,23 @@
+-- Иерархия и приглашения менеджеров. Отдел, оклад и руководитель
+-- переносятся из устаревшей таблицы managers (совпадение по телефону).
+
+ALTER TABLE users
+    ADD COLUMN boss_id        BIGINT REFERENCES users CHECK ( boss_id <> id ),
+    ADD COLUMN department     TEXT NOT NULL DEFAULT '',
+    ADD COLUMN invite_hash    TEXT UNIQUE,
+    ADD COLUMN invite_expires TIMESTAMP;
+
+UPDATE users u
+SET department = coalesce(m.department, ''),
+    salary     = m.salary
+FROM managers m
+WHERE m.phone = u.phone;
+
+UPDATE users u
+SET boss_id = b.id
+FROM managers m
+JOIN managers mb ON mb.id = m.boss_id
+JOIN users b ON b.phone = mb.phone
+WHERE m.phone = u.phone AND b.id <> u.id;
+
+CREATE INDEX users_boss_id_idx ON users (boss_id);
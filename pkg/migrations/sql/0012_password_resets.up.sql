@@ -0,0 +1,15 @@
+-- Одноразовые коды сброса пароля, не больше одного на пользователя.
+-- code хранит SHA-256 (hex) от отправленного кода.
+
+CREATE TABLE password_resets
+(
+    kind     TEXT                NOT NULL CHECK (kind IN ('customer', 'manager')),
+    owner_id BIGINT              NOT NULL,
+    code     TEXT                NOT NULL,
+    attempts INTEGER             NOT NULL DEFAULT 0,
+    created  TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires  TIMESTAMP           NOT NULL,
+    PRIMARY KEY (kind, owner_id)
+);
+
+CREATE INDEX password_resets_expires_idx ON password_resets (expires);
@@ -0,0 +1,19 @@
+-- Outbox уведомлений. Строки добавляются в той же транзакции, что и
+-- бизнес-изменение, и рассылаются фоновым dispatcher'ом.
+
+CREATE TABLE notifications
+(
+    id             BIGSERIAL           PRIMARY KEY,
+    event          TEXT                NOT NULL,
+    recipient_kind TEXT                NOT NULL CHECK (recipient_kind IN ('customer', 'manager')),
+    recipient_id   BIGINT              NOT NULL,
+    text           TEXT                NOT NULL,
+    status         TEXT                NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts       INTEGER             NOT NULL DEFAULT 0,
+    next_attempt   TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error     TEXT,
+    created        TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent           TIMESTAMP
+);
+
+CREATE INDEX notifications_due_idx ON notifications (next_attempt) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+ALTER TABLE customers
+    DROP COLUMN IF EXISTS verify_expires,
+    DROP COLUMN IF EXISTS verify_attempts,
+    DROP COLUMN IF EXISTS verify_code,
+    DROP COLUMN IF EXISTS phone_verified;
@@ -0,0 +1,70 @@
+-- Телефоны приводятся к E.164 (без пробелов, скобок, точек и дефисов,
+-- "00" в начале заменяется на "+"). Номер, который после этого совпал
+-- бы с другим, остаётся как есть. Код страны по умолчанию задаётся
+-- конфигурацией приложения, поэтому номера без "+" здесь не дополняются.
+-- Если после приведения остались номера, которые не принял бы
+-- phones.Normalizer (не E.164, дубли, национальные номера, зарезервированные
+-- коды стран, неверная длина для своей страны), миграция завершается
+-- ошибкой со списком таких строк: их нужно исправить вручную и снова
+-- выполнить migrate up. Правила стран повторяют phones.rules и phones.reserved.
+-- Подтверждение телефона покупателя: verify_code хранит SHA-256 (hex)
+-- от отправленного кода.
+
+WITH normalized AS (
+    SELECT id, regexp_replace(regexp_replace(phone, '[[:space:]().-]', '', 'g'), '^00', '+') AS phone
+    FROM customers
+)
+UPDATE customers c
+SET phone = n.phone
+FROM normalized n
+WHERE n.id = c.id AND n.phone <> c.phone
+  AND (SELECT count(*) FROM normalized o WHERE o.phone = n.phone) = 1
+  AND NOT EXISTS (SELECT 1 FROM customers o WHERE o.phone = n.phone);
+
+WITH normalized AS (
+    SELECT id, regexp_replace(regexp_replace(phone, '[[:space:]().-]', '', 'g'), '^00', '+') AS phone
+    FROM users
+)
+UPDATE users u
+SET phone = n.phone
+FROM normalized n
+WHERE n.id = u.id AND n.phone <> u.phone
+  AND (SELECT count(*) FROM normalized o WHERE o.phone = n.phone) = 1
+  AND NOT EXISTS (SELECT 1 FROM users o WHERE o.phone = n.phone);
+
+DO $$
+DECLARE
+    invalid TEXT;
+BEGIN
+    WITH rules (code, lengths) AS (
+        VALUES ('992', '{9}'::INT[]), ('998', '{9}'), ('996', '{9}'), ('993', '{8}'),
+               ('994', '{9}'), ('995', '{9}'), ('374', '{8}'), ('375', '{9}'),
+               ('380', '{9}'), ('93', '{9}'), ('90', '{10}'), ('7', '{10}'), ('1', '{10}')
+    )
+    SELECT string_agg(format('%s %s: %s', p.tbl, p.id, p.phone), ', ' ORDER BY p.tbl, p.id) INTO invalid
+    FROM (
+        SELECT 'customers' AS tbl, id, phone FROM customers
+        UNION ALL
+        SELECT 'users', id, phone FROM users
+    ) p
+    WHERE p.phone !~ '^\+[1-9][0-9]{7,14}$'
+       OR substr(p.phone, 2, 3) IN ('990', '991', '997', '999')
+       OR NOT coalesce((
+            SELECT length(p.phone) - 1 - length(r.code) = ANY (r.lengths)
+            FROM rules r
+            WHERE substr(p.phone, 2, length(r.code)) = r.code
+            ORDER BY length(r.code) DESC
+            LIMIT 1
+        ), TRUE);
+
+    IF invalid IS NOT NULL THEN
+        RAISE EXCEPTION 'phones are not in E.164, fix them and run the migration again: %', invalid;
+    END IF;
+END
+$$;
+
+ALTER TABLE customers
+    ADD COLUMN phone_verified  TIMESTAMP,
+    ADD COLUMN verify_code     TEXT,
+    ADD COLUMN verify_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN verify_expires  TIMESTAMP;
@@ -0,0 +1,26 @@
+-- Неудачные попытки входа. key - "customer:<телефон>", "manager:<телефон>"
+-- или "ip:<адрес>"; пока locked_until в будущем, вход по ключу запрещён.
+
+CREATE TABLE login_attempts
+(
+    key          TEXT                PRIMARY KEY,
+    failures     INTEGER             NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX login_attempts_last_failure_idx ON login_attempts (last_failure);
+
+-- Журнал аудита событий безопасности.
+
+CREATE TABLE audit_events
+(
+    id       BIGSERIAL           PRIMARY KEY,
+    kind     TEXT                NOT NULL,
+    subject  TEXT                NOT NULL,
+    actor_id BIGINT              REFERENCES users ON DELETE SET NULL,
+    details  JSONB,
+    created  TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_events_kind_idx ON audit_events (kind, id);
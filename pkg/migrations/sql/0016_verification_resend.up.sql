@@ -0,0 +1,7 @@
+-- Когда покупателю отправлен последний код подтверждения телефона.
+-- Не сбрасывается вместе с кодом: новый код можно запросить только
+-- через verification_resend_cooldown после предыдущего.
+
+ALTER TABLE customers ADD COLUMN verify_sent TIMESTAMP;
+
+UPDATE customers SET verify_sent = CURRENT_TIMESTAMP WHERE verify_code IS NOT NULL;
@@ -0,0 +1 @@
+-- SHA-256 необратим: токены остаются хэшами и по-прежнему действуют.
@@ -0,0 +1,11 @@
+-- Токены старого формата (512 hex-символов без префикса) хранились в
+-- открытом виде. Они заменяются на SHA-256 (hex), как и новые токены,
+-- поэтому остаются действительными до истечения, но в БД больше не видны.
+
+UPDATE customers_tokens
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE length(token) = 512 AND position('_' IN token) = 0;
+
+UPDATE managers_tokens
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE length(token) = 512 AND position('_' IN token) = 0;
@@ -0,0 +1,102 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+)
+
+// Message - сообщение пользователю на его телефон.
+type Message struct {
+	Phone string
+	Text  string
+}
+
+// Notifier доставляет сообщения пользователям. Реализация для SMS-шлюза,
+// почты или webhook'а подключается в main вместо NewNotifier.
+type Notifier interface {
+	Notify(ctx context.Context, message *Message) error
+}
+
+// NewNotifier возвращает FileNotifier, если задан cfg.NotifierFile
+// (WriterNotifier на стандартный вывод для "-"), иначе LogNotifier.
+// Все они предназначены для разработки и тестов.
+func NewNotifier(cfg *config.Config) Notifier {
+	switch cfg.NotifierFile {
+	case "":
+		return NewLogNotifier()
+	case "-":
+		return NewWriterNotifier(os.Stdout)
+	default:
+		return NewFileNotifier(cfg.NotifierFile)
+	}
+}
+
+// LogNotifier пишет сообщения в лог.
+type LogNotifier struct{}
+
+// NewLogNotifier создаёт LogNotifier.
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, message *Message) error {
+	log.Printf("message to %s: %s", message.Phone, message.Text)
+	return nil
+}
+
+// WriterNotifier пишет сообщения в writer, по строке на сообщение:
+// время, телефон и текст через табуляцию.
+type WriterNotifier struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewWriterNotifier создаёт WriterNotifier.
+func NewWriterNotifier(writer io.Writer) *WriterNotifier {
+	return &WriterNotifier{writer: writer}
+}
+
+func (n *WriterNotifier) Notify(ctx context.Context, message *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return writeMessage(n.writer, message)
+}
+
+// FileNotifier дописывает сообщения в файл в формате WriterNotifier.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier создаёт FileNotifier; файл создаётся при первом сообщении.
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, message *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeMessage(file, message)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeMessage(writer io.Writer, message *Message) error {
+	_, err := fmt.Fprintf(writer, "%s\t%s\t%q\n", time.Now().Format(time.RFC3339), message.Phone, message.Text)
+	return err
+}
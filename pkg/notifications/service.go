@@ -0,0 +1,224 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// errNoRecipient - причина отказа, когда у получателя нет телефона
+// (например, покупатель удалён).
+var errNoRecipient = errors.New("recipient not found")
+
+// Recipient - тип получателя уведомления.
+type Recipient string
+
+const (
+	RecipientCustomer Recipient = "customer"
+	RecipientManager  Recipient = "manager"
+)
+
+// Статусы уведомлений в outbox.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Event - событие для получателя. Data подставляется в шаблон события.
+type Event struct {
+	Kind        EventKind
+	Recipient   Recipient
+	RecipientID int64
+	Data        map[string]interface{}
+}
+
+// Notification - уведомление в outbox. Text формируется при постановке
+// в очередь, телефон получателя - при отправке.
+type Notification struct {
+	ID          int64      `json:"id"`
+	Event       EventKind  `json:"event"`
+	Recipient   Recipient  `json:"recipient"`
+	RecipientID int64      `json:"recipient_id"`
+	Text        string     `json:"text"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	NextAttempt time.Time  `json:"next_attempt"`
+	LastError   string     `json:"last_error,omitempty"`
+	Created     time.Time  `json:"created"`
+	Sent        *time.Time `json:"sent,omitempty"`
+}
+
+// Pending - уведомление, которое пора отправить, вместе с телефоном
+// получателя (пустым, если получателя нет).
+type Pending struct {
+	Notification
+	Phone string
+}
+
+// OutboxRepo хранит очередь уведомлений. Если уведомление не найдено,
+// возвращает storage.ErrNotFound.
+type OutboxRepo interface {
+	// Add сохраняет уведомление и заполняет ID, Status, NextAttempt и Created.
+	Add(ctx context.Context, item *Notification) error
+	// Due возвращает до limit уведомлений, которые пора отправить, от старых
+	// к новым. Внутри транзакции они блокируются до её конца, а уведомления,
+	// заблокированные другой транзакцией, пропускаются.
+	Due(ctx context.Context, limit int) ([]*Pending, error)
+	MarkSent(ctx context.Context, id int64) error
+	// Retry увеличивает счётчик попыток и откладывает отправку на delay.
+	Retry(ctx context.Context, id int64, lastError string, delay time.Duration) error
+	// Fail увеличивает счётчик попыток и больше не отправляет уведомление.
+	Fail(ctx context.Context, id int64, lastError string) error
+}
+
+// Service ставит уведомления в outbox и рассылает их в фоне.
+//
+// Enqueue пишет в outbox через ctx, поэтому внутри транзакции уведомление
+// сохраняется вместе с бизнес-изменением или не сохраняется вовсе.
+// Доставка - "хотя бы один раз": если транзакция рассылки не зафиксируется
+// после отправки, сообщение уйдёт повторно.
+type Service struct {
+	tx          storage.Transactor
+	outbox      OutboxRepo
+	notifier    Notifier
+	batch       int
+	retryDelay  time.Duration
+	maxAttempts int
+}
+
+// NewService создаёт сервис и регистрирует в lc фоновую рассылку.
+func NewService(tx storage.Transactor, outbox OutboxRepo, notifier Notifier, cfg *config.Config, lc *lifecycle.Lifecycle) *Service {
+	s := &Service{
+		tx:          tx,
+		outbox:      outbox,
+		notifier:    notifier,
+		batch:       cfg.NotificationBatch,
+		retryDelay:  cfg.NotificationRetryDelay,
+		maxAttempts: cfg.NotificationMaxAttempts,
+	}
+	lc.Append(s.dispatcherHook(cfg.NotificationInterval))
+	return s
+}
+
+// Enqueue формирует текст уведомления по шаблону события и ставит его в outbox.
+func (s *Service) Enqueue(ctx context.Context, event *Event) error {
+	tmpl, ok := templates[event.Kind]
+	if !ok {
+		log.Printf("no template for event %q", event.Kind)
+		return ErrInternal
+	}
+	text := &strings.Builder{}
+	err := tmpl.Execute(text, event.Data)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+
+	err = s.outbox.Add(ctx, &Notification{
+		Event:       event.Kind,
+		Recipient:   event.Recipient,
+		RecipientID: event.RecipientID,
+		Text:        text.String(),
+	})
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// Dispatch отправляет одну пачку уведомлений и возвращает количество
+// отправленных. Неудачная попытка откладывается с удвоением задержки,
+// после maxAttempts попыток уведомление помечается failed.
+func (s *Service) Dispatch(ctx context.Context) (int, error) {
+	sent := 0
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		sent = 0
+		items, err := s.outbox.Due(ctx, s.batch)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			err = errNoRecipient
+			if item.Phone != "" {
+				err = s.notifier.Notify(ctx, &Message{Phone: item.Phone, Text: item.Text})
+			}
+			if err == nil {
+				sent++
+				err = s.outbox.MarkSent(ctx, item.ID)
+			} else if errors.Is(err, errNoRecipient) || item.Attempts+1 >= s.maxAttempts {
+				log.Printf("notification %d failed: %v", item.ID, err)
+				err = s.outbox.Fail(ctx, item.ID, err.Error())
+			} else {
+				err = s.outbox.Retry(ctx, item.ID, err.Error(), s.retryDelay<<item.Attempts)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Print(err)
+		return 0, ErrInternal
+	}
+	return sent, nil
+}
+
+// dispatcherHook запускает RunDispatcher на время жизни приложения.
+func (s *Service) dispatcherHook(interval time.Duration) lifecycle.Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	return lifecycle.Hook{
+		Name: "notification dispatcher",
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				s.RunDispatcher(ctx, interval)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
+
+// RunDispatcher раз в interval рассылает уведомления, пока не отменён ctx.
+// Полная пачка означает, что в очереди могут быть ещё уведомления,
+// и следующая рассылка начинается сразу.
+func (s *Service) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				count, err := s.Dispatch(ctx)
+				if err != nil || count < s.batch {
+					break
+				}
+			}
+		}
+	}
+}
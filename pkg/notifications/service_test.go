@@ -0,0 +1,143 @@
+package notifications_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/storage/memory"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+const phone = "+992901234567"
+
+// newEnv создаёт окружение, в котором первые failures сообщений не
+// отправляются, и покупателя phone без уведомления о регистрации.
+func newEnv(t *testing.T, failures int) (*memtest.Env, int64) {
+	t.Helper()
+	env := memtest.New(func(cfg *config.Config) {
+		cfg.NotificationMaxAttempts = 3
+		cfg.NotificationRetryDelay = time.Second
+	})
+	env.Notifier.Failures = failures
+
+	customer, err := memory.NewCustomerRepo(env.Store).Create(context.Background(), &customers.Customer{Name: "Ali", Phone: phone}, "")
+	if err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	return env, customer.ID
+}
+
+func enqueue(t *testing.T, env *memtest.Env, recipientID int64) {
+	t.Helper()
+	err := env.Notifications.Enqueue(context.Background(), &notifications.Event{
+		Kind:        notifications.EventCustomerRegistered,
+		Recipient:   notifications.RecipientCustomer,
+		RecipientID: recipientID,
+		Data:        map[string]interface{}{"Name": "Ali"},
+	})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+}
+
+// dispatch выполняет рассылку и проверяет количество отправленных
+// уведомлений и всех попыток отправки с начала теста.
+func dispatch(t *testing.T, env *memtest.Env, wantSent int, wantCalls int) {
+	t.Helper()
+	sent, err := env.Notifications.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if sent != wantSent || len(env.Notifier.Messages) != wantCalls {
+		t.Fatalf("sent %d with %d calls, want %d with %d", sent, len(env.Notifier.Messages), wantSent, wantCalls)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	env, customerID := newEnv(t, 0)
+	enqueue(t, env, customerID)
+
+	dispatch(t, env, 1, 1)
+	message := env.Notifier.Messages[0]
+	if message.Phone != phone || message.Text != "Ali, вы зарегистрированы. Добро пожаловать!" {
+		t.Errorf("message = %+v", message)
+	}
+	dispatch(t, env, 0, 1)
+}
+
+func TestDispatchRetry(t *testing.T) {
+	env, customerID := newEnv(t, 1)
+	enqueue(t, env, customerID)
+
+	dispatch(t, env, 0, 1)
+	dispatch(t, env, 0, 1)
+	env.Advance(env.Config.NotificationRetryDelay)
+	dispatch(t, env, 1, 2)
+}
+
+func TestDispatchFails(t *testing.T) {
+	env, customerID := newEnv(t, 10)
+	enqueue(t, env, customerID)
+
+	// задержка удваивается после каждой неудачи: 1s, затем 2s
+	dispatch(t, env, 0, 1)
+	env.Advance(time.Second)
+	dispatch(t, env, 0, 2)
+	env.Advance(time.Second)
+	dispatch(t, env, 0, 2)
+	env.Advance(time.Second)
+	dispatch(t, env, 0, 3)
+
+	// после NotificationMaxAttempts попыток уведомление больше не отправляется
+	env.Advance(time.Hour)
+	dispatch(t, env, 0, 3)
+}
+
+func TestDispatchNoRecipient(t *testing.T) {
+	env, customerID := newEnv(t, 0)
+	enqueue(t, env, customerID+100)
+
+	dispatch(t, env, 0, 0)
+	env.Advance(time.Hour)
+	dispatch(t, env, 0, 0)
+}
+
+func TestEnqueueRollback(t *testing.T) {
+	env, customerID := newEnv(t, 0)
+
+	err := env.Store.InTx(context.Background(), func(ctx context.Context) error {
+		err := env.Notifications.Enqueue(ctx, &notifications.Event{
+			Kind:        notifications.EventCustomerBlocked,
+			Recipient:   notifications.RecipientCustomer,
+			RecipientID: customerID,
+		})
+		if err != nil {
+			return err
+		}
+		return errors.New("business change failed")
+	})
+	if err == nil {
+		t.Fatal("InTx: want an error")
+	}
+	dispatch(t, env, 0, 0)
+}
+
+func TestEnqueueMissingData(t *testing.T) {
+	env, customerID := newEnv(t, 0)
+
+	err := env.Notifications.Enqueue(context.Background(), &notifications.Event{
+		Kind:        notifications.EventSaleReceipt,
+		Recipient:   notifications.RecipientCustomer,
+		RecipientID: customerID,
+		Data:        map[string]interface{}{"SaleID": 1},
+	})
+	if !errors.Is(err, notifications.ErrInternal) {
+		t.Errorf("err = %v, want ErrInternal", err)
+	}
+	dispatch(t, env, 0, 0)
+}
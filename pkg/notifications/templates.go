@@ -0,0 +1,36 @@
+package notifications
+
+import (
+	"text/template"
+)
+
+// EventKind - событие, о котором уведомляется пользователь.
+// У каждого события свой шаблон текста.
+type EventKind string
+
+const (
+	// EventCustomerRegistered - покупатель зарегистрировался. Данные: Name.
+	EventCustomerRegistered EventKind = "customer_registered"
+	// EventCustomerBlocked - покупатель заблокирован. Данные не нужны.
+	EventCustomerBlocked EventKind = "customer_blocked"
+	// EventCustomerUnblocked - покупатель разблокирован. Данные не нужны.
+	EventCustomerUnblocked EventKind = "customer_unblocked"
+	// EventSaleReceipt - чек продажи. Данные: SaleID, Items, Total.
+	EventSaleReceipt EventKind = "sale_receipt"
+	// EventSaleCancelled - продажа отменена. Данные: SaleID, Total.
+	EventSaleCancelled EventKind = "sale_cancelled"
+)
+
+// templates - тексты уведомлений. Если в данных нет поля, которое
+// использует шаблон, Enqueue вернёт ошибку.
+var templates = map[EventKind]*template.Template{
+	EventCustomerRegistered: parse(EventCustomerRegistered, `{{.Name}}, вы зарегистрированы. Добро пожаловать!`),
+	EventCustomerBlocked:    parse(EventCustomerBlocked, `Ваш аккаунт заблокирован. Обратитесь в магазин.`),
+	EventCustomerUnblocked:  parse(EventCustomerUnblocked, `Ваш аккаунт снова активен.`),
+	EventSaleReceipt:        parse(EventSaleReceipt, `Покупка №{{.SaleID}}: товаров {{.Items}}, сумма {{.Total}}. Спасибо!`),
+	EventSaleCancelled:      parse(EventSaleCancelled, `Покупка №{{.SaleID}} на сумму {{.Total}} отменена.`),
+}
+
+func parse(kind EventKind, text string) *template.Template {
+	return template.Must(template.New(string(kind)).Option("missingkey=error").Parse(text))
+}
@@ -0,0 +1,32 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Digits - количество цифр в одноразовом коде.
+const Digits = 6
+
+// Generate возвращает код из Digits случайных цифр.
+func Generate() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < Digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", Digits, n), nil
+}
+
+// Hash возвращает SHA-256 кода в hex - в хранилище попадает только он.
+// Перебор кода ограничивается счётчиком попыток, а не стойкостью хэша.
+func Hash(code string) string {
+	digest := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,76 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/Fanisabonu/http/pkg/config"
+)
+
+// ErrWeakPassword возвращается, когда пароль не соответствует политике.
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// MaxLength - максимальная длина пароля в байтах: bcrypt учитывает только первые 72.
+const MaxLength = 72
+
+// Нарушения политики, которые перечисляются в PolicyError.
+const (
+	ViolationTooShort = "too_short"
+	ViolationTooLong  = "too_long"
+	ViolationNoLetter = "no_letter"
+	ViolationNoDigit  = "no_digit"
+)
+
+// PolicyError перечисляет нарушенные правила политики.
+// errors.Is(err, ErrWeakPassword) для неё истинно.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return ErrWeakPassword.Error() + ": " + strings.Join(e.Violations, ", ")
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrWeakPassword.
+func (e *PolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Policy - требования к новым паролям. Существующие пароли
+// при входе не перепроверяются.
+type Policy struct {
+	MinLength     int
+	RequireLetter bool
+	RequireDigit  bool
+}
+
+// NewPolicy создаёт политику из конфигурации.
+func NewPolicy(cfg *config.Config) *Policy {
+	return &Policy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireLetter: cfg.PasswordRequireLetter,
+		RequireDigit:  cfg.PasswordRequireDigit,
+	}
+}
+
+// Check возвращает *PolicyError, если пароль нарушает политику.
+func (p *Policy) Check(password string) error {
+	violations := make([]string, 0)
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, ViolationTooShort)
+	}
+	if len(password) > MaxLength {
+		violations = append(violations, ViolationTooLong)
+	}
+	if p.RequireLetter && strings.IndexFunc(password, unicode.IsLetter) < 0 {
+		violations = append(violations, ViolationNoLetter)
+	}
+	if p.RequireDigit && strings.IndexFunc(password, unicode.IsDigit) < 0 {
+		violations = append(violations, ViolationNoDigit)
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package phones
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/config"
+)
+
+// ErrInvalidPhone возвращается, когда номер нельзя привести к E.164
+// или он не проходит правила своей страны.
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// Rule - длины национального номера (без кода страны) для кода страны.
+type Rule struct {
+	Code    string
+	Lengths []int
+}
+
+// rules - правила для стран, с которыми работает магазин. Номера остальных
+// стран проверяются только по общей длине E.164. Правила и reserved
+// повторены в миграции 0014: при изменении их нужно поменять и там.
+var rules = []Rule{
+	{Code: "992", Lengths: []int{9}}, // Таджикистан
+	{Code: "998", Lengths: []int{9}}, // Узбекистан
+	{Code: "996", Lengths: []int{9}}, // Киргизия
+	{Code: "993", Lengths: []int{8}}, // Туркмения
+	{Code: "994", Lengths: []int{9}}, // Азербайджан
+	{Code: "995", Lengths: []int{9}}, // Грузия
+	{Code: "374", Lengths: []int{8}}, // Армения
+	{Code: "375", Lengths: []int{9}}, // Беларусь
+	{Code: "380", Lengths: []int{9}}, // Украина
+	{Code: "93", Lengths: []int{9}},  // Афганистан
+	{Code: "90", Lengths: []int{10}}, // Турция
+	{Code: "7", Lengths: []int{10}},  // Россия, Казахстан
+	{Code: "1", Lengths: []int{10}},  // США, Канада
+}
+
+// reserved - коды стран, не выданные МСЭ, в зонах, с которыми работает магазин.
+var reserved = []string{"990", "991", "997", "999"}
+
+// Длины номера E.164 в цифрах вместе с кодом страны.
+const (
+	minDigits = 8
+	maxDigits = 15
+)
+
+// separators - символы, которые люди ставят внутри номера.
+var separators = strings.NewReplacer(" ", "", "\t", "", "-", "", "(", "", ")", "", ".", "")
+
+// Normalizer приводит номера к виду E.164: "+" и только цифры.
+type Normalizer struct {
+	defaultCode string
+}
+
+// NewNormalizer создаёт Normalizer. Номера без "+" и без "00" считаются
+// национальными номерами страны cfg.PhoneDefaultCountry.
+func NewNormalizer(cfg *config.Config) *Normalizer {
+	return &Normalizer{defaultCode: cfg.PhoneDefaultCountry}
+}
+
+// Normalize возвращает номер в виде E.164, например "+992 (90) 123-45-67"
+// превращается в "+992901234567". Если номер некорректен, возвращается ErrInvalidPhone.
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	value := separators.Replace(strings.TrimSpace(raw))
+	switch {
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	case strings.HasPrefix(value, "00"):
+		value = value[2:]
+	case n.defaultCode != "":
+		value = n.defaultCode + value
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if len(value) < minDigits || len(value) > maxDigits || value[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	for _, digit := range value {
+		if digit < '0' || digit > '9' {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	for _, code := range reserved {
+		if strings.HasPrefix(value, code) {
+			return "", ErrInvalidPhone
+		}
+	}
+	rule, ok := ruleFor(value)
+	if ok && !contains(rule.Lengths, len(value)-len(rule.Code)) {
+		return "", ErrInvalidPhone
+	}
+	return "+" + value, nil
+}
+
+// ruleFor ищет правило с самым длинным кодом страны, которым начинается номер.
+func ruleFor(digits string) (Rule, bool) {
+	var result Rule
+	found := false
+	for _, rule := range rules {
+		if strings.HasPrefix(digits, rule.Code) && len(rule.Code) > len(result.Code) {
+			result, found = rule, true
+		}
+	}
+	return result, found
+}
+
+func contains(values []int, value int) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
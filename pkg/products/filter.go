@@ -0,0 +1,108 @@
+package products
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Sort - порядок выдачи каталога. Минус перед полем - по убыванию.
+// При равенстве поля товары упорядочиваются по id в том же направлении.
+type Sort string
+
+// Поддерживаемые порядки.
+const (
+	SortID        Sort = "id"
+	SortName      Sort = "name"
+	SortNameDesc  Sort = "-name"
+	SortPrice     Sort = "price"
+	SortPriceDesc Sort = "-price"
+)
+
+// Field возвращает поле сортировки без направления.
+func (s Sort) Field() string {
+	if s.Desc() {
+		return string(s[1:])
+	}
+	return string(s)
+}
+
+// Desc сообщает, что сортировка по убыванию.
+func (s Sort) Desc() bool {
+	return len(s) > 0 && s[0] == '-'
+}
+
+func (s Sort) valid() bool {
+	switch s {
+	case SortID, SortName, SortNameDesc, SortPrice, SortPriceDesc:
+		return true
+	}
+	return false
+}
+
+// Filter - параметры выборки каталога.
+type Filter struct {
+	// Query ищет подстроку в названии без учёта регистра.
+	Query    string
+	MinPrice *int
+	MaxPrice *int
+	// IncludeInactive добавляет снятые с продажи товары (только для менеджеров).
+	IncludeInactive bool
+	Sort            Sort
+	Limit           int
+	// After - последний товар предыдущей страницы.
+	After *Cursor
+}
+
+func (f *Filter) normalize() error {
+	if f.Sort == "" {
+		f.Sort = SortID
+	}
+	if !f.Sort.valid() {
+		return ErrInvalidFilter
+	}
+	if f.Limit == 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		return ErrInvalidFilter
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// Cursor - позиция в каталоге: значения поля сортировки и id последнего товара.
+type Cursor struct {
+	Sort  Sort   `json:"s"`
+	ID    int64  `json:"id"`
+	Name  string `json:"n,omitempty"`
+	Price int    `json:"p,omitempty"`
+}
+
+func encodeCursor(sort Sort, last *Product) string {
+	cursor := &Cursor{Sort: sort, ID: last.ID}
+	switch sort.Field() {
+	case "name":
+		cursor.Name = last.Name
+	case "price":
+		cursor.Price = last.Price
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор. Курсор от другой сортировки считается некорректным.
+func decodeCursor(value string, sort Sort) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidFilter
+	}
+	cursor := &Cursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil || cursor.Sort != sort {
+		return nil, ErrInvalidFilter
+	}
+	return cursor, nil
+}
@@ -0,0 +1,230 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда товар не найден.
+var ErrNotFound = errors.New("product not found")
+
+// ErrInvalidProduct возвращается, когда у товара пустое название,
+// неположительная цена или отрицательный остаток.
+var ErrInvalidProduct = errors.New("invalid product")
+
+// ErrInvalidFilter возвращается при некорректных параметрах поиска или курсоре.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Ограничения размера страницы.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// Product продукты
+type Product struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Price  int    `json:"price"`
+	Qty    int    `json:"qty"`
+	Active bool   `json:"active"`
+}
+
+// Patch - частичное изменение товара: nil-поля не меняются.
+// Остаток здесь не меняется - для этого есть движения склада (inventory).
+type Patch struct {
+	Name   *string `json:"name"`
+	Price  *int    `json:"price"`
+	Active *bool   `json:"active"`
+}
+
+// Page - страница каталога. NextCursor пуст на последней странице.
+type Page struct {
+	Items      []*Product `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ProductRepo хранит товары. Если товар не найден, возвращает storage.ErrNotFound.
+type ProductRepo interface {
+	// List возвращает до filter.Limit товаров в порядке filter.Sort,
+	// начиная после filter.After.
+	List(ctx context.Context, filter *Filter) ([]*Product, error)
+	ByID(ctx context.Context, id int64) (*Product, error)
+	Create(ctx context.Context, item *Product) (*Product, error)
+	Update(ctx context.Context, item *Product) (*Product, error)
+}
+
+// Ledger проводит движения по складу (см. inventory.Service).
+type Ledger interface {
+	Record(ctx context.Context, item *inventory.Movement) (*inventory.Movement, error)
+}
+
+// Service управляет каталогом товаров.
+type Service struct {
+	tx       storage.Transactor
+	products ProductRepo
+	ledger   Ledger
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, products ProductRepo, ledger Ledger) *Service {
+	return &Service{tx: tx, products: products, ledger: ledger}
+}
+
+// List возвращает страницу каталога. Курсор для следующей страницы
+// строится по последнему товару, поэтому страницы не съезжают,
+// если между запросами товары добавляются или удаляются.
+func (s *Service) List(ctx context.Context, filter *Filter, cursor string) (*Page, error) {
+	err := filter.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if cursor != "" {
+		filter.After, err = decodeCursor(cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := *filter
+	query.Limit = filter.Limit + 1
+	items, err := s.products.List(ctx, &query)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	page := &Page{Items: items}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		page.NextCursor = encodeCursor(filter.Sort, page.Items[filter.Limit-1])
+	}
+	return page, nil
+}
+
+// ByID возвращает товар, в том числе снятый с продажи.
+func (s *Service) ByID(ctx context.Context, id int64) (*Product, error) {
+	item, err := s.products.ByID(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return item, nil
+}
+
+// Create добавляет товар в продажу. Идентификатор назначает хранилище.
+// Начальный остаток проводится через журнал как поступление.
+func (s *Service) Create(ctx context.Context, item *Product) (*Product, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	item.Active = true
+	err := validate(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Product
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		created := *item
+		created.Qty = 0
+		result, err = s.products.Create(ctx, &created)
+		if err != nil || item.Qty == 0 {
+			return err
+		}
+
+		movement, err := s.ledger.Record(ctx, &inventory.Movement{
+			ProductID: result.ID,
+			Kind:      inventory.KindRestock,
+			Delta:     item.Qty,
+			Reason:    "initial stock",
+		})
+		if err != nil {
+			return err
+		}
+		result.Qty = movement.QtyAfter
+		return nil
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return result, nil
+}
+
+// Replace заменяет все поля товара (PUT), кроме остатка.
+func (s *Service) Replace(ctx context.Context, item *Product) (*Product, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	err := validate(item)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(ctx, item)
+}
+
+// Patch меняет только переданные поля товара (PATCH).
+func (s *Service) Patch(ctx context.Context, id int64, patch *Patch) (*Product, error) {
+	var result *Product
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		item, err := s.ByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if patch.Name != nil {
+			item.Name = strings.TrimSpace(*patch.Name)
+		}
+		if patch.Price != nil {
+			item.Price = *patch.Price
+		}
+		if patch.Active != nil {
+			item.Active = *patch.Active
+		}
+		err = validate(item)
+		if err != nil {
+			return err
+		}
+
+		result, err = s.update(ctx, item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Remove снимает товар с продажи (active = false). Товар остаётся в БД,
+// чтобы на него продолжали ссылаться старые продажи и покупки.
+func (s *Service) Remove(ctx context.Context, id int64) (*Product, error) {
+	active := false
+	return s.Patch(ctx, id, &Patch{Active: &active})
+}
+
+func (s *Service) update(ctx context.Context, item *Product) (*Product, error) {
+	result, err := s.products.Update(ctx, item)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return result, nil
+}
+
+func validate(item *Product) error {
+	if item.Name == "" || item.Price <= 0 || item.Qty < 0 {
+		return ErrInvalidProduct
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package purchases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда в заказе указан несуществующий товар.
+var ErrNotFound = errors.New("product not found")
+
+// ErrInsufficientStock возвращается, когда товара на складе не хватает для покупки.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidQuantity возвращается, когда заказ пуст или в позиции указано некорректное количество.
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// ErrPhoneNotVerified возвращается, когда покупатель ещё не подтвердил телефон.
+var ErrPhoneNotVerified = errors.New("phone is not verified")
+
+// InsufficientStockError содержит идентификаторы товаров, которых не хватает
+// (или которые сняты с продажи). errors.Is(err, ErrInsufficientStock) для неё истинно.
+type InsufficientStockError struct {
+	ProductIDs []int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return ErrInsufficientStock.Error() + ": products " + strings.Join(ids, ", ")
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrInsufficientStock.
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// Purchase - покупка покупателя с позициями. Total - сумма price * qty по позициям.
+type Purchase struct {
+	ID         int64     `json:"id"`
+	CustomerID int64     `json:"customer_id"`
+	Created    time.Time `json:"created"`
+	Items      []*Item   `json:"items"`
+	Total      int       `json:"total"`
+}
+
+// Item - позиция покупки. Название и цена фиксируются на момент покупки.
+type Item struct {
+	ID         int64  `json:"id"`
+	PurchaseID int64  `json:"purchase_id"`
+	ProductID  int64  `json:"product_id"`
+	Name       string `json:"name"`
+	Price      int    `json:"price"`
+	Qty        int    `json:"qty"`
+}
+
+// Order - заказ покупателя: только товары и количества, цены берутся из каталога.
+type Order struct {
+	Items []*OrderItem `json:"items"`
+}
+
+// OrderItem - позиция заказа.
+type OrderItem struct {
+	ProductID int64 `json:"product_id"`
+	Qty       int   `json:"qty"`
+}
+
+// PurchaseRepo хранит покупки.
+type PurchaseRepo interface {
+	// Create сохраняет покупку без позиций и заполняет ID и Created.
+	Create(ctx context.Context, item *Purchase) error
+	// AddItem сохраняет позицию покупки и заполняет её ID.
+	AddItem(ctx context.Context, item *Item) error
+	// ByCustomer возвращает покупки покупателя вместе с позициями.
+	ByCustomer(ctx context.Context, customerID int64) ([]*Purchase, error)
+}
+
+// Catalog читает товары. Внутри транзакции строка товара блокируется до её конца.
+type Catalog interface {
+	ByID(ctx context.Context, id int64) (*products.Product, error)
+}
+
+// Ledger проводит движения по складу (см. inventory.Service).
+type Ledger interface {
+	Record(ctx context.Context, item *inventory.Movement) (*inventory.Movement, error)
+}
+
+// Verifier сообщает, подтвердил ли покупатель телефон (см. customers.Service).
+type Verifier interface {
+	IsPhoneVerified(ctx context.Context, id int64) (bool, error)
+}
+
+// Service оформляет покупки покупателей.
+type Service struct {
+	tx        storage.Transactor
+	purchases PurchaseRepo
+	catalog   Catalog
+	ledger    Ledger
+	verifier  Verifier
+	// verified - покупать можно только с подтверждённым телефоном.
+	verified bool
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, purchases PurchaseRepo, catalog Catalog, ledger Ledger, verifier Verifier, cfg *config.Config) *Service {
+	return &Service{tx: tx, purchases: purchases, catalog: catalog, ledger: ledger, verifier: verifier, verified: cfg.PhoneVerificationRequired}
+}
+
+// MakePurchase оформляет заказ покупателя в одной транзакции: блокирует товары
+// в порядке id, проверяет остатки, списывает их движениями журнала и записывает
+// покупку с позициями по текущим ценам каталога.
+// Если какого-то товара не хватает, возвращается *InsufficientStockError,
+// если покупатель не подтвердил телефон (а это требуется) - ErrPhoneNotVerified.
+func (s *Service) MakePurchase(ctx context.Context, customerID int64, order *Order) (*Purchase, error) {
+	requested := make(map[int64]int)
+	ids := make([]int64, 0, len(order.Items))
+	for _, value := range order.Items {
+		if value == nil || value.Qty <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		if _, ok := requested[value.ProductID]; !ok {
+			ids = append(ids, value.ProductID)
+		}
+		requested[value.ProductID] += value.Qty
+	}
+	if len(ids) == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if s.verified {
+		ok, err := s.verifier.IsPhoneVerified(ctx, customerID)
+		if err != nil {
+			log.Print(err)
+			return nil, ErrInternal
+		}
+		if !ok {
+			return nil, ErrPhoneNotVerified
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	result := &Purchase{CustomerID: customerID}
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		catalog := make(map[int64]*products.Product, len(ids))
+		shortage := make([]int64, 0)
+		for _, id := range ids {
+			product, err := s.catalog.ByID(ctx, id)
+			if errors.Is(err, storage.ErrNotFound) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if !product.Active || product.Qty < requested[id] {
+				shortage = append(shortage, id)
+			}
+			catalog[id] = product
+		}
+		if len(shortage) > 0 {
+			return &InsufficientStockError{ProductIDs: shortage}
+		}
+
+		err := s.purchases.Create(ctx, result)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			_, err = s.ledger.Record(ctx, &inventory.Movement{
+				ProductID:  id,
+				Kind:       inventory.KindSale,
+				Delta:      -requested[id],
+				ActorKind:  auth.KindCustomer,
+				ActorID:    &customerID,
+				PurchaseID: &result.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			item := &Item{
+				PurchaseID: result.ID,
+				ProductID:  id,
+				Name:       catalog[id].Name,
+				Price:      catalog[id].Price,
+				Qty:        requested[id],
+			}
+			err = s.purchases.AddItem(ctx, item)
+			if err != nil {
+				return err
+			}
+			result.Items = append(result.Items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	result.Total = total(result)
+	return result, nil
+}
+
+// Purchases возвращает покупки покупателя.
+func (s *Service) Purchases(ctx context.Context, customerID int64) ([]*Purchase, error) {
+	items, err := s.purchases.ByCustomer(ctx, customerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	for _, item := range items {
+		item.Total = total(item)
+	}
+	return items, nil
+}
+
+func total(purchase *Purchase) int {
+	result := 0
+	for _, item := range purchase.Items {
+		result += item.Price * item.Qty
+	}
+	return result
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{ErrNotFound, ErrInsufficientStock, ErrInvalidQuantity, ErrPhoneNotVerified, ErrInternal} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
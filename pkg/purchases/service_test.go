@@ -0,0 +1,115 @@
+package purchases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+func TestMakePurchase(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 5)
+	coffee := env.Product(t, "coffee", 250, 2)
+
+	purchase, err := env.Purchases.MakePurchase(context.Background(), customer.ID, &purchases.Order{
+		Items: []*purchases.OrderItem{{ProductID: coffee.ID, Qty: 1}, {ProductID: tea.ID, Qty: 2}, {ProductID: tea.ID, Qty: 1}},
+	})
+	if err != nil {
+		t.Fatalf("MakePurchase: %v", err)
+	}
+	if len(purchase.Items) != 2 || purchase.Total != 3*100+250 {
+		t.Errorf("purchase = %+v, want 2 items and total 550", purchase)
+	}
+	if qty := env.Qty(t, tea.ID); qty != 2 {
+		t.Errorf("tea qty = %d, want 2", qty)
+	}
+	if qty := env.Qty(t, coffee.ID); qty != 1 {
+		t.Errorf("coffee qty = %d, want 1", qty)
+	}
+
+	items, err := env.Purchases.Purchases(context.Background(), customer.ID)
+	if err != nil {
+		t.Fatalf("Purchases: %v", err)
+	}
+	if len(items) != 1 || items[0].Total != purchase.Total {
+		t.Errorf("purchases = %+v, want the new purchase", items)
+	}
+}
+
+func TestMakePurchasePhoneNotVerified(t *testing.T) {
+	env := memtest.New()
+	customer := env.Customer(t, "+992901234567", "Correct-Horse-42")
+	tea := env.Product(t, "tea", 100, 5)
+	order := &purchases.Order{Items: []*purchases.OrderItem{{ProductID: tea.ID, Qty: 1}}}
+
+	_, err := env.Purchases.MakePurchase(context.Background(), customer.ID, order)
+	if !errors.Is(err, purchases.ErrPhoneNotVerified) {
+		t.Fatalf("err = %v, want ErrPhoneNotVerified", err)
+	}
+	if qty := env.Qty(t, tea.ID); qty != 5 {
+		t.Errorf("qty = %d, want 5", qty)
+	}
+
+	env = memtest.New(func(cfg *config.Config) {
+		cfg.PhoneVerificationRequired = false
+	})
+	customer = env.Customer(t, "+992901234567", "Correct-Horse-42")
+	tea = env.Product(t, "tea", 100, 5)
+	order = &purchases.Order{Items: []*purchases.OrderItem{{ProductID: tea.ID, Qty: 1}}}
+	_, err = env.Purchases.MakePurchase(context.Background(), customer.ID, order)
+	if err != nil {
+		t.Errorf("MakePurchase without required verification: %v", err)
+	}
+}
+
+func TestMakePurchaseShortage(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 5)
+	coffee := env.Product(t, "coffee", 250, 1)
+
+	_, err := env.Purchases.MakePurchase(context.Background(), customer.ID, &purchases.Order{
+		Items: []*purchases.OrderItem{{ProductID: tea.ID, Qty: 1}, {ProductID: coffee.ID, Qty: 3}},
+	})
+	var shortage *purchases.InsufficientStockError
+	if !errors.As(err, &shortage) || len(shortage.ProductIDs) != 1 || shortage.ProductIDs[0] != coffee.ID {
+		t.Fatalf("err = %v, want shortage of product %d", err, coffee.ID)
+	}
+	if qty := env.Qty(t, tea.ID); qty != 5 {
+		t.Errorf("tea qty = %d, want 5", qty)
+	}
+	items, err := env.Purchases.Purchases(context.Background(), customer.ID)
+	if err != nil || len(items) != 0 {
+		t.Errorf("purchases = %v, %v; want none", items, err)
+	}
+}
+
+func TestMakePurchaseInvalid(t *testing.T) {
+	env := memtest.New()
+	customer := env.VerifiedCustomer(t, "+992901234567")
+	tea := env.Product(t, "tea", 100, 5)
+
+	tests := []struct {
+		name  string
+		items []*purchases.OrderItem
+		want  error
+	}{
+		{"empty", nil, purchases.ErrInvalidQuantity},
+		{"null item", []*purchases.OrderItem{nil}, purchases.ErrInvalidQuantity},
+		{"negative qty", []*purchases.OrderItem{{ProductID: tea.ID, Qty: -1}}, purchases.ErrInvalidQuantity},
+		{"unknown product", []*purchases.OrderItem{{ProductID: tea.ID + 100, Qty: 1}}, purchases.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := env.Purchases.MakePurchase(context.Background(), customer.ID, &purchases.Order{Items: test.items})
+			if !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда возврат не найден.
+var ErrNotFound = errors.New("return not found")
+
+// ErrNoSuchOrigin возвращается, когда продажа или покупка для возврата не найдена.
+var ErrNoSuchOrigin = errors.New("sale or purchase not found")
+
+// ErrInvalidReturn возвращается, когда в возврате не указан ровно один документ,
+// нет позиций или причины, количество неположительное или сумма возврата
+// больше стоимости позиции.
+var ErrInvalidReturn = errors.New("invalid return")
+
+// ErrUnknownPosition возвращается, когда позиция не относится к продаже или покупке.
+var ErrUnknownPosition = errors.New("position does not belong to the sale or purchase")
+
+// ErrQtyExceeded возвращается, когда возвращают больше, чем продано за вычетом прошлых возвратов.
+var ErrQtyExceeded = errors.New("returned quantity exceeds sold quantity")
+
+// ErrSaleCancelled возвращается при возврате по отменённой продаже.
+var ErrSaleCancelled = errors.New("sale is cancelled")
+
+// Ограничения размера страницы списка возвратов.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Return - возврат по продаже (SaleID) или покупке (PurchaseID). Refund -
+// сумма, возвращённая покупателю, равна сумме Refund позиций.
+type Return struct {
+	ID         int64     `json:"id"`
+	ManagerID  int64     `json:"manager_id"`
+	SaleID     *int64    `json:"sale_id,omitempty"`
+	PurchaseID *int64    `json:"purchase_id,omitempty"`
+	Reason     string    `json:"reason"`
+	Refund     int64     `json:"refund"`
+	Created    time.Time `json:"created"`
+	Items      []*Item   `json:"items"`
+}
+
+// Item - возвращённая позиция продажи (SalePositionID) или покупки (PurchaseItemID).
+type Item struct {
+	ID             int64  `json:"id"`
+	ReturnID       int64  `json:"return_id"`
+	SalePositionID *int64 `json:"sale_position_id,omitempty"`
+	PurchaseItemID *int64 `json:"purchase_item_id,omitempty"`
+	ProductID      int64  `json:"product_id"`
+	Qty            int64  `json:"qty"`
+	Refund         int64  `json:"refund"`
+}
+
+// Request - запрос на возврат. PositionID в позициях - id позиции продажи
+// или покупки; без Refund возвращается полная стоимость (price * qty).
+type Request struct {
+	SaleID     *int64         `json:"sale_id"`
+	PurchaseID *int64         `json:"purchase_id"`
+	Reason     string         `json:"reason"`
+	Items      []*RequestItem `json:"items"`
+}
+
+// RequestItem - позиция запроса на возврат.
+type RequestItem struct {
+	PositionID int64  `json:"position_id"`
+	Qty        int64  `json:"qty"`
+	Refund     *int64 `json:"refund"`
+}
+
+// Origin - продажа или покупка, по которой оформляется возврат.
+type Origin struct {
+	Cancelled bool
+	Lines     []*OriginLine
+}
+
+// OriginLine - позиция продажи или покупки. Returned - уже возвращённое количество.
+type OriginLine struct {
+	ID        int64
+	ProductID int64
+	Qty       int64
+	Price     int64
+	Returned  int64
+}
+
+// Filter - параметры списка возвратов; nil-поля не фильтруют.
+type Filter struct {
+	SaleID     *int64
+	PurchaseID *int64
+	Before     int64
+	Limit      int
+}
+
+// ReturnRepo хранит возвраты. Если документ не найден, возвращает storage.ErrNotFound.
+type ReturnRepo interface {
+	// Sale и Purchase возвращают позиции документа с уже возвращённым количеством;
+	// внутри транзакции строка документа блокируется до её конца.
+	Sale(ctx context.Context, id int64) (*Origin, error)
+	Purchase(ctx context.Context, id int64) (*Origin, error)
+	// Create сохраняет возврат без позиций и заполняет ID и Created.
+	Create(ctx context.Context, item *Return) error
+	// AddItem сохраняет позицию возврата и заполняет её ID.
+	AddItem(ctx context.Context, item *Item) error
+	ByID(ctx context.Context, id int64) (*Return, error)
+	// List возвращает до filter.Limit возвратов с id < filter.Before
+	// (0 - с последнего), от новых к старым.
+	List(ctx context.Context, filter *Filter) ([]*Return, error)
+}
+
+// Ledger проводит движения по складу (см. inventory.Service).
+type Ledger interface {
+	Record(ctx context.Context, item *inventory.Movement) (*inventory.Movement, error)
+}
+
+// Service оформляет возвраты.
+type Service struct {
+	tx      storage.Transactor
+	returns ReturnRepo
+	ledger  Ledger
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, returns ReturnRepo, ledger Ledger) *Service {
+	return &Service{tx: tx, returns: returns, ledger: ledger}
+}
+
+// Create оформляет возврат в одной транзакции: проверяет, что позиции
+// относятся к документу и не возвращены сверх проданного, записывает
+// возврат и возвращает товары на склад движениями return.
+func (s *Service) Create(ctx context.Context, managerID int64, request *Request) (*Return, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if (request.SaleID == nil) == (request.PurchaseID == nil) || reason == "" || len(request.Items) == 0 {
+		return nil, ErrInvalidReturn
+	}
+	for _, value := range request.Items {
+		if value == nil || value.Qty <= 0 || value.Refund != nil && *value.Refund < 0 {
+			return nil, ErrInvalidReturn
+		}
+	}
+
+	result := &Return{ManagerID: managerID, SaleID: request.SaleID, PurchaseID: request.PurchaseID, Reason: reason}
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var origin *Origin
+		var err error
+		if request.SaleID != nil {
+			origin, err = s.returns.Sale(ctx, *request.SaleID)
+		} else {
+			origin, err = s.returns.Purchase(ctx, *request.PurchaseID)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNoSuchOrigin
+		}
+		if err != nil {
+			return err
+		}
+		if origin.Cancelled {
+			return ErrSaleCancelled
+		}
+
+		lines := make(map[int64]*OriginLine, len(origin.Lines))
+		for _, line := range origin.Lines {
+			lines[line.ID] = line
+		}
+		items := make([]*Item, 0, len(request.Items))
+		for _, value := range request.Items {
+			line, ok := lines[value.PositionID]
+			if !ok {
+				return ErrUnknownPosition
+			}
+			line.Returned += value.Qty
+			if line.Returned > line.Qty {
+				return ErrQtyExceeded
+			}
+
+			item := &Item{ProductID: line.ProductID, Qty: value.Qty, Refund: line.Price * value.Qty}
+			if value.Refund != nil {
+				if *value.Refund > item.Refund {
+					return ErrInvalidReturn
+				}
+				item.Refund = *value.Refund
+			}
+			positionID := line.ID
+			if request.SaleID != nil {
+				item.SalePositionID = &positionID
+			} else {
+				item.PurchaseItemID = &positionID
+			}
+			result.Refund += item.Refund
+			items = append(items, item)
+		}
+
+		err = s.returns.Create(ctx, result)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			item.ReturnID = result.ID
+			err = s.returns.AddItem(ctx, item)
+			if err != nil {
+				return err
+			}
+			_, err = s.ledger.Record(ctx, &inventory.Movement{
+				ProductID:  item.ProductID,
+				Kind:       inventory.KindReturn,
+				Delta:      int(item.Qty),
+				Reason:     reason,
+				ActorKind:  auth.KindManager,
+				ActorID:    &managerID,
+				SaleID:     request.SaleID,
+				PurchaseID: request.PurchaseID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result.Items = items
+		return nil
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return result, nil
+}
+
+// Return возвращает возврат с позициями.
+func (s *Service) Return(ctx context.Context, id int64) (*Return, error) {
+	item, err := s.returns.ByID(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return item, nil
+}
+
+// Returns возвращает возвраты от новых к старым. Для следующей
+// страницы нужно передать Before = id последнего полученного возврата.
+func (s *Service) Returns(ctx context.Context, filter *Filter) ([]*Return, error) {
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxLimit || filter.Before < 0 {
+		return nil, ErrInvalidReturn
+	}
+
+	items, err := s.returns.List(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{
+		ErrNoSuchOrigin, ErrInvalidReturn, ErrUnknownPosition, ErrQtyExceeded, ErrSaleCancelled, ErrInternal,
+	} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
@@ -0,0 +1,120 @@
+package returns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+// sell продаёт qty единиц нового товара по цене 100 и возвращает товар и продажу.
+func sell(t *testing.T, env *memtest.Env, qty int64) (*products.Product, *sales.MakeSale) {
+	t.Helper()
+	product := env.Product(t, "tea", 100, 10)
+	sale, err := env.Sales.MakeSale(context.Background(), &sales.MakeSale{
+		ManagerID: 1,
+		Positions: []*sales.SalePosition{{ProductID: product.ID, Qty: qty, Price: 100}},
+	})
+	if err != nil {
+		t.Fatalf("MakeSale: %v", err)
+	}
+	return product, sale
+}
+
+func request(sale *sales.MakeSale, qty int64) *returns.Request {
+	return &returns.Request{
+		SaleID: &sale.ID,
+		Reason: "damaged",
+		Items:  []*returns.RequestItem{{PositionID: sale.Positions[0].ID, Qty: qty}},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	env := memtest.New()
+	product, sale := sell(t, env, 3)
+
+	item, err := env.Returns.Create(context.Background(), 1, request(sale, 2))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if item.Refund != 200 || len(item.Items) != 1 {
+		t.Errorf("return = %+v, want refund 200", item)
+	}
+	if qty := env.Qty(t, product.ID); qty != 10-3+2 {
+		t.Errorf("qty = %d, want 9", qty)
+	}
+}
+
+func TestCreateQtyExceeded(t *testing.T) {
+	env := memtest.New()
+	product, sale := sell(t, env, 3)
+	ctx := context.Background()
+
+	_, err := env.Returns.Create(ctx, 1, request(sale, 4))
+	if !errors.Is(err, returns.ErrQtyExceeded) {
+		t.Fatalf("err = %v, want ErrQtyExceeded", err)
+	}
+
+	// прошлые возвраты уменьшают количество, которое ещё можно вернуть
+	_, err = env.Returns.Create(ctx, 1, request(sale, 2))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, err = env.Returns.Create(ctx, 1, request(sale, 2))
+	if !errors.Is(err, returns.ErrQtyExceeded) {
+		t.Fatalf("err = %v, want ErrQtyExceeded", err)
+	}
+	if qty := env.Qty(t, product.ID); qty != 9 {
+		t.Errorf("qty = %d, want 9 after one return", qty)
+	}
+	_, err = env.Returns.Create(ctx, 1, request(sale, 1))
+	if err != nil {
+		t.Errorf("Create the rest: %v", err)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	env := memtest.New()
+	_, sale := sell(t, env, 3)
+	ctx := context.Background()
+	refund := int64(301)
+
+	tests := []struct {
+		name    string
+		request *returns.Request
+		want    error
+	}{
+		{"no document", &returns.Request{Reason: "damaged", Items: request(sale, 1).Items}, returns.ErrInvalidReturn},
+		{"no reason", &returns.Request{SaleID: &sale.ID, Items: request(sale, 1).Items}, returns.ErrInvalidReturn},
+		{"null item", &returns.Request{SaleID: &sale.ID, Reason: "damaged", Items: []*returns.RequestItem{nil}}, returns.ErrInvalidReturn},
+		{"refund above price", &returns.Request{SaleID: &sale.ID, Reason: "damaged", Items: []*returns.RequestItem{{PositionID: sale.Positions[0].ID, Qty: 3, Refund: &refund}}}, returns.ErrInvalidReturn},
+		{"unknown position", &returns.Request{SaleID: &sale.ID, Reason: "damaged", Items: []*returns.RequestItem{{PositionID: sale.Positions[0].ID + 100, Qty: 1}}}, returns.ErrUnknownPosition},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := env.Returns.Create(ctx, 1, test.request)
+			if !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateCancelledSale(t *testing.T) {
+	env := memtest.New()
+	_, sale := sell(t, env, 3)
+	ctx := context.Background()
+
+	_, err := env.Sales.Cancel(ctx, sale.ID, 1, &sales.Cancellation{Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	_, err = env.Returns.Create(ctx, 1, request(sale, 1))
+	if !errors.Is(err, returns.ErrSaleCancelled) {
+		t.Errorf("err = %v, want ErrSaleCancelled", err)
+	}
+}
@@ -0,0 +1,206 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInvalidReport возвращается при некорректном периоде или группировке отчёта.
+var ErrInvalidReport = errors.New("invalid report parameters")
+
+// ErrNoSuchManager возвращается, когда менеджер для отчёта не найден.
+var ErrNoSuchManager = errors.New("manager not found")
+
+// MaxReportDays ограничивает длину периода отчёта.
+const MaxReportDays = 366
+
+// Grouping - разрез отчёта.
+type Grouping string
+
+const (
+	GroupDay      Grouping = "day"
+	GroupWeek     Grouping = "week"
+	GroupMonth    Grouping = "month"
+	GroupProduct  Grouping = "product"
+	GroupCustomer Grouping = "customer"
+)
+
+// Period возвращает единицу date_trunc для временных разрезов и "" для остальных.
+func (g Grouping) Period() string {
+	switch g {
+	case GroupDay, GroupWeek, GroupMonth:
+		return string(g)
+	}
+	return ""
+}
+
+// ReportFilter - параметры отчёта. Период - полуинтервал [From, To).
+type ReportFilter struct {
+	ManagerID int64
+	From      time.Time
+	To        time.Time
+	GroupBy   Grouping
+}
+
+// Bucket - строка отчёта. Для временных разрезов Key - дата начала периода
+// (YYYY-MM-DD, неделя начинается с понедельника), для товаров и покупателей - id.
+type Bucket struct {
+	Key   string `json:"key"`
+	Name  string `json:"name,omitempty"`
+	Sales int64  `json:"sales"`
+	Qty   int64  `json:"qty"`
+	Total int64  `json:"total"`
+}
+
+// Report - отчёт менеджера за период. Plan - месячный план, пропорционально
+// пересчитанный на период, Completion - выполнение плана в процентах.
+type Report struct {
+	ManagerID  int64     `json:"manager_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	GroupBy    Grouping  `json:"group_by"`
+	Sales      int64     `json:"sales"`
+	Qty        int64     `json:"qty"`
+	Total      int64     `json:"total"`
+	Plan       int64     `json:"plan"`
+	Completion float64   `json:"plan_completion"`
+	Breakdown  []*Bucket `json:"breakdown"`
+}
+
+// Standing - место менеджера в рейтинге. MonthlyPlan - план из профиля.
+type Standing struct {
+	ManagerID   int64   `json:"manager_id"`
+	Name        string  `json:"name"`
+	Sales       int64   `json:"sales"`
+	Total       int64   `json:"total"`
+	MonthlyPlan int64   `json:"monthly_plan"`
+	Plan        int64   `json:"plan"`
+	Completion  float64 `json:"plan_completion"`
+}
+
+// Leaderboard - рейтинг менеджеров за период по сумме продаж.
+type Leaderboard struct {
+	From     time.Time   `json:"from"`
+	To       time.Time   `json:"to"`
+	Standing []*Standing `json:"standing"`
+}
+
+// ReportRepo считает итоги продаж. Учитываются позиции продаж с created в [From, To).
+type ReportRepo interface {
+	// Summary возвращает итог по менеджеру без разбивки (Key пустой).
+	Summary(ctx context.Context, filter *ReportFilter) (*Bucket, error)
+	// Breakdown группирует продажи менеджера по filter.GroupBy: временные
+	// разрезы - по возрастанию даты, остальные - по убыванию суммы.
+	Breakdown(ctx context.Context, filter *ReportFilter) ([]*Bucket, error)
+	// Leaderboard возвращает активных менеджеров (роль MANAGER) с итогами
+	// за период по убыванию суммы.
+	Leaderboard(ctx context.Context, from time.Time, to time.Time) ([]*Standing, error)
+	// Plan возвращает месячный план менеджера или storage.ErrNotFound.
+	Plan(ctx context.Context, managerID int64) (int64, error)
+}
+
+// Report строит отчёт менеджера за период по выбранному разрезу.
+// Пустой разрез означает разбивку по дням.
+func (s *Service) Report(ctx context.Context, filter *ReportFilter) (*Report, error) {
+	if filter.GroupBy == "" {
+		filter.GroupBy = GroupDay
+	}
+	switch filter.GroupBy {
+	case GroupDay, GroupWeek, GroupMonth, GroupProduct, GroupCustomer:
+	default:
+		return nil, ErrInvalidReport
+	}
+	err := validatePeriod(filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.reports.Plan(ctx, filter.ManagerID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoSuchManager
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	summary, err := s.reports.Summary(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	breakdown, err := s.reports.Breakdown(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	report := &Report{
+		ManagerID: filter.ManagerID,
+		From:      filter.From,
+		To:        filter.To,
+		GroupBy:   filter.GroupBy,
+		Sales:     summary.Sales,
+		Qty:       summary.Qty,
+		Total:     summary.Total,
+		Plan:      proratedPlan(plan, filter.From, filter.To),
+		Breakdown: breakdown,
+	}
+	report.Completion = completion(report.Total, report.Plan)
+	return report, nil
+}
+
+// Leaderboard возвращает рейтинг менеджеров за период [from, to).
+func (s *Service) Leaderboard(ctx context.Context, from time.Time, to time.Time) (*Leaderboard, error) {
+	err := validatePeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.reports.Leaderboard(ctx, from, to)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	for _, item := range items {
+		item.Plan = proratedPlan(item.MonthlyPlan, from, to)
+		item.Completion = completion(item.Total, item.Plan)
+	}
+	return &Leaderboard{From: from, To: to, Standing: items}, nil
+}
+
+func validatePeriod(from time.Time, to time.Time) error {
+	if !from.Before(to) || to.Sub(from) > MaxReportDays*24*time.Hour {
+		return ErrInvalidReport
+	}
+	return nil
+}
+
+// proratedPlan пересчитывает месячный план на период [from, to): каждый
+// день периода добавляет plan / (число дней в его месяце).
+func proratedPlan(plan int64, from time.Time, to time.Time) int64 {
+	total := 0.0
+	for start := from; start.Before(to); {
+		monthStart := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+		next := monthStart.AddDate(0, 1, 0)
+		end := next
+		if to.Before(end) {
+			end = to
+		}
+		days := next.Sub(monthStart).Hours() / 24
+		total += float64(plan) * end.Sub(start).Hours() / 24 / days
+		start = end
+	}
+	return int64(total + 0.5)
+}
+
+// completion возвращает процент выполнения плана с точностью до сотых.
+func completion(total int64, plan int64) float64 {
+	if plan <= 0 {
+		return 0
+	}
+	return float64(total*10000/plan) / 100
+}
@@ -0,0 +1,383 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNotFound возвращается, когда в продаже указан несуществующий товар.
+var ErrNotFound = errors.New("item not found")
+
+// ErrInsufficientStock возвращается, когда товара на складе не хватает для продажи.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidQuantity возвращается, когда в позиции указано некорректное количество.
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// ErrInvalidPrice возвращается, когда в позиции указана отрицательная цена.
+var ErrInvalidPrice = errors.New("invalid price")
+
+// ErrNoSuchSale возвращается, когда продажа не найдена.
+var ErrNoSuchSale = errors.New("sale not found")
+
+// ErrAlreadyCancelled возвращается при повторной отмене продажи.
+var ErrAlreadyCancelled = errors.New("sale is already cancelled")
+
+// ErrInvalidCancellation возвращается, когда отмена не содержит причины.
+var ErrInvalidCancellation = errors.New("cancellation reason is required")
+
+// ErrInvalidSaleFilter возвращается при некорректных параметрах списка продаж.
+var ErrInvalidSaleFilter = errors.New("invalid sale filter")
+
+// Ограничения размера страницы списка продаж.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// InsufficientStockError содержит идентификаторы товаров, которых не хватает
+// (или которые сняты с продажи). errors.Is(err, ErrInsufficientStock) для неё истинно.
+type InsufficientStockError struct {
+	ProductIDs []int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return ErrInsufficientStock.Error() + ": products " + strings.Join(ids, ", ")
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrInsufficientStock.
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// SalePosition - позиция продажи. Returned - количество, возвращённое
+// по позиции (см. пакет returns), заполняется при чтении.
+type SalePosition struct {
+	ID        int64 `json:"id"`
+	ProductID int64 `json:"product_id"`
+	SaleID    int64 `json:"sale_id"`
+	Qty       int64 `json:"qty"`
+	Price     int64 `json:"price"`
+	Returned  int64 `json:"returned"`
+}
+
+// MakeSale - продажа. Поля отмены заполнены только у отменённых продаж,
+// Total считается по позициям при чтении.
+type MakeSale struct {
+	ID           int64           `json:"id"`
+	ManagerID    int64           `json:"manager_id"`
+	CustomerID   int64           `json:"customer_id"`
+	Created      time.Time       `json:"created"`
+	Positions    []*SalePosition `json:"positions"`
+	Total        int64           `json:"total"`
+	Cancelled    *time.Time      `json:"cancelled,omitempty"`
+	CancelledBy  *int64          `json:"cancelled_by,omitempty"`
+	CancelReason string          `json:"cancel_reason,omitempty"`
+}
+
+// Cancellation - запрос на отмену продажи.
+type Cancellation struct {
+	Reason string `json:"reason"`
+}
+
+// SaleFilter - параметры списка продаж. ManagerID = 0 - продажи всех менеджеров,
+// nil-поля не фильтруют. Период - полуинтервал [From, To).
+type SaleFilter struct {
+	ManagerID  int64
+	CustomerID *int64
+	ProductID  *int64
+	From       *time.Time
+	To         *time.Time
+	Before     int64
+	Limit      int
+}
+
+// Stock - остаток товара на складе.
+type Stock struct {
+	ProductID int64
+	Qty       int64
+	Active    bool
+}
+
+// SaleRepo хранит продажи. Если продажа не найдена, возвращает storage.ErrNotFound.
+type SaleRepo interface {
+	// Create сохраняет продажу без позиций и заполняет ID и Created.
+	Create(ctx context.Context, item *MakeSale) error
+	// AddPosition сохраняет позицию продажи и заполняет её ID.
+	AddPosition(ctx context.Context, item *SalePosition) error
+	// ByID возвращает продажу с позициями; внутри транзакции строка продажи
+	// блокируется до её конца.
+	ByID(ctx context.Context, id int64) (*MakeSale, error)
+	// List возвращает до filter.Limit продаж с позициями и id < filter.Before
+	// (0 - с последней), от новых к старым. ProductID оставляет продажи,
+	// в которых есть этот товар.
+	List(ctx context.Context, filter *SaleFilter) ([]*MakeSale, error)
+	// Cancel помечает продажу отменённой.
+	Cancel(ctx context.Context, id int64, managerID int64, reason string) error
+}
+
+// StockRepo читает остатки товаров.
+type StockRepo interface {
+	// LockStock возвращает остатки товаров, упорядоченные по id. Внутри
+	// транзакции строки блокируются до её конца.
+	LockStock(ctx context.Context, ids []int64) ([]*Stock, error)
+}
+
+// Ledger проводит движения по складу (см. inventory.Service).
+type Ledger interface {
+	Record(ctx context.Context, item *inventory.Movement) (*inventory.Movement, error)
+}
+
+// Outbox ставит уведомления в очередь в текущей транзакции (см. notifications.Service).
+type Outbox interface {
+	Enqueue(ctx context.Context, event *notifications.Event) error
+}
+
+// Service оформляет продажи менеджеров.
+type Service struct {
+	tx      storage.Transactor
+	sales   SaleRepo
+	stock   StockRepo
+	ledger  Ledger
+	reports ReportRepo
+	outbox  Outbox
+}
+
+// NewService создаёт сервис.
+func NewService(tx storage.Transactor, sales SaleRepo, stock StockRepo, ledger Ledger, reports ReportRepo, outbox Outbox) *Service {
+	return &Service{tx: tx, sales: sales, stock: stock, ledger: ledger, reports: reports, outbox: outbox}
+}
+
+// MakeSale оформляет продажу в одной транзакции: блокирует строки товаров,
+// проверяет остатки, списывает их движениями журнала, записывает продажу с позициями
+// и ставит в очередь чек покупателю. При любой ошибке транзакция откатывается целиком.
+// Если какого-то товара не хватает, возвращается *InsufficientStockError.
+func (s *Service) MakeSale(ctx context.Context, item *MakeSale) (*MakeSale, error) {
+	requested := make(map[int64]int64)
+	ids := make([]int64, 0, len(item.Positions))
+	for _, value := range item.Positions {
+		if value == nil || value.Qty <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		if value.Price < 0 {
+			return nil, ErrInvalidPrice
+		}
+		if _, ok := requested[value.ProductID]; !ok {
+			ids = append(ids, value.ProductID)
+		}
+		requested[value.ProductID] += value.Qty
+	}
+	if len(ids) == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	item.Cancelled, item.CancelledBy, item.CancelReason = nil, nil, ""
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		stock, err := s.stock.LockStock(ctx, ids)
+		if err != nil {
+			return err
+		}
+		found := make(map[int64]bool)
+		shortage := make([]int64, 0)
+		for _, value := range stock {
+			found[value.ProductID] = true
+			if !value.Active || value.Qty < requested[value.ProductID] {
+				shortage = append(shortage, value.ProductID)
+			}
+		}
+		for _, id := range ids {
+			if !found[id] {
+				return ErrNotFound
+			}
+		}
+		if len(shortage) > 0 {
+			return &InsufficientStockError{ProductIDs: shortage}
+		}
+
+		err = s.sales.Create(ctx, item)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			_, err = s.ledger.Record(ctx, &inventory.Movement{
+				ProductID: id,
+				Kind:      inventory.KindSale,
+				Delta:     -int(requested[id]),
+				ActorKind: auth.KindManager,
+				ActorID:   &item.ManagerID,
+				SaleID:    &item.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, value := range item.Positions {
+			value.SaleID = item.ID
+			err = s.sales.AddPosition(ctx, value)
+			if err != nil {
+				return err
+			}
+		}
+		item.Total = total(item)
+		return s.notify(ctx, notifications.EventSaleReceipt, item)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return item, nil
+}
+
+// Sale возвращает продажу с позициями.
+func (s *Service) Sale(ctx context.Context, id int64) (*MakeSale, error) {
+	item, err := s.sales.ByID(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoSuchSale
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	item.Total = total(item)
+	return item, nil
+}
+
+// Sales возвращает страницу продаж от новых к старым. Для следующей
+// страницы нужно передать Before = id последней полученной продажи.
+func (s *Service) Sales(ctx context.Context, filter *SaleFilter) ([]*MakeSale, error) {
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxLimit || filter.Before < 0 {
+		return nil, ErrInvalidSaleFilter
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidSaleFilter
+	}
+
+	items, err := s.sales.List(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	for _, item := range items {
+		item.Total = total(item)
+	}
+	return items, nil
+}
+
+// Cancel отменяет продажу в одной транзакции: возвращает товары на склад
+// движениями cancellation (кроме уже возвращённого количества) и помечает
+// продажу отменённой. Позиции и сама продажа остаются в БД.
+func (s *Service) Cancel(ctx context.Context, id int64, managerID int64, cancellation *Cancellation) (*MakeSale, error) {
+	reason := strings.TrimSpace(cancellation.Reason)
+	if reason == "" {
+		return nil, ErrInvalidCancellation
+	}
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		item, err := s.sales.ByID(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNoSuchSale
+		}
+		if err != nil {
+			return err
+		}
+		if item.Cancelled != nil {
+			return ErrAlreadyCancelled
+		}
+
+		returned := make(map[int64]int64)
+		ids := make([]int64, 0, len(item.Positions))
+		for _, position := range item.Positions {
+			if _, ok := returned[position.ProductID]; !ok {
+				ids = append(ids, position.ProductID)
+			}
+			returned[position.ProductID] += position.Qty - position.Returned
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i] < ids[j]
+		})
+		for _, productID := range ids {
+			if returned[productID] == 0 {
+				continue
+			}
+			_, err = s.ledger.Record(ctx, &inventory.Movement{
+				ProductID: productID,
+				Kind:      inventory.KindCancellation,
+				Delta:     int(returned[productID]),
+				Reason:    reason,
+				ActorKind: auth.KindManager,
+				ActorID:   &managerID,
+				SaleID:    &item.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = s.sales.Cancel(ctx, id, managerID, reason)
+		if err != nil {
+			return err
+		}
+		return s.notify(ctx, notifications.EventSaleCancelled, item)
+	})
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.Sale(ctx, id)
+}
+
+// notify уведомляет покупателя о продаже, если он указан.
+func (s *Service) notify(ctx context.Context, kind notifications.EventKind, item *MakeSale) error {
+	if item.CustomerID == 0 {
+		return nil
+	}
+	var items int64
+	for _, position := range item.Positions {
+		items += position.Qty
+	}
+	return s.outbox.Enqueue(ctx, &notifications.Event{
+		Kind:        kind,
+		Recipient:   notifications.RecipientCustomer,
+		RecipientID: item.CustomerID,
+		Data:        map[string]interface{}{"SaleID": item.ID, "Items": items, "Total": total(item)},
+	})
+}
+
+func total(item *MakeSale) int64 {
+	var result int64
+	for _, position := range item.Positions {
+		result += position.Qty * position.Price
+	}
+	return result
+}
+
+// internal пропускает ошибки сервиса как есть, а ошибки хранилища
+// логирует и заменяет на ErrInternal.
+func internal(err error) error {
+	for _, known := range []error{
+		ErrNotFound, ErrInsufficientStock, ErrInvalidQuantity, ErrNoSuchSale, ErrAlreadyCancelled, ErrInternal,
+	} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Print(err)
+	return ErrInternal
+}
@@ -0,0 +1,130 @@
+package sales_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage/memory"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+// failingOutbox не даёт поставить уведомление в очередь, чтобы проверить
+// откат продажи на последнем шаге транзакции.
+type failingOutbox struct{}
+
+func (failingOutbox) Enqueue(ctx context.Context, event *notifications.Event) error {
+	return errors.New("outbox is unavailable")
+}
+
+func TestMakeSale(t *testing.T) {
+	env := memtest.New()
+	tea := env.Product(t, "tea", 100, 5)
+
+	sale, err := env.Sales.MakeSale(context.Background(), &sales.MakeSale{
+		ManagerID: 1,
+		Positions: []*sales.SalePosition{{ProductID: tea.ID, Qty: 2, Price: 100}, {ProductID: tea.ID, Qty: 1, Price: 100}},
+	})
+	if err != nil {
+		t.Fatalf("MakeSale: %v", err)
+	}
+	if sale.ID == 0 || sale.Positions[0].SaleID != sale.ID {
+		t.Errorf("sale = %+v, want saved positions", sale)
+	}
+	if qty := env.Qty(t, tea.ID); qty != 2 {
+		t.Errorf("qty = %d, want 2", qty)
+	}
+}
+
+func TestMakeSaleShortage(t *testing.T) {
+	env := memtest.New()
+	tea := env.Product(t, "tea", 100, 5)
+	coffee := env.Product(t, "coffee", 100, 1)
+	manager := env.Manager(t, &managers.Manager{Name: "Bob", Phone: "+992901000001"})
+	ctx := context.Background()
+
+	_, err := env.Sales.MakeSale(ctx, &sales.MakeSale{
+		ManagerID: manager.ID,
+		Positions: []*sales.SalePosition{{ProductID: tea.ID, Qty: 2, Price: 100}, {ProductID: coffee.ID, Qty: 2, Price: 100}},
+	})
+	var shortage *sales.InsufficientStockError
+	if !errors.As(err, &shortage) || !errors.Is(err, sales.ErrInsufficientStock) {
+		t.Fatalf("err = %v, want InsufficientStockError", err)
+	}
+	if len(shortage.ProductIDs) != 1 || shortage.ProductIDs[0] != coffee.ID {
+		t.Errorf("shortage = %v, want [%d]", shortage.ProductIDs, coffee.ID)
+	}
+
+	// продажа откатывается целиком: остатки и итог менеджера не меняются
+	if qty := env.Qty(t, tea.ID); qty != 5 {
+		t.Errorf("tea qty = %d, want 5", qty)
+	}
+	history, err := env.Ledger.History(ctx, tea.ID, 0, 0)
+	if err != nil || len(history) != 1 {
+		t.Errorf("History = %d movements, %v; want only the initial restock", len(history), err)
+	}
+	report, err := env.Sales.Report(ctx, &sales.ReportFilter{ManagerID: manager.ID, From: env.Now().Add(-time.Hour), To: env.Now().Add(time.Hour)})
+	if err != nil || report.Sales != 0 {
+		t.Errorf("Report = %+v, %v; want no sales", report, err)
+	}
+}
+
+func TestMakeSaleRollback(t *testing.T) {
+	env := memtest.New()
+	tea := env.Product(t, "tea", 100, 5)
+	service := sales.NewService(env.Store, memory.NewSaleRepo(env.Store), memory.NewProductRepo(env.Store), env.Ledger, memory.NewReportRepo(env.Store), failingOutbox{})
+	ctx := context.Background()
+
+	// чек покупателю ставится в очередь последним, после списания и позиций
+	_, err := service.MakeSale(ctx, &sales.MakeSale{
+		ManagerID:  1,
+		CustomerID: 1,
+		Positions:  []*sales.SalePosition{{ProductID: tea.ID, Qty: 2, Price: 100}},
+	})
+	if !errors.Is(err, sales.ErrInternal) {
+		t.Fatalf("err = %v, want ErrInternal", err)
+	}
+	if qty := env.Qty(t, tea.ID); qty != 5 {
+		t.Errorf("qty = %d, want 5", qty)
+	}
+	items, err := service.Sales(ctx, &sales.SaleFilter{})
+	if err != nil || len(items) != 0 {
+		t.Errorf("Sales = %d, %v; want none", len(items), err)
+	}
+	history, err := env.Ledger.History(ctx, tea.ID, 0, 0)
+	if err != nil || len(history) != 1 {
+		t.Errorf("History = %d movements, %v; want only the initial restock", len(history), err)
+	}
+}
+
+func TestMakeSaleInvalid(t *testing.T) {
+	env := memtest.New()
+	tea := env.Product(t, "tea", 100, 5)
+
+	tests := []struct {
+		name      string
+		positions []*sales.SalePosition
+		want      error
+	}{
+		{"empty", nil, sales.ErrInvalidQuantity},
+		{"null position", []*sales.SalePosition{nil}, sales.ErrInvalidQuantity},
+		{"zero qty", []*sales.SalePosition{{ProductID: tea.ID}}, sales.ErrInvalidQuantity},
+		{"negative price", []*sales.SalePosition{{ProductID: tea.ID, Qty: 1, Price: -100}}, sales.ErrInvalidPrice},
+		{"unknown product", []*sales.SalePosition{{ProductID: tea.ID + 100, Qty: 1}}, sales.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := env.Sales.MakeSale(context.Background(), &sales.MakeSale{ManagerID: 1, Positions: test.positions})
+			if !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+	if qty := env.Qty(t, tea.ID); qty != 5 {
+		t.Errorf("qty = %d, want 5", qty)
+	}
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// AttemptRepo хранит неудачные попытки входа в Store.
+type AttemptRepo struct {
+	store *Store
+}
+
+// NewAttemptRepo создаёт репозиторий.
+func NewAttemptRepo(store *Store) *AttemptRepo {
+	return &AttemptRepo{store: store}
+}
+
+func (r *AttemptRepo) Blocked(ctx context.Context, keys []string) (time.Duration, error) {
+	now := r.store.Now()
+	var result time.Duration
+	err := r.store.do(ctx, func(d *data) error {
+		for _, key := range keys {
+			if item, ok := d.attempts[key]; ok && item.LockedUntil.Sub(now) > result {
+				result = item.LockedUntil.Sub(now)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *AttemptRepo) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	now := r.store.Now()
+	var result int
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.attempts[key]
+		if !ok {
+			item = &auth.Lockout{Key: key}
+			d.attempts[key] = item
+		}
+		if !item.LastFailure.After(now.Add(-window)) {
+			item.Failures = 0
+		}
+		item.Failures++
+		item.LastFailure = now
+		result = item.Failures
+		return nil
+	})
+	return result, err
+}
+
+func (r *AttemptRepo) Attempt(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	now := r.store.Now()
+	var failures int
+	var blocked time.Duration
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.attempts[key]
+		if !ok {
+			item = &auth.Lockout{Key: key}
+			d.attempts[key] = item
+		}
+		if item.LockedUntil.After(now) {
+			failures = item.Failures
+			blocked = item.LockedUntil.Sub(now)
+			return nil
+		}
+		if !item.LastFailure.After(now.Add(-window)) {
+			item.Failures = 0
+		}
+		item.Failures++
+		item.LastFailure = now
+		failures = item.Failures
+		return nil
+	})
+	return failures, blocked, err
+}
+
+func (r *AttemptRepo) Forgive(ctx context.Context, key string) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.attempts[key]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if item.Failures > 0 {
+			item.Failures--
+		}
+		item.LockedUntil = time.Time{}
+		return nil
+	})
+}
+
+func (r *AttemptRepo) Lock(ctx context.Context, key string, duration time.Duration) error {
+	until := r.store.Now().Add(duration)
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.attempts[key]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.LockedUntil = until
+		return nil
+	})
+}
+
+func (r *AttemptRepo) Reset(ctx context.Context, key string) error {
+	return r.store.do(ctx, func(d *data) error {
+		if _, ok := d.attempts[key]; !ok {
+			return storage.ErrNotFound
+		}
+		delete(d.attempts, key)
+		return nil
+	})
+}
+
+func (r *AttemptRepo) Locked(ctx context.Context) ([]*auth.Lockout, error) {
+	now := r.store.Now()
+	items := make([]*auth.Lockout, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.attempts {
+			if item.LockedUntil.After(now) {
+				value := *item
+				items = append(items, &value)
+			}
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].LockedUntil.Equal(items[j].LockedUntil) {
+			return items[i].LockedUntil.After(items[j].LockedUntil)
+		}
+		return items[i].Key < items[j].Key
+	})
+	return items, err
+}
+
+func (r *AttemptRepo) RemoveStale(ctx context.Context, window time.Duration) (int64, error) {
+	now := r.store.Now()
+	var count int64
+	err := r.store.do(ctx, func(d *data) error {
+		for key, item := range d.attempts {
+			if !item.LastFailure.After(now.Add(-window)) && !item.LockedUntil.After(now) {
+				delete(d.attempts, key)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+var _ auth.AttemptRepo = (*AttemptRepo)(nil)
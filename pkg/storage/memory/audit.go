@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+)
+
+// AuditRepo хранит журнал аудита в Store.
+type AuditRepo struct {
+	store *Store
+}
+
+// NewAuditRepo создаёт репозиторий.
+func NewAuditRepo(store *Store) *AuditRepo {
+	return &AuditRepo{store: store}
+}
+
+func (r *AuditRepo) Add(ctx context.Context, item *audit.Event) error {
+	now := r.store.Now()
+	return r.store.do(ctx, func(d *data) error {
+		item.ID, item.Created = d.nextID(), now
+		value := *item
+		d.audit[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *AuditRepo) List(ctx context.Context, filter *audit.Filter) ([]*audit.Event, error) {
+	items := make([]*audit.Event, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.audit {
+			if filter.Kind != "" && item.Kind != filter.Kind {
+				continue
+			}
+			if filter.Before != 0 && item.ID >= filter.Before {
+				continue
+			}
+			value := *item
+			items = append(items, &value)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID > items[j].ID
+	})
+	if len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, err
+}
+
+var _ audit.EventRepo = (*AuditRepo)(nil)
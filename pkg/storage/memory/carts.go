@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// CartRepo хранит корзины в Store.
+type CartRepo struct {
+	store *Store
+}
+
+// NewCartRepo создаёт репозиторий.
+func NewCartRepo(store *Store) *CartRepo {
+	return &CartRepo{store: store}
+}
+
+func (r *CartRepo) Lines(ctx context.Context, customerID int64) ([]*carts.Line, error) {
+	items := make([]*carts.Line, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.carts[customerID] {
+			value := *item
+			items = append(items, &value)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ProductID < items[j].ProductID
+	})
+	return items, err
+}
+
+func (r *CartRepo) SetLine(ctx context.Context, customerID int64, line *carts.Line) error {
+	return r.store.do(ctx, func(d *data) error {
+		if d.carts[customerID] == nil {
+			d.carts[customerID] = make(map[int64]*carts.Line)
+		}
+		line.Updated = r.store.Now()
+		d.carts[customerID][line.ProductID] = &carts.Line{
+			ProductID: line.ProductID,
+			Qty:       line.Qty,
+			Price:     line.Price,
+			Updated:   line.Updated,
+		}
+		return nil
+	})
+}
+
+func (r *CartRepo) RemoveLine(ctx context.Context, customerID int64, productID int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		if _, ok := d.carts[customerID][productID]; !ok {
+			return storage.ErrNotFound
+		}
+		delete(d.carts[customerID], productID)
+		return nil
+	})
+}
+
+func (r *CartRepo) Clear(ctx context.Context, customerID int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		delete(d.carts, customerID)
+		return nil
+	})
+}
+
+var _ carts.CartRepo = (*CartRepo)(nil)
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// CredentialRepo ищет учётные данные покупателей и менеджеров в Store.
+type CredentialRepo struct {
+	store *Store
+}
+
+// NewCredentialRepo создаёт репозиторий.
+func NewCredentialRepo(store *Store) *CredentialRepo {
+	return &CredentialRepo{store: store}
+}
+
+func (r *CredentialRepo) CredentialsByPhone(ctx context.Context, kind auth.Kind, phone string) (*auth.Credentials, error) {
+	var result *auth.Credentials
+	err := r.store.do(ctx, func(d *data) error {
+		if kind == auth.KindManager {
+			if item := d.managerByPhone(phone); item != nil {
+				result = &auth.Credentials{ID: item.ID, PasswordHash: item.passwordHash, Active: item.Active}
+			}
+		} else if item := d.customerByPhone(phone); item != nil {
+			result = &auth.Credentials{ID: item.ID, PasswordHash: item.passwordHash, Active: item.Active}
+		}
+		if result == nil {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *CredentialRepo) CredentialsByID(ctx context.Context, kind auth.Kind, id int64) (*auth.Credentials, error) {
+	var result *auth.Credentials
+	err := r.store.do(ctx, func(d *data) error {
+		if kind == auth.KindManager {
+			if item, ok := d.managers[id]; ok {
+				result = &auth.Credentials{ID: item.ID, PasswordHash: item.passwordHash, Active: item.Active}
+			}
+		} else if item, ok := d.customers[id]; ok {
+			result = &auth.Credentials{ID: item.ID, PasswordHash: item.passwordHash, Active: item.Active}
+		}
+		if result == nil {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *CredentialRepo) SetPassword(ctx context.Context, kind auth.Kind, id int64, hash string) error {
+	return r.store.do(ctx, func(d *data) error {
+		if kind == auth.KindManager {
+			item, ok := d.managers[id]
+			if !ok {
+				return storage.ErrNotFound
+			}
+			item.passwordHash, item.inviteHash, item.inviteExpires = hash, "", time.Time{}
+			return nil
+		}
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.passwordHash = hash
+		return nil
+	})
+}
+
+var _ auth.CredentialRepo = (*CredentialRepo)(nil)
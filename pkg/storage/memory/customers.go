@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// CustomerRepo хранит покупателей в Store.
+type CustomerRepo struct {
+	store *Store
+}
+
+// NewCustomerRepo создаёт репозиторий.
+func NewCustomerRepo(store *Store) *CustomerRepo {
+	return &CustomerRepo{store: store}
+}
+
+func (r *CustomerRepo) list(ctx context.Context, activeOnly bool) ([]*customers.Customer, error) {
+	items := make([]*customers.Customer, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.customers {
+			if activeOnly && !item.Active {
+				continue
+			}
+			value := item.Customer
+			items = append(items, &value)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items, err
+}
+
+func (r *CustomerRepo) All(ctx context.Context) ([]*customers.Customer, error) {
+	return r.list(ctx, false)
+}
+
+func (r *CustomerRepo) AllActive(ctx context.Context) ([]*customers.Customer, error) {
+	return r.list(ctx, true)
+}
+
+func (r *CustomerRepo) ByID(ctx context.Context, id int64) (*customers.Customer, error) {
+	var result *customers.Customer
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		value := item.Customer
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *CustomerRepo) Create(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	var result *customers.Customer
+	err := r.store.do(ctx, func(d *data) error {
+		if d.customerByPhone(item.Phone) != nil {
+			return storage.ErrConflict
+		}
+		result = d.addCustomer(item, passwordHash, r.store)
+		return nil
+	})
+	return result, err
+}
+
+func (r *CustomerRepo) Upsert(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	var result *customers.Customer
+	err := r.store.do(ctx, func(d *data) error {
+		if existing := d.customerByPhone(item.Phone); existing != nil {
+			existing.Name = item.Name
+			value := existing.Customer
+			result = &value
+			return nil
+		}
+		result = d.addCustomer(item, passwordHash, r.store)
+		return nil
+	})
+	return result, err
+}
+
+func (r *CustomerRepo) Update(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	var result *customers.Customer
+	err := r.store.do(ctx, func(d *data) error {
+		existing, ok := d.customers[item.ID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if other := d.customerByPhone(item.Phone); other != nil && other.ID != item.ID {
+			return storage.ErrConflict
+		}
+		existing.Name = item.Name
+		if existing.Phone != item.Phone {
+			existing.Phone = item.Phone
+			existing.PhoneVerified = false
+			existing.verification = nil
+		}
+		if passwordHash != "" {
+			existing.passwordHash = passwordHash
+		}
+		value := existing.Customer
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *CustomerRepo) Remove(ctx context.Context, id int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		if _, ok := d.customers[id]; !ok {
+			return storage.ErrNotFound
+		}
+		delete(d.customers, id)
+		return nil
+	})
+}
+
+func (r *CustomerRepo) SetActive(ctx context.Context, id int64, active bool) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.Active = active
+		return nil
+	})
+}
+
+func (r *CustomerRepo) SetVerification(ctx context.Context, id int64, hash string, ttl time.Duration, cooldown time.Duration) (time.Duration, error) {
+	now := r.store.Now()
+	var wait time.Duration
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if next := item.verificationSent.Add(cooldown); next.After(now) {
+			wait = next.Sub(now)
+			return nil
+		}
+		item.verification = &verification{Verification: customers.Verification{Hash: hash}, expires: now.Add(ttl)}
+		item.verificationSent = now
+		return nil
+	})
+	return wait, err
+}
+
+func (r *CustomerRepo) Verification(ctx context.Context, id int64) (*customers.Verification, error) {
+	now := r.store.Now()
+	var result *customers.Verification
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok || item.verification == nil {
+			return storage.ErrNotFound
+		}
+		value := item.verification.Verification
+		value.Expired = !item.verification.expires.After(now)
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *CustomerRepo) FailVerification(ctx context.Context, id int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok || item.verification == nil {
+			return storage.ErrNotFound
+		}
+		item.verification.Attempts++
+		return nil
+	})
+}
+
+func (r *CustomerRepo) ClearVerification(ctx context.Context, id int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.verification = nil
+		return nil
+	})
+}
+
+func (r *CustomerRepo) MarkVerified(ctx context.Context, id int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.customers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.PhoneVerified = true
+		item.verification = nil
+		return nil
+	})
+}
+
+func (d *data) customerByPhone(phone string) *customer {
+	for _, item := range d.customers {
+		if item.Phone == phone {
+			return item
+		}
+	}
+	return nil
+}
+
+func (d *data) addCustomer(item *customers.Customer, passwordHash string, store *Store) *customers.Customer {
+	created := &customer{
+		Customer: customers.Customer{
+			ID:      d.nextID(),
+			Name:    item.Name,
+			Phone:   item.Phone,
+			Active:  true,
+			Created: store.Now(),
+		},
+		passwordHash: passwordHash,
+	}
+	d.customers[created.ID] = created
+	value := created.Customer
+	return &value
+}
+
+var _ customers.CustomerRepo = (*CustomerRepo)(nil)
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// IdempotencyRepo хранит ключи идемпотентности в Store.
+type IdempotencyRepo struct {
+	store *Store
+}
+
+// NewIdempotencyRepo создаёт репозиторий.
+func NewIdempotencyRepo(store *Store) *IdempotencyRepo {
+	return &IdempotencyRepo{store: store}
+}
+
+func (r *IdempotencyRepo) Reserve(ctx context.Context, item *idempotency.Record) error {
+	now := r.store.Now()
+	return r.store.do(ctx, func(d *data) error {
+		id := idempotencyID(item.Scope, item.Key)
+		if existing, ok := d.idempotency[id]; ok && existing.expires.After(now) {
+			return storage.ErrConflict
+		}
+		d.idempotency[id] = &idempotencyKey{
+			Record:  idempotency.Record{Scope: item.Scope, Key: item.Key, Fingerprint: item.Fingerprint},
+			expires: now.Add(item.TTL),
+		}
+		return nil
+	})
+}
+
+func (r *IdempotencyRepo) ByKey(ctx context.Context, scope string, key string) (*idempotency.Record, error) {
+	now := r.store.Now()
+	var result *idempotency.Record
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.idempotency[idempotencyID(scope, key)]
+		if !ok || !item.expires.After(now) {
+			return storage.ErrNotFound
+		}
+		value := item.Record
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *IdempotencyRepo) Complete(ctx context.Context, scope string, key string, response *idempotency.Response, ttl time.Duration) error {
+	now := r.store.Now()
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.idempotency[idempotencyID(scope, key)]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		value := *response
+		value.Body = append([]byte(nil), response.Body...)
+		item.Response = &value
+		item.expires = now.Add(ttl)
+		return nil
+	})
+}
+
+func (r *IdempotencyRepo) Remove(ctx context.Context, scope string, key string) error {
+	return r.store.do(ctx, func(d *data) error {
+		id := idempotencyID(scope, key)
+		if _, ok := d.idempotency[id]; !ok {
+			return storage.ErrNotFound
+		}
+		delete(d.idempotency, id)
+		return nil
+	})
+}
+
+func (r *IdempotencyRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	now := r.store.Now()
+	var count int64
+	err := r.store.do(ctx, func(d *data) error {
+		for id, item := range d.idempotency {
+			if !item.expires.After(now) {
+				delete(d.idempotency, id)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func idempotencyID(scope string, key string) string {
+	return scope + "\x00" + key
+}
+
+var _ idempotency.KeyRepo = (*IdempotencyRepo)(nil)
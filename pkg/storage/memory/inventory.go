@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// InventoryRepo хранит журнал движений в Store.
+type InventoryRepo struct {
+	store *Store
+}
+
+// NewInventoryRepo создаёт репозиторий.
+func NewInventoryRepo(store *Store) *InventoryRepo {
+	return &InventoryRepo{store: store}
+}
+
+func (r *InventoryRepo) LockQty(ctx context.Context, productID int64) (int, error) {
+	var qty int
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.products[productID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		qty = item.Qty
+		return nil
+	})
+	return qty, err
+}
+
+func (r *InventoryRepo) SetQty(ctx context.Context, productID int64, qty int) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.products[productID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.Qty = qty
+		return nil
+	})
+}
+
+func (r *InventoryRepo) Create(ctx context.Context, item *inventory.Movement) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		item.Created = r.store.Now()
+		value := *item
+		d.movements[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *InventoryRepo) ByProduct(ctx context.Context, productID int64, before int64, limit int) ([]*inventory.Movement, error) {
+	items := make([]*inventory.Movement, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.movements {
+			if item.ProductID != productID || (before != 0 && item.ID >= before) {
+				continue
+			}
+			value := *item
+			items = append(items, &value)
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID > items[j].ID
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, err
+}
+
+var _ inventory.MovementRepo = (*InventoryRepo)(nil)
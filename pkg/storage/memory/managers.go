@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ManagerRepo хранит менеджеров и роли в Store.
+type ManagerRepo struct {
+	store *Store
+}
+
+// NewManagerRepo создаёт репозиторий.
+func NewManagerRepo(store *Store) *ManagerRepo {
+	return &ManagerRepo{store: store}
+}
+
+// AddManager добавляет активного менеджера с паролем - аналог начальных
+// данных, которые в Postgres загружаются из seed.sql.
+func (s *Store) AddManager(item *managers.Manager, passwordHash string) error {
+	return s.do(context.Background(), func(d *data) error {
+		if d.managerByPhone(item.Phone) != nil {
+			return storage.ErrConflict
+		}
+		d.addManager(item, passwordHash, s.Now())
+		return nil
+	})
+}
+
+func (r *ManagerRepo) Create(ctx context.Context, item *managers.Manager, passwordHash string) error {
+	return r.store.do(ctx, func(d *data) error {
+		if d.managerByPhone(item.Phone) != nil {
+			return storage.ErrConflict
+		}
+		d.addManager(item, passwordHash, r.store.Now())
+		return nil
+	})
+}
+
+func (r *ManagerRepo) ByID(ctx context.Context, id int64) (*managers.Manager, error) {
+	var result *managers.Manager
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		result = item.copy()
+		return nil
+	})
+	return result, err
+}
+
+func (r *ManagerRepo) List(ctx context.Context) ([]*managers.Manager, error) {
+	items := make([]*managers.Manager, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.managers {
+			items = append(items, item.copy())
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items, err
+}
+
+func (r *ManagerRepo) Update(ctx context.Context, item *managers.Manager) error {
+	return r.store.do(ctx, func(d *data) error {
+		existing, ok := d.managers[item.ID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if other := d.managerByPhone(item.Phone); other != nil && other.ID != item.ID {
+			return storage.ErrConflict
+		}
+		existing.Name, existing.Phone = item.Name, item.Phone
+		existing.Roles = append([]string(nil), item.Roles...)
+		existing.Plan, existing.Salary, existing.Department = item.Plan, item.Salary, item.Department
+		existing.BossID = copyID(item.BossID)
+		return nil
+	})
+}
+
+func (r *ManagerRepo) Deactivate(ctx context.Context, id int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.Active = false
+		return nil
+	})
+}
+
+func (r *ManagerRepo) ReassignSubordinates(ctx context.Context, fromID int64, toID *int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		for _, item := range d.managers {
+			if item.BossID != nil && *item.BossID == fromID {
+				item.BossID = copyID(toID)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ManagerRepo) Subordinates(ctx context.Context, id int64) ([]*managers.TeamMember, error) {
+	items := make([]*managers.TeamMember, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		seen := map[int64]bool{id: true}
+		level := []int64{id}
+		for depth := 1; len(level) > 0; depth++ {
+			next := make([]int64, 0)
+			for _, item := range d.managers {
+				if item.BossID == nil || seen[item.ID] || !containsID(level, *item.BossID) {
+					continue
+				}
+				seen[item.ID] = true
+				next = append(next, item.ID)
+				items = append(items, &managers.TeamMember{Manager: *item.copy(), Level: depth})
+			}
+			level = next
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Level != items[j].Level {
+			return items[i].Level < items[j].Level
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, err
+}
+
+func (r *ManagerRepo) SetInvite(ctx context.Context, id int64, hash string, ttl time.Duration) (time.Time, error) {
+	expires := r.store.Now().Add(ttl)
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.inviteHash, item.inviteExpires = hash, expires
+		return nil
+	})
+	return expires, err
+}
+
+func (r *ManagerRepo) ByInvite(ctx context.Context, hash string) (int64, error) {
+	now := r.store.Now()
+	var id int64
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.managers {
+			if item.inviteHash == hash && item.inviteExpires.After(now) && item.Active {
+				id = item.ID
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	return id, err
+}
+
+func (r *ManagerRepo) SetPassword(ctx context.Context, id int64, hash string) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.passwordHash, item.inviteHash, item.inviteExpires = hash, "", time.Time{}
+		return nil
+	})
+}
+
+func (r *ManagerRepo) Permissions(ctx context.Context, id int64) ([]string, error) {
+	permissions := make([]string, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[id]
+		if !ok || !item.Active {
+			return nil
+		}
+		seen := make(map[string]bool)
+		for _, role := range item.Roles {
+			for _, permission := range d.roles[role] {
+				if !seen[permission] {
+					seen[permission] = true
+					permissions = append(permissions, permission)
+				}
+			}
+		}
+		return nil
+	})
+	return permissions, err
+}
+
+func (r *ManagerRepo) RolesExist(ctx context.Context, roles []string) (bool, error) {
+	ok := true
+	err := r.store.do(ctx, func(d *data) error {
+		for _, role := range roles {
+			if _, exists := d.roles[role]; !exists {
+				ok = false
+			}
+		}
+		return nil
+	})
+	return ok, err
+}
+
+func (d *data) managerByPhone(phone string) *manager {
+	for _, item := range d.managers {
+		if item.Phone == phone {
+			return item
+		}
+	}
+	return nil
+}
+
+func (d *data) addManager(item *managers.Manager, passwordHash string, now time.Time) {
+	item.ID = d.nextID()
+	item.Created = now
+	d.managers[item.ID] = &manager{
+		Manager: managers.Manager{
+			ID:         item.ID,
+			Name:       item.Name,
+			Phone:      item.Phone,
+			Roles:      append([]string(nil), item.Roles...),
+			Plan:       item.Plan,
+			Salary:     item.Salary,
+			Department: item.Department,
+			BossID:     copyID(item.BossID),
+			Active:     true,
+			Created:    now,
+		},
+		passwordHash: passwordHash,
+	}
+}
+
+// copy возвращает копию менеджера без пароля.
+func (m *manager) copy() *managers.Manager {
+	value := m.Manager
+	value.Roles = append([]string(nil), m.Roles...)
+	value.BossID = copyID(m.BossID)
+	return &value
+}
+
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	value := *id
+	return &value
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, value := range ids {
+		if value == id {
+			return true
+		}
+	}
+	return false
+}
+
+var _ managers.ManagerRepo = (*ManagerRepo)(nil)
@@ -0,0 +1,167 @@
+// Package memtest собирает сервисы приложения поверх memory.Store для тестов.
+package memtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/config"
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/lifecycle"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/passwords"
+	"github.com/Fanisabonu/http/pkg/phones"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage/memory"
+)
+
+// Notifier запоминает сообщения вместо доставки. Первые Failures
+// сообщений считаются неотправленными.
+type Notifier struct {
+	Failures int
+	Messages []*notifications.Message
+}
+
+// Notify сохраняет сообщение.
+func (n *Notifier) Notify(ctx context.Context, message *notifications.Message) error {
+	n.Messages = append(n.Messages, message)
+	if len(n.Messages) <= n.Failures {
+		return errors.New("gateway is unavailable")
+	}
+	return nil
+}
+
+// Env - сервисы приложения, работающие с одним хранилищем в памяти.
+// Часы хранилища стоят на месте, пока их не сдвинет Advance.
+type Env struct {
+	Store         *memory.Store
+	Config        *config.Config
+	Audit         *audit.Service
+	Auth          *auth.Service
+	Customers     *customers.Service
+	Managers      *managers.Service
+	Ledger        *inventory.Service
+	Products      *products.Service
+	Sales         *sales.Service
+	Purchases     *purchases.Service
+	Carts         *carts.Service
+	Returns       *returns.Service
+	Idempotency   *idempotency.Service
+	Notifications *notifications.Service
+	Notifier      *Notifier
+
+	now time.Time
+}
+
+// New создаёт пустое хранилище и сервисы с настройками по умолчанию,
+// которые можно поменять в configure. Пароли хэшируются с минимальной
+// стоимостью bcrypt, чтобы тесты не тратили на это время.
+func New(configure ...func(cfg *config.Config)) *Env {
+	cfg := config.Default()
+	cfg.BcryptCost = bcrypt.MinCost
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	store := memory.NewStore()
+	env := &Env{Store: store, Config: cfg, Notifier: &Notifier{}, now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Now = env.Now
+
+	lc := lifecycle.New()
+	policy := passwords.NewPolicy(cfg)
+	normalizer := phones.NewNormalizer(cfg)
+	env.Audit = audit.NewService(memory.NewAuditRepo(store))
+	env.Auth = auth.NewService(store, memory.NewTokenRepo(store), memory.NewCredentialRepo(store), memory.NewResetRepo(store), memory.NewAttemptRepo(store),
+		policy, normalizer, env.Notifier, env.Audit, cfg, lc)
+	env.Notifications = notifications.NewService(store, memory.NewOutboxRepo(store), env.Notifier, cfg, lc)
+	env.Customers = customers.NewService(store, memory.NewCustomerRepo(store), env.Auth, env.Notifications, env.Notifier, policy, normalizer, cfg)
+	env.Managers = managers.NewService(store, memory.NewManagerRepo(store), policy, normalizer, cfg)
+	env.Ledger = inventory.NewService(store, memory.NewInventoryRepo(store))
+	env.Products = products.NewService(store, memory.NewProductRepo(store), env.Ledger)
+	env.Sales = sales.NewService(store, memory.NewSaleRepo(store), memory.NewProductRepo(store), env.Ledger, memory.NewReportRepo(store), env.Notifications)
+	env.Purchases = purchases.NewService(store, memory.NewPurchaseRepo(store), memory.NewProductRepo(store), env.Ledger, env.Customers, cfg)
+	env.Carts = carts.NewService(store, memory.NewCartRepo(store), memory.NewProductRepo(store), env.Purchases)
+	env.Returns = returns.NewService(store, memory.NewReturnRepo(store), env.Ledger)
+	env.Idempotency = idempotency.NewService(memory.NewIdempotencyRepo(store), cfg, lc)
+	return env
+}
+
+// Now возвращает текущее время хранилища.
+func (e *Env) Now() time.Time {
+	return e.now
+}
+
+// Advance сдвигает часы хранилища на d.
+func (e *Env) Advance(d time.Duration) {
+	e.now = e.now.Add(d)
+}
+
+// Product добавляет товар с ценой price и остатком qty.
+func (e *Env) Product(t *testing.T, name string, price int, qty int) *products.Product {
+	t.Helper()
+	item, err := e.Products.Create(context.Background(), &products.Product{Name: name, Price: price, Qty: qty})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return item
+}
+
+// Qty возвращает остаток товара.
+func (e *Env) Qty(t *testing.T, id int64) int {
+	t.Helper()
+	item, err := e.Products.ByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("product %d: %v", id, err)
+	}
+	return item.Qty
+}
+
+// Manager добавляет активного менеджера без пароля. Без ролей менеджер
+// получает роль MANAGER.
+func (e *Env) Manager(t *testing.T, item *managers.Manager) *managers.Manager {
+	t.Helper()
+	if len(item.Roles) == 0 {
+		item.Roles = []string{managers.RoleManager}
+	}
+	err := e.Store.AddManager(item, "")
+	if err != nil {
+		t.Fatalf("add manager: %v", err)
+	}
+	return item
+}
+
+// Customer регистрирует покупателя с телефоном phone и паролем password.
+func (e *Env) Customer(t *testing.T, phone string, password string) *customers.Customer {
+	t.Helper()
+	item, err := e.Customers.RegisterCustomer(context.Background(), &customers.Registration{Name: "Ali", Phone: phone, Password: password})
+	if err != nil {
+		t.Fatalf("register customer: %v", err)
+	}
+	return item
+}
+
+// VerifiedCustomer регистрирует покупателя и сразу отмечает его телефон
+// подтверждённым, не отправляя код.
+func (e *Env) VerifiedCustomer(t *testing.T, phone string) *customers.Customer {
+	t.Helper()
+	item := e.Customer(t, phone, "Correct-Horse-42")
+	err := memory.NewCustomerRepo(e.Store).MarkVerified(context.Background(), item.ID)
+	if err != nil {
+		t.Fatalf("verify phone: %v", err)
+	}
+	item.PhoneVerified = true
+	return item
+}
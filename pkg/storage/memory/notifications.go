@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// OutboxRepo хранит уведомления в Store.
+type OutboxRepo struct {
+	store *Store
+}
+
+// NewOutboxRepo создаёт репозиторий.
+func NewOutboxRepo(store *Store) *OutboxRepo {
+	return &OutboxRepo{store: store}
+}
+
+func (r *OutboxRepo) Add(ctx context.Context, item *notifications.Notification) error {
+	now := r.store.Now()
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		item.Status, item.NextAttempt, item.Created = notifications.StatusPending, now, now
+		value := *item
+		d.notifications[item.ID] = &value
+		return nil
+	})
+}
+
+// Due не блокирует уведомления: транзакции Store и так выполняются по одной.
+func (r *OutboxRepo) Due(ctx context.Context, limit int) ([]*notifications.Pending, error) {
+	now := r.store.Now()
+	items := make([]*notifications.Pending, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.notifications {
+			if item.Status != notifications.StatusPending || item.NextAttempt.After(now) {
+				continue
+			}
+			pending := &notifications.Pending{Notification: *item}
+			if item.Recipient == notifications.RecipientManager {
+				if owner, ok := d.managers[item.RecipientID]; ok {
+					pending.Phone = owner.Phone
+				}
+			} else if owner, ok := d.customers[item.RecipientID]; ok {
+				pending.Phone = owner.Phone
+			}
+			items = append(items, pending)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, err
+}
+
+func (r *OutboxRepo) MarkSent(ctx context.Context, id int64) error {
+	now := r.store.Now()
+	return r.update(ctx, id, func(item *notifications.Notification) {
+		item.Status, item.Sent = notifications.StatusSent, &now
+		item.Attempts++
+	})
+}
+
+func (r *OutboxRepo) Retry(ctx context.Context, id int64, lastError string, delay time.Duration) error {
+	next := r.store.Now().Add(delay)
+	return r.update(ctx, id, func(item *notifications.Notification) {
+		item.Attempts++
+		item.LastError, item.NextAttempt = lastError, next
+	})
+}
+
+func (r *OutboxRepo) Fail(ctx context.Context, id int64, lastError string) error {
+	return r.update(ctx, id, func(item *notifications.Notification) {
+		item.Status, item.LastError = notifications.StatusFailed, lastError
+		item.Attempts++
+	})
+}
+
+func (r *OutboxRepo) update(ctx context.Context, id int64, change func(item *notifications.Notification)) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.notifications[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		change(item)
+		return nil
+	})
+}
+
+var _ notifications.OutboxRepo = (*OutboxRepo)(nil)
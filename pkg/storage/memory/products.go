@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ProductRepo хранит товары в Store.
+type ProductRepo struct {
+	store *Store
+}
+
+// NewProductRepo создаёт репозиторий.
+func NewProductRepo(store *Store) *ProductRepo {
+	return &ProductRepo{store: store}
+}
+
+func (r *ProductRepo) List(ctx context.Context, filter *products.Filter) ([]*products.Product, error) {
+	query := strings.ToLower(filter.Query)
+	items := make([]*products.Product, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.products {
+			if !filter.IncludeInactive && !item.Active {
+				continue
+			}
+			if query != "" && !strings.Contains(strings.ToLower(item.Name), query) {
+				continue
+			}
+			if filter.MinPrice != nil && item.Price < *filter.MinPrice {
+				continue
+			}
+			if filter.MaxPrice != nil && item.Price > *filter.MaxPrice {
+				continue
+			}
+			if filter.After != nil && !productAfter(filter.Sort, item, filter.After) {
+				continue
+			}
+			value := *item
+			items = append(items, &value)
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return productLess(filter.Sort, items[i], items[j])
+	})
+	if len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, err
+}
+
+// productLess сравнивает товары по полю сортировки, при равенстве - по id.
+func productLess(order products.Sort, a *products.Product, b *products.Product) bool {
+	less, equal := a.ID < b.ID, true
+	switch order.Field() {
+	case "name":
+		less, equal = a.Name < b.Name, a.Name == b.Name
+	case "price":
+		less, equal = a.Price < b.Price, a.Price == b.Price
+	}
+	if equal {
+		less = a.ID < b.ID
+	}
+	if order.Desc() {
+		return !less && a.ID != b.ID
+	}
+	return less
+}
+
+// productAfter сообщает, идёт ли товар после курсора.
+func productAfter(order products.Sort, item *products.Product, cursor *products.Cursor) bool {
+	last := &products.Product{ID: cursor.ID, Name: cursor.Name, Price: cursor.Price}
+	return productLess(order, last, item)
+}
+
+func (r *ProductRepo) ByID(ctx context.Context, id int64) (*products.Product, error) {
+	var result *products.Product
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.products[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		value := *item
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *ProductRepo) Create(ctx context.Context, item *products.Product) (*products.Product, error) {
+	var result *products.Product
+	err := r.store.do(ctx, func(d *data) error {
+		created := *item
+		created.ID = d.nextID()
+		d.products[created.ID] = &created
+		value := created
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *ProductRepo) Update(ctx context.Context, item *products.Product) (*products.Product, error) {
+	var result *products.Product
+	err := r.store.do(ctx, func(d *data) error {
+		stored, ok := d.products[item.ID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		stored.Name, stored.Price, stored.Active = item.Name, item.Price, item.Active
+		value := *stored
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *ProductRepo) LockStock(ctx context.Context, ids []int64) ([]*sales.Stock, error) {
+	items := make([]*sales.Stock, 0, len(ids))
+	err := r.store.do(ctx, func(d *data) error {
+		for _, id := range ids {
+			if item, ok := d.products[id]; ok {
+				items = append(items, &sales.Stock{ProductID: id, Qty: int64(item.Qty), Active: item.Active})
+			}
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ProductID < items[j].ProductID
+	})
+	return items, err
+}
+
+var (
+	_ products.ProductRepo = (*ProductRepo)(nil)
+	_ sales.StockRepo      = (*ProductRepo)(nil)
+)
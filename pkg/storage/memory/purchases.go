@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/purchases"
+)
+
+// PurchaseRepo хранит покупки в Store.
+type PurchaseRepo struct {
+	store *Store
+}
+
+// NewPurchaseRepo создаёт репозиторий.
+func NewPurchaseRepo(store *Store) *PurchaseRepo {
+	return &PurchaseRepo{store: store}
+}
+
+func (r *PurchaseRepo) Create(ctx context.Context, item *purchases.Purchase) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		item.Created = r.store.Now()
+		value := *item
+		value.Items = nil
+		d.purchases[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *PurchaseRepo) AddItem(ctx context.Context, item *purchases.Item) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		value := *item
+		d.items[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *PurchaseRepo) ByCustomer(ctx context.Context, customerID int64) ([]*purchases.Purchase, error) {
+	items := make([]*purchases.Purchase, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, purchase := range d.purchases {
+			if purchase.CustomerID != customerID {
+				continue
+			}
+			value := *purchase
+			for _, item := range d.items {
+				if item.PurchaseID == purchase.ID {
+					position := *item
+					value.Items = append(value.Items, &position)
+				}
+			}
+			sort.Slice(value.Items, func(i, j int) bool {
+				return value.Items[i].ID < value.Items[j].ID
+			})
+			items = append(items, &value)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items, err
+}
+
+var _ purchases.PurchaseRepo = (*PurchaseRepo)(nil)
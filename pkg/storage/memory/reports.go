@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ReportRepo считает отчёты по продажам из Store за вычетом возвратов.
+// Отменённые продажи не учитываются.
+type ReportRepo struct {
+	store *Store
+}
+
+// NewReportRepo создаёт репозиторий.
+func NewReportRepo(store *Store) *ReportRepo {
+	return &ReportRepo{store: store}
+}
+
+// bucketSales - строка отчёта вместе с продажами, уже учтёнными в ней.
+type bucketSales struct {
+	sales.Bucket
+	seen map[int64]bool
+}
+
+func (b *bucketSales) add(saleID int64, qty int64, amount int64) {
+	if !b.seen[saleID] {
+		b.seen[saleID] = true
+		b.Sales++
+	}
+	b.Qty += qty
+	b.Total += amount
+}
+
+// saleLine - позиция продажи или возврат по ней (с отрицательными qty и amount).
+type saleLine struct {
+	sale     *sales.MakeSale
+	position *sales.SalePosition
+	qty      int64
+	amount   int64
+}
+
+// saleLines возвращает позиции неотменённых продаж и возвраты по ним.
+func (d *data) saleLines() []*saleLine {
+	lines := make([]*saleLine, 0, len(d.positions)+len(d.returned))
+	for _, position := range d.positions {
+		sale, ok := d.sales[position.SaleID]
+		if ok && sale.Cancelled == nil {
+			lines = append(lines, &saleLine{sale: sale, position: position, qty: position.Qty, amount: position.Qty * position.Price})
+		}
+	}
+	for _, item := range d.returned {
+		if item.SalePositionID == nil {
+			continue
+		}
+		position, ok := d.positions[*item.SalePositionID]
+		if !ok {
+			continue
+		}
+		sale, ok := d.sales[position.SaleID]
+		if ok && sale.Cancelled == nil {
+			lines = append(lines, &saleLine{sale: sale, position: position, qty: -item.Qty, amount: -item.Refund})
+		}
+	}
+	return lines
+}
+
+// group собирает позиции продаж менеджера за период в строки по ключу key.
+func (r *ReportRepo) group(ctx context.Context, filter *sales.ReportFilter, key func(d *data, sale *sales.MakeSale, position *sales.SalePosition) (string, string)) ([]*sales.Bucket, error) {
+	buckets := make(map[string]*bucketSales)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, line := range d.saleLines() {
+			sale := line.sale
+			if sale.ManagerID != filter.ManagerID || sale.Created.Before(filter.From) || !sale.Created.Before(filter.To) {
+				continue
+			}
+			id, name := key(d, sale, line.position)
+			bucket, ok := buckets[id]
+			if !ok {
+				bucket = &bucketSales{Bucket: sales.Bucket{Key: id, Name: name}, seen: make(map[int64]bool)}
+				buckets[id] = bucket
+			}
+			bucket.add(sale.ID, line.qty, line.amount)
+		}
+		return nil
+	})
+
+	items := make([]*sales.Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		value := bucket.Bucket
+		items = append(items, &value)
+	}
+	return items, err
+}
+
+func (r *ReportRepo) Summary(ctx context.Context, filter *sales.ReportFilter) (*sales.Bucket, error) {
+	items, err := r.group(ctx, filter, func(*data, *sales.MakeSale, *sales.SalePosition) (string, string) {
+		return "", ""
+	})
+	if err != nil || len(items) == 0 {
+		return &sales.Bucket{}, err
+	}
+	return items[0], nil
+}
+
+func (r *ReportRepo) Breakdown(ctx context.Context, filter *sales.ReportFilter) ([]*sales.Bucket, error) {
+	var key func(d *data, sale *sales.MakeSale, position *sales.SalePosition) (string, string)
+	switch filter.GroupBy {
+	case sales.GroupDay, sales.GroupWeek, sales.GroupMonth:
+		key = func(d *data, sale *sales.MakeSale, position *sales.SalePosition) (string, string) {
+			return truncate(sale.Created, filter.GroupBy).Format("2006-01-02"), ""
+		}
+	case sales.GroupProduct:
+		key = func(d *data, sale *sales.MakeSale, position *sales.SalePosition) (string, string) {
+			name := ""
+			if product, ok := d.products[position.ProductID]; ok {
+				name = product.Name
+			}
+			return strconv.FormatInt(position.ProductID, 10), name
+		}
+	case sales.GroupCustomer:
+		key = func(d *data, sale *sales.MakeSale, position *sales.SalePosition) (string, string) {
+			name := ""
+			if customer, ok := d.customers[sale.CustomerID]; ok {
+				name = customer.Name
+			}
+			return strconv.FormatInt(sale.CustomerID, 10), name
+		}
+	default:
+		return nil, fmt.Errorf("unsupported grouping %q", filter.GroupBy)
+	}
+
+	items, err := r.group(ctx, filter, key)
+	sort.Slice(items, func(i, j int) bool {
+		if filter.GroupBy.Period() == "" && items[i].Total != items[j].Total {
+			return items[i].Total > items[j].Total
+		}
+		return items[i].Key < items[j].Key
+	})
+	return items, err
+}
+
+// truncate повторяет date_trunc: неделя начинается с понедельника.
+func truncate(value time.Time, grouping sales.Grouping) time.Time {
+	day := time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
+	switch grouping {
+	case sales.GroupWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case sales.GroupMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+func (r *ReportRepo) Leaderboard(ctx context.Context, from time.Time, to time.Time) ([]*sales.Standing, error) {
+	items := make([]*sales.Standing, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.managers {
+			if item.Active && containsRole(item.Roles, managers.RoleManager) {
+				items = append(items, &sales.Standing{ManagerID: item.ID, Name: item.Name, MonthlyPlan: item.Plan})
+			}
+		}
+		lines := d.saleLines()
+		for _, standing := range items {
+			seen := make(map[int64]bool)
+			for _, line := range lines {
+				sale := line.sale
+				if sale.ManagerID != standing.ManagerID || sale.Created.Before(from) || !sale.Created.Before(to) {
+					continue
+				}
+				if !seen[sale.ID] {
+					seen[sale.ID] = true
+					standing.Sales++
+				}
+				standing.Total += line.amount
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Total != items[j].Total {
+			return items[i].Total > items[j].Total
+		}
+		return items[i].ManagerID < items[j].ManagerID
+	})
+	return items, err
+}
+
+func (r *ReportRepo) Plan(ctx context.Context, managerID int64) (int64, error) {
+	var plan int64
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.managers[managerID]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		plan = item.Plan
+		return nil
+	})
+	return plan, err
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, value := range roles {
+		if value == role {
+			return true
+		}
+	}
+	return false
+}
+
+var _ sales.ReportRepo = (*ReportRepo)(nil)
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ResetRepo хранит коды сброса пароля в Store.
+type ResetRepo struct {
+	store *Store
+}
+
+// NewResetRepo создаёт репозиторий.
+func NewResetRepo(store *Store) *ResetRepo {
+	return &ResetRepo{store: store}
+}
+
+func (r *ResetRepo) Save(ctx context.Context, kind auth.Kind, ownerID int64, hash string, ttl time.Duration) error {
+	expires := r.store.Now().Add(ttl)
+	return r.store.do(ctx, func(d *data) error {
+		d.resets[resetID(kind, ownerID)] = &resetCode{
+			ResetCode: auth.ResetCode{Kind: kind, OwnerID: ownerID, Hash: hash},
+			expires:   expires,
+		}
+		return nil
+	})
+}
+
+func (r *ResetRepo) ByOwner(ctx context.Context, kind auth.Kind, ownerID int64) (*auth.ResetCode, error) {
+	now := r.store.Now()
+	var result *auth.ResetCode
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.resets[resetID(kind, ownerID)]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		value := item.ResetCode
+		value.Expired = !item.expires.After(now)
+		result = &value
+		return nil
+	})
+	return result, err
+}
+
+func (r *ResetRepo) Fail(ctx context.Context, kind auth.Kind, ownerID int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.resets[resetID(kind, ownerID)]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		item.Attempts++
+		return nil
+	})
+}
+
+func (r *ResetRepo) Remove(ctx context.Context, kind auth.Kind, ownerID int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		id := resetID(kind, ownerID)
+		if _, ok := d.resets[id]; !ok {
+			return storage.ErrNotFound
+		}
+		delete(d.resets, id)
+		return nil
+	})
+}
+
+func (r *ResetRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	now := r.store.Now()
+	var count int64
+	err := r.store.do(ctx, func(d *data) error {
+		for id, item := range d.resets {
+			if !item.expires.After(now) {
+				delete(d.resets, id)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func resetID(kind auth.Kind, ownerID int64) string {
+	return string(kind) + ":" + strconv.FormatInt(ownerID, 10)
+}
+
+var _ auth.ResetRepo = (*ResetRepo)(nil)
@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ReturnRepo хранит возвраты в Store.
+type ReturnRepo struct {
+	store *Store
+}
+
+// NewReturnRepo создаёт репозиторий.
+func NewReturnRepo(store *Store) *ReturnRepo {
+	return &ReturnRepo{store: store}
+}
+
+func (r *ReturnRepo) Sale(ctx context.Context, id int64) (*returns.Origin, error) {
+	var result *returns.Origin
+	err := r.store.do(ctx, func(d *data) error {
+		sale, ok := d.sales[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		result = &returns.Origin{Cancelled: sale.Cancelled != nil, Lines: make([]*returns.OriginLine, 0)}
+		for _, position := range d.positions {
+			if position.SaleID != id {
+				continue
+			}
+			line := &returns.OriginLine{ID: position.ID, ProductID: position.ProductID, Qty: position.Qty, Price: position.Price}
+			for _, item := range d.returned {
+				if item.SalePositionID != nil && *item.SalePositionID == position.ID {
+					line.Returned += item.Qty
+				}
+			}
+			result.Lines = append(result.Lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortLines(result.Lines)
+	return result, nil
+}
+
+func (r *ReturnRepo) Purchase(ctx context.Context, id int64) (*returns.Origin, error) {
+	var result *returns.Origin
+	err := r.store.do(ctx, func(d *data) error {
+		if _, ok := d.purchases[id]; !ok {
+			return storage.ErrNotFound
+		}
+		result = &returns.Origin{Lines: make([]*returns.OriginLine, 0)}
+		for _, position := range d.items {
+			if position.PurchaseID != id {
+				continue
+			}
+			line := &returns.OriginLine{ID: position.ID, ProductID: position.ProductID, Qty: int64(position.Qty), Price: int64(position.Price)}
+			for _, item := range d.returned {
+				if item.PurchaseItemID != nil && *item.PurchaseItemID == position.ID {
+					line.Returned += item.Qty
+				}
+			}
+			result.Lines = append(result.Lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortLines(result.Lines)
+	return result, nil
+}
+
+func (r *ReturnRepo) Create(ctx context.Context, item *returns.Return) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		item.Created = r.store.Now()
+		value := *item
+		value.Items = nil
+		d.returns[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *ReturnRepo) AddItem(ctx context.Context, item *returns.Item) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		value := *item
+		d.returned[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *ReturnRepo) ByID(ctx context.Context, id int64) (*returns.Return, error) {
+	var result *returns.Return
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.returns[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		result = d.returnWithItems(item)
+		return nil
+	})
+	return result, err
+}
+
+func (r *ReturnRepo) List(ctx context.Context, filter *returns.Filter) ([]*returns.Return, error) {
+	items := make([]*returns.Return, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.returns {
+			if filter.SaleID != nil && (item.SaleID == nil || *item.SaleID != *filter.SaleID) ||
+				filter.PurchaseID != nil && (item.PurchaseID == nil || *item.PurchaseID != *filter.PurchaseID) ||
+				filter.Before != 0 && item.ID >= filter.Before {
+				continue
+			}
+			items = append(items, d.returnWithItems(item))
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID > items[j].ID
+	})
+	if len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, err
+}
+
+// returnWithItems возвращает копию возврата вместе с позициями.
+func (d *data) returnWithItems(item *returns.Return) *returns.Return {
+	value := *item
+	value.Items = make([]*returns.Item, 0)
+	for _, returned := range d.returned {
+		if returned.ReturnID == item.ID {
+			copied := *returned
+			value.Items = append(value.Items, &copied)
+		}
+	}
+	sort.Slice(value.Items, func(i, j int) bool {
+		return value.Items[i].ID < value.Items[j].ID
+	})
+	return &value
+}
+
+func sortLines(lines []*returns.OriginLine) {
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].ID < lines[j].ID
+	})
+}
+
+var _ returns.ReturnRepo = (*ReturnRepo)(nil)
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// SaleRepo хранит продажи в Store.
+type SaleRepo struct {
+	store *Store
+}
+
+// NewSaleRepo создаёт репозиторий.
+func NewSaleRepo(store *Store) *SaleRepo {
+	return &SaleRepo{store: store}
+}
+
+func (r *SaleRepo) Create(ctx context.Context, item *sales.MakeSale) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		item.Created = r.store.Now()
+		value := *item
+		value.Positions = nil
+		d.sales[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *SaleRepo) AddPosition(ctx context.Context, item *sales.SalePosition) error {
+	return r.store.do(ctx, func(d *data) error {
+		item.ID = d.nextID()
+		value := *item
+		d.positions[item.ID] = &value
+		return nil
+	})
+}
+
+func (r *SaleRepo) ByID(ctx context.Context, id int64) (*sales.MakeSale, error) {
+	var result *sales.MakeSale
+	err := r.store.do(ctx, func(d *data) error {
+		item, ok := d.sales[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		result = d.saleWithPositions(item)
+		return nil
+	})
+	return result, err
+}
+
+func (r *SaleRepo) List(ctx context.Context, filter *sales.SaleFilter) ([]*sales.MakeSale, error) {
+	items := make([]*sales.MakeSale, 0)
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.sales {
+			if filter.ManagerID != 0 && item.ManagerID != filter.ManagerID ||
+				filter.CustomerID != nil && item.CustomerID != *filter.CustomerID ||
+				filter.From != nil && item.Created.Before(*filter.From) ||
+				filter.To != nil && !item.Created.Before(*filter.To) ||
+				filter.Before != 0 && item.ID >= filter.Before {
+				continue
+			}
+			value := d.saleWithPositions(item)
+			if filter.ProductID != nil && !hasProduct(value, *filter.ProductID) {
+				continue
+			}
+			items = append(items, value)
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID > items[j].ID
+	})
+	if len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, err
+}
+
+func (r *SaleRepo) Cancel(ctx context.Context, id int64, managerID int64, reason string) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.sales[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		now := r.store.Now()
+		item.Cancelled, item.CancelledBy, item.CancelReason = &now, &managerID, reason
+		return nil
+	})
+}
+
+// saleWithPositions возвращает копию продажи вместе с позициями.
+func (d *data) saleWithPositions(item *sales.MakeSale) *sales.MakeSale {
+	value := *item
+	value.Positions = make([]*sales.SalePosition, 0)
+	for _, position := range d.positions {
+		if position.SaleID == item.ID {
+			copied := *position
+			for _, returned := range d.returned {
+				if returned.SalePositionID != nil && *returned.SalePositionID == position.ID {
+					copied.Returned += returned.Qty
+				}
+			}
+			value.Positions = append(value.Positions, &copied)
+		}
+	}
+	sort.Slice(value.Positions, func(i, j int) bool {
+		return value.Positions[i].ID < value.Positions[j].ID
+	})
+	return &value
+}
+
+func hasProduct(item *sales.MakeSale, productID int64) bool {
+	for _, position := range item.Positions {
+		if position.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+var _ sales.SaleRepo = (*SaleRepo)(nil)
@@ -0,0 +1,270 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/purchases"
+	"github.com/Fanisabonu/http/pkg/returns"
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// customer - покупатель вместе с хэшем пароля и кодом подтверждения телефона.
+// verificationSent - когда отправлен последний код, он не сбрасывается вместе с кодом.
+type customer struct {
+	customers.Customer
+	passwordHash     string
+	verification     *verification
+	verificationSent time.Time
+}
+
+// verification - код подтверждения телефона вместе со сроком действия.
+type verification struct {
+	customers.Verification
+	expires time.Time
+}
+
+// manager - менеджер вместе с хэшем пароля и приглашением.
+type manager struct {
+	managers.Manager
+	passwordHash  string
+	inviteHash    string
+	inviteExpires time.Time
+}
+
+// token - сохранённый токен.
+type token struct {
+	id            int64
+	kind          auth.Kind
+	ownerID       int64
+	hash          string
+	refreshHash   string
+	expire        time.Time
+	refreshExpire time.Time
+}
+
+// idempotencyKey - ключ идемпотентности вместе со сроком хранения.
+type idempotencyKey struct {
+	idempotency.Record
+	expires time.Time
+}
+
+// resetCode - код сброса пароля вместе со сроком действия.
+type resetCode struct {
+	auth.ResetCode
+	expires time.Time
+}
+
+// data - всё содержимое хранилища. Копируется целиком при начале транзакции.
+type data struct {
+	lastID    int64
+	customers map[int64]*customer
+	managers  map[int64]*manager
+	roles     map[string][]string
+	tokens    map[int64]*token
+	products  map[int64]*products.Product
+	sales     map[int64]*sales.MakeSale
+	positions map[int64]*sales.SalePosition
+	purchases map[int64]*purchases.Purchase
+	items     map[int64]*purchases.Item
+	movements map[int64]*inventory.Movement
+	carts     map[int64]map[int64]*carts.Line
+	returns   map[int64]*returns.Return
+	returned  map[int64]*returns.Item
+
+	idempotency   map[string]*idempotencyKey
+	resets        map[string]*resetCode
+	notifications map[int64]*notifications.Notification
+	attempts      map[string]*auth.Lockout
+	audit         map[int64]*audit.Event
+}
+
+func (d *data) nextID() int64 {
+	d.lastID++
+	return d.lastID
+}
+
+func (d *data) clone() *data {
+	result := &data{
+		lastID:    d.lastID,
+		customers: make(map[int64]*customer, len(d.customers)),
+		managers:  make(map[int64]*manager, len(d.managers)),
+		roles:     make(map[string][]string, len(d.roles)),
+		tokens:    make(map[int64]*token, len(d.tokens)),
+		products:  make(map[int64]*products.Product, len(d.products)),
+		sales:     make(map[int64]*sales.MakeSale, len(d.sales)),
+		positions: make(map[int64]*sales.SalePosition, len(d.positions)),
+		purchases: make(map[int64]*purchases.Purchase, len(d.purchases)),
+		items:     make(map[int64]*purchases.Item, len(d.items)),
+		movements: make(map[int64]*inventory.Movement, len(d.movements)),
+		carts:     make(map[int64]map[int64]*carts.Line, len(d.carts)),
+		returns:   make(map[int64]*returns.Return, len(d.returns)),
+		returned:  make(map[int64]*returns.Item, len(d.returned)),
+
+		idempotency:   make(map[string]*idempotencyKey, len(d.idempotency)),
+		resets:        make(map[string]*resetCode, len(d.resets)),
+		notifications: make(map[int64]*notifications.Notification, len(d.notifications)),
+		attempts:      make(map[string]*auth.Lockout, len(d.attempts)),
+		audit:         make(map[int64]*audit.Event, len(d.audit)),
+	}
+	for id, item := range d.customers {
+		value := *item
+		if item.verification != nil {
+			code := *item.verification
+			value.verification = &code
+		}
+		result.customers[id] = &value
+	}
+	for id, item := range d.managers {
+		value := *item
+		value.Roles = append([]string(nil), item.Roles...)
+		result.managers[id] = &value
+	}
+	for name, permissions := range d.roles {
+		result.roles[name] = append([]string(nil), permissions...)
+	}
+	for id, item := range d.tokens {
+		value := *item
+		result.tokens[id] = &value
+	}
+	for id, item := range d.products {
+		value := *item
+		result.products[id] = &value
+	}
+	for id, item := range d.sales {
+		value := *item
+		result.sales[id] = &value
+	}
+	for id, item := range d.positions {
+		value := *item
+		result.positions[id] = &value
+	}
+	for id, item := range d.purchases {
+		value := *item
+		result.purchases[id] = &value
+	}
+	for id, item := range d.items {
+		value := *item
+		result.items[id] = &value
+	}
+	for id, item := range d.movements {
+		value := *item
+		result.movements[id] = &value
+	}
+	for customerID, lines := range d.carts {
+		result.carts[customerID] = make(map[int64]*carts.Line, len(lines))
+		for productID, item := range lines {
+			value := *item
+			result.carts[customerID][productID] = &value
+		}
+	}
+	for id, item := range d.returns {
+		value := *item
+		result.returns[id] = &value
+	}
+	for id, item := range d.returned {
+		value := *item
+		result.returned[id] = &value
+	}
+	for id, item := range d.idempotency {
+		value := *item
+		result.idempotency[id] = &value
+	}
+	for id, item := range d.resets {
+		value := *item
+		result.resets[id] = &value
+	}
+	for id, item := range d.notifications {
+		value := *item
+		result.notifications[id] = &value
+	}
+	for key, item := range d.attempts {
+		value := *item
+		result.attempts[key] = &value
+	}
+	for id, item := range d.audit {
+		value := *item
+		result.audit[id] = &value
+	}
+	return result
+}
+
+type txKey struct{}
+
+// Store - хранилище в памяти для тестов и локальной разработки.
+// Реализует storage.Transactor: транзакции выполняются по одной, при ошибке или панике
+// состояние восстанавливается из снимка, сделанного в начале транзакции.
+// Запросы вне транзакции ждут её окончания, поэтому хранилище можно
+// использовать из нескольких горутин.
+type Store struct {
+	// Now - часы хранилища, по ним считается истечение токенов.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	txMu sync.Mutex
+	data *data
+}
+
+// NewStore создаёт пустое хранилище с ролями MANAGER и ADMIN.
+func NewStore() *Store {
+	empty := &data{}
+	result := &Store{Now: time.Now, data: empty.clone()}
+	result.data.roles[managers.RoleManager] = []string{"sales.read", "sales.write", "products.read"}
+	result.data.roles[managers.RoleAdmin] = []string{"products.read", "products.write", "customers.read", "customers.write", "managers.write"}
+	return result
+}
+
+// InTx выполняет fn в транзакции. Если fn вернула ошибку или паникует,
+// хранилище возвращается к снимку, а паника продолжается.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.data.clone()
+	s.mu.Unlock()
+
+	committed := false
+	defer func() {
+		if !committed {
+			s.mu.Lock()
+			s.data = snapshot
+			s.mu.Unlock()
+		}
+	}()
+
+	err := fn(context.WithValue(ctx, txKey{}, true))
+	committed = err == nil
+	return err
+}
+
+// do выполняет fn под блокировкой хранилища. Вне транзакции do ещё и ждёт
+// её окончания, иначе изменение, сделанное во время чужой транзакции,
+// пропало бы при её откате к снимку.
+func (s *Store) do(ctx context.Context, fn func(d *data) error) error {
+	if ctx.Value(txKey{}) == nil {
+		s.txMu.Lock()
+		defer s.txMu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+var _ storage.Transactor = (*Store)(nil)
@@ -0,0 +1,41 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/storage/memory/memtest"
+)
+
+func TestInTxPanic(t *testing.T) {
+	env := memtest.New()
+	ctx := context.Background()
+	var created *products.Product
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was not propagated")
+			}
+		}()
+		_ = env.Store.InTx(ctx, func(ctx context.Context) error {
+			item, err := env.Products.Create(ctx, &products.Product{Name: "tea", Price: 100, Qty: 5})
+			if err != nil {
+				return err
+			}
+			created = item
+			panic("handler failed")
+		})
+	}()
+
+	// изменения транзакции, прерванной паникой, не сохраняются
+	if created == nil {
+		t.Fatal("product was not created inside the transaction")
+	}
+	_, err := env.Products.ByID(ctx, created.ID)
+	if !errors.Is(err, products.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
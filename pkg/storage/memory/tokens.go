@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// TokenRepo хранит токены в Store.
+type TokenRepo struct {
+	store *Store
+}
+
+// NewTokenRepo создаёт репозиторий.
+func NewTokenRepo(store *Store) *TokenRepo {
+	return &TokenRepo{store: store}
+}
+
+func (r *TokenRepo) Create(ctx context.Context, item *auth.NewTokenRecord) (time.Time, error) {
+	now := r.store.Now()
+	created := &token{
+		kind:          item.Kind,
+		ownerID:       item.OwnerID,
+		hash:          item.Hash,
+		refreshHash:   item.RefreshHash,
+		expire:        now.Add(item.TTL),
+		refreshExpire: now.Add(item.RefreshTTL),
+	}
+	err := r.store.do(ctx, func(d *data) error {
+		created.id = d.nextID()
+		d.tokens[created.id] = created
+		return nil
+	})
+	return created.expire, err
+}
+
+func (r *TokenRepo) ByToken(ctx context.Context, kind auth.Kind, hash string) (*auth.TokenRecord, error) {
+	return r.find(ctx, kind, func(item *token) bool { return item.hash == hash })
+}
+
+func (r *TokenRepo) ByRefreshToken(ctx context.Context, kind auth.Kind, hash string) (*auth.TokenRecord, error) {
+	return r.find(ctx, kind, func(item *token) bool { return item.refreshHash == hash })
+}
+
+func (r *TokenRepo) find(ctx context.Context, kind auth.Kind, match func(item *token) bool) (*auth.TokenRecord, error) {
+	now := r.store.Now()
+	var result *auth.TokenRecord
+	err := r.store.do(ctx, func(d *data) error {
+		for _, item := range d.tokens {
+			if item.kind != kind || !match(item) {
+				continue
+			}
+			roles, active := d.owner(kind, item.ownerID)
+			if !active {
+				return storage.ErrNotFound
+			}
+			result = &auth.TokenRecord{
+				ID:             item.id,
+				Kind:           kind,
+				OwnerID:        item.ownerID,
+				Roles:          roles,
+				Expire:         item.expire,
+				Expired:        !item.expire.After(now),
+				RefreshExpired: !item.refreshExpire.After(now),
+			}
+			return nil
+		}
+		return storage.ErrNotFound
+	})
+	return result, err
+}
+
+func (r *TokenRepo) Remove(ctx context.Context, kind auth.Kind, id int64, ownerID int64) error {
+	return r.store.do(ctx, func(d *data) error {
+		item, ok := d.tokens[id]
+		if ok && item.kind == kind && item.ownerID == ownerID {
+			delete(d.tokens, id)
+		}
+		return nil
+	})
+}
+
+func (r *TokenRepo) RemoveByOwner(ctx context.Context, kind auth.Kind, ownerID int64) (int64, error) {
+	var count int64
+	err := r.store.do(ctx, func(d *data) error {
+		for id, item := range d.tokens {
+			if item.kind == kind && item.ownerID == ownerID {
+				delete(d.tokens, id)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *TokenRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	now := r.store.Now()
+	var count int64
+	err := r.store.do(ctx, func(d *data) error {
+		for id, item := range d.tokens {
+			if !item.refreshExpire.After(now) {
+				delete(d.tokens, id)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// owner возвращает роли владельца токена и то, активен ли он.
+func (d *data) owner(kind auth.Kind, id int64) ([]string, bool) {
+	if kind == auth.KindManager {
+		item, ok := d.managers[id]
+		if !ok {
+			return nil, false
+		}
+		return append([]string(nil), item.Roles...), item.Active
+	}
+	item, ok := d.customers[id]
+	if !ok {
+		return nil, false
+	}
+	return []string{}, item.Active
+}
+
+var _ auth.TokenRepo = (*TokenRepo)(nil)
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// AttemptRepo хранит неудачные попытки входа в таблице login_attempts.
+type AttemptRepo struct {
+	db *DB
+}
+
+// NewAttemptRepo создаёт репозиторий.
+func NewAttemptRepo(db *DB) *AttemptRepo {
+	return &AttemptRepo{db: db}
+}
+
+func (r *AttemptRepo) Blocked(ctx context.Context, keys []string) (time.Duration, error) {
+	var seconds float64
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT coalesce(max(extract(EPOCH FROM locked_until - CURRENT_TIMESTAMP)), 0)::FLOAT8
+		FROM login_attempts WHERE key = ANY($1) AND locked_until > CURRENT_TIMESTAMP
+	`, keys).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (r *AttemptRepo) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO login_attempts AS a (key, failures) VALUES ($1, 1)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN a.last_failure > CURRENT_TIMESTAMP - $2::INTERVAL THEN a.failures + 1 ELSE 1 END,
+			last_failure = CURRENT_TIMESTAMP
+		RETURNING failures
+	`, key, window).Scan(&failures)
+	return failures, err
+}
+
+func (r *AttemptRepo) Attempt(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	var failures int
+	var seconds float64
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO login_attempts AS a (key, failures) VALUES ($1, 1)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN a.locked_until > CURRENT_TIMESTAMP THEN a.failures
+				WHEN a.last_failure > CURRENT_TIMESTAMP - $2::INTERVAL THEN a.failures + 1
+				ELSE 1 END,
+			last_failure = CASE WHEN a.locked_until > CURRENT_TIMESTAMP THEN a.last_failure ELSE CURRENT_TIMESTAMP END
+		RETURNING failures, greatest(coalesce(extract(EPOCH FROM locked_until - CURRENT_TIMESTAMP), 0), 0)::FLOAT8
+	`, key, window).Scan(&failures, &seconds)
+	return failures, time.Duration(seconds * float64(time.Second)), err
+}
+
+func (r *AttemptRepo) Forgive(ctx context.Context, key string) error {
+	return r.exec(ctx, `
+		UPDATE login_attempts SET failures = greatest(failures - 1, 0), locked_until = NULL WHERE key = $1
+	`, key)
+}
+
+func (r *AttemptRepo) Lock(ctx context.Context, key string, duration time.Duration) error {
+	return r.exec(ctx, `
+		UPDATE login_attempts SET locked_until = CURRENT_TIMESTAMP + $2::INTERVAL WHERE key = $1
+	`, key, duration)
+}
+
+func (r *AttemptRepo) Reset(ctx context.Context, key string) error {
+	return r.exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+}
+
+func (r *AttemptRepo) exec(ctx context.Context, sql string, args ...interface{}) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *AttemptRepo) Locked(ctx context.Context) ([]*auth.Lockout, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT key, failures, last_failure, locked_until FROM login_attempts
+		WHERE locked_until > CURRENT_TIMESTAMP ORDER BY locked_until DESC, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*auth.Lockout, 0)
+	for rows.Next() {
+		item := &auth.Lockout{}
+		err = rows.Scan(&item.Key, &item.Failures, &item.LastFailure, &item.LockedUntil)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *AttemptRepo) RemoveStale(ctx context.Context, window time.Duration) (int64, error) {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		DELETE FROM login_attempts
+		WHERE last_failure <= CURRENT_TIMESTAMP - $1::INTERVAL
+		  AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)
+	`, window)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+var _ auth.AttemptRepo = (*AttemptRepo)(nil)
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/audit"
+)
+
+// AuditRepo хранит журнал аудита в таблице audit_events.
+type AuditRepo struct {
+	db *DB
+}
+
+// NewAuditRepo создаёт репозиторий.
+func NewAuditRepo(db *DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Add(ctx context.Context, item *audit.Event) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO audit_events (kind, subject, actor_id, details) VALUES ($1, $2, $3, $4)
+		RETURNING id, created
+	`, string(item.Kind), item.Subject, item.ActorID, item.Details).Scan(&item.ID, &item.Created)
+}
+
+func (r *AuditRepo) List(ctx context.Context, filter *audit.Filter) ([]*audit.Event, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = "+arg(string(filter.Kind)))
+	}
+	if filter.Before != 0 {
+		conditions = append(conditions, "id < "+arg(filter.Before))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT id, kind, subject, actor_id, details, created FROM audit_events `+where+`
+		ORDER BY id DESC LIMIT `+arg(filter.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*audit.Event, 0)
+	for rows.Next() {
+		item := &audit.Event{}
+		var kind string
+		err = rows.Scan(&item.ID, &kind, &item.Subject, &item.ActorID, &item.Details, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		item.Kind = audit.Kind(kind)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+var _ audit.EventRepo = (*AuditRepo)(nil)
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Fanisabonu/http/pkg/carts"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// CartRepo хранит корзины в таблице cart_items.
+type CartRepo struct {
+	db *DB
+}
+
+// NewCartRepo создаёт репозиторий.
+func NewCartRepo(db *DB) *CartRepo {
+	return &CartRepo{db: db}
+}
+
+func (r *CartRepo) Lines(ctx context.Context, customerID int64) ([]*carts.Line, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT product_id, qty, price, updated FROM cart_items WHERE customer_id = $1 ORDER BY product_id
+	`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*carts.Line, 0)
+	for rows.Next() {
+		item := &carts.Line{}
+		err = rows.Scan(&item.ProductID, &item.Qty, &item.Price, &item.Updated)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *CartRepo) SetLine(ctx context.Context, customerID int64, line *carts.Line) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO cart_items (customer_id, product_id, qty, price) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (customer_id, product_id) DO UPDATE
+		SET qty = excluded.qty, price = excluded.price, updated = CURRENT_TIMESTAMP
+		RETURNING updated
+	`, customerID, line.ProductID, line.Qty, line.Price).Scan(&line.Updated)
+}
+
+func (r *CartRepo) RemoveLine(ctx context.Context, customerID int64, productID int64) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		DELETE FROM cart_items WHERE customer_id = $1 AND product_id = $2
+	`, customerID, productID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *CartRepo) Clear(ctx context.Context, customerID int64) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM cart_items WHERE customer_id = $1`, customerID)
+	return err
+}
+
+var _ carts.CartRepo = (*CartRepo)(nil)
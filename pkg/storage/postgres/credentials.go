@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// CredentialRepo ищет учётные данные в customers и users.
+type CredentialRepo struct {
+	db *DB
+}
+
+// NewCredentialRepo создаёт репозиторий.
+func NewCredentialRepo(db *DB) *CredentialRepo {
+	return &CredentialRepo{db: db}
+}
+
+func (r *CredentialRepo) CredentialsByPhone(ctx context.Context, kind auth.Kind, phone string) (*auth.Credentials, error) {
+	return r.find(ctx, kind, "phone", phone)
+}
+
+func (r *CredentialRepo) CredentialsByID(ctx context.Context, kind auth.Kind, id int64) (*auth.Credentials, error) {
+	return r.find(ctx, kind, "id", id)
+}
+
+func (r *CredentialRepo) find(ctx context.Context, kind auth.Kind, column string, value interface{}) (*auth.Credentials, error) {
+	sql := `SELECT id, password, active FROM customers WHERE ` + column + ` = $1`
+	if kind == auth.KindManager {
+		sql = `SELECT id, coalesce(password, ''), active FROM users WHERE ` + column + ` = $1`
+	}
+
+	item := &auth.Credentials{}
+	err := r.db.conn(ctx).QueryRow(ctx, sql, value).Scan(&item.ID, &item.PasswordHash, &item.Active)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return item, nil
+}
+
+func (r *CredentialRepo) SetPassword(ctx context.Context, kind auth.Kind, id int64, hash string) error {
+	sql := `UPDATE customers SET password = $2 WHERE id = $1`
+	if kind == auth.KindManager {
+		sql = `UPDATE users SET password = $2, invite_hash = NULL, invite_expires = NULL WHERE id = $1`
+	}
+
+	tag, err := r.db.conn(ctx).Exec(ctx, sql, id, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+var _ auth.CredentialRepo = (*CredentialRepo)(nil)
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/customers"
+	"github.com/Fanisabonu/http/pkg/storage"
+	"github.com/jackc/pgx/v4"
+)
+
+// CustomerRepo хранит покупателей в таблице customers.
+type CustomerRepo struct {
+	db *DB
+}
+
+// NewCustomerRepo создаёт репозиторий.
+func NewCustomerRepo(db *DB) *CustomerRepo {
+	return &CustomerRepo{db: db}
+}
+
+const customerColumns = `id, name, phone, active, created, phone_verified IS NOT NULL`
+
+func scanCustomer(row interface {
+	Scan(dest ...interface{}) error
+}) (*customers.Customer, error) {
+	item := &customers.Customer{}
+	err := row.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created, &item.PhoneVerified)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *CustomerRepo) list(ctx context.Context, sql string, args ...interface{}) ([]*customers.Customer, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*customers.Customer, 0)
+	for rows.Next() {
+		item, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *CustomerRepo) All(ctx context.Context) ([]*customers.Customer, error) {
+	return r.list(ctx, `SELECT `+customerColumns+` FROM customers ORDER BY id`)
+}
+
+func (r *CustomerRepo) AllActive(ctx context.Context) ([]*customers.Customer, error) {
+	return r.list(ctx, `SELECT `+customerColumns+` FROM customers WHERE active ORDER BY id`)
+}
+
+func (r *CustomerRepo) ByID(ctx context.Context, id int64) (*customers.Customer, error) {
+	item, err := scanCustomer(r.db.conn(ctx).QueryRow(ctx, `
+		SELECT `+customerColumns+` FROM customers WHERE id = $1
+	`, id))
+	return item, notFound(err)
+}
+
+func (r *CustomerRepo) Create(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	result, err := scanCustomer(r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO customers (name, phone, password) VALUES ($1, $2, $3)
+		ON CONFLICT (phone) DO NOTHING RETURNING `+customerColumns+`
+	`, item.Name, item.Phone, passwordHash))
+	// при конфликте DO NOTHING не возвращает строк
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrConflict
+	}
+	return result, err
+}
+
+func (r *CustomerRepo) Upsert(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	return scanCustomer(r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO customers (name, phone, password) VALUES ($1, $2, $3)
+		ON CONFLICT (phone) DO UPDATE SET name = excluded.name RETURNING `+customerColumns+`
+	`, item.Name, item.Phone, passwordHash))
+}
+
+func (r *CustomerRepo) Update(ctx context.Context, item *customers.Customer, passwordHash string) (*customers.Customer, error) {
+	result, err := scanCustomer(r.db.conn(ctx).QueryRow(ctx, `
+		UPDATE customers SET name = $2, phone = $3, password = coalesce(nullif($4, ''), password),
+			phone_verified  = CASE WHEN phone = $3 THEN phone_verified END,
+			verify_code     = CASE WHEN phone = $3 THEN verify_code END,
+			verify_attempts = CASE WHEN phone = $3 THEN verify_attempts ELSE 0 END,
+			verify_expires  = CASE WHEN phone = $3 THEN verify_expires END
+		WHERE id = $1 RETURNING `+customerColumns+`
+	`, item.ID, item.Name, item.Phone, passwordHash))
+	return result, conflict(notFound(err))
+}
+
+func (r *CustomerRepo) Remove(ctx context.Context, id int64) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM customers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *CustomerRepo) SetActive(ctx context.Context, id int64, active bool) error {
+	return r.exec(ctx, `UPDATE customers SET active = $2 WHERE id = $1`, id, active)
+}
+
+// SetVerification меняет код, только если прошло cooldown с отправки
+// предыдущего; иначе тем же запросом возвращает, сколько осталось ждать.
+func (r *CustomerRepo) SetVerification(ctx context.Context, id int64, hash string, ttl time.Duration, cooldown time.Duration) (time.Duration, error) {
+	var updated bool
+	var seconds float64
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		WITH updated AS (
+			UPDATE customers SET verify_code = $2, verify_attempts = 0, verify_expires = CURRENT_TIMESTAMP + $3::INTERVAL,
+				verify_sent = CURRENT_TIMESTAMP
+			WHERE id = $1 AND (verify_sent IS NULL OR verify_sent <= CURRENT_TIMESTAMP - $4::INTERVAL)
+			RETURNING id
+		)
+		SELECT EXISTS (SELECT 1 FROM updated),
+			coalesce(extract(EPOCH FROM verify_sent + $4::INTERVAL - CURRENT_TIMESTAMP), 0)::FLOAT8
+		FROM customers WHERE id = $1
+	`, id, hash, ttl, cooldown).Scan(&updated, &seconds)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if updated {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (r *CustomerRepo) Verification(ctx context.Context, id int64) (*customers.Verification, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+
+	item := &customers.Verification{}
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT verify_code, verify_attempts, verify_expires <= CURRENT_TIMESTAMP
+		FROM customers WHERE id = $1 AND verify_code IS NOT NULL
+	`+lock, id).Scan(&item.Hash, &item.Attempts, &item.Expired)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return item, nil
+}
+
+func (r *CustomerRepo) FailVerification(ctx context.Context, id int64) error {
+	return r.exec(ctx, `
+		UPDATE customers SET verify_attempts = verify_attempts + 1 WHERE id = $1 AND verify_code IS NOT NULL
+	`, id)
+}
+
+func (r *CustomerRepo) ClearVerification(ctx context.Context, id int64) error {
+	return r.exec(ctx, `
+		UPDATE customers SET verify_code = NULL, verify_attempts = 0, verify_expires = NULL WHERE id = $1
+	`, id)
+}
+
+func (r *CustomerRepo) MarkVerified(ctx context.Context, id int64) error {
+	return r.exec(ctx, `
+		UPDATE customers SET phone_verified = CURRENT_TIMESTAMP,
+			verify_code = NULL, verify_attempts = 0, verify_expires = NULL
+		WHERE id = $1
+	`, id)
+}
+
+func (r *CustomerRepo) exec(ctx context.Context, sql string, args ...interface{}) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+var _ customers.CustomerRepo = (*CustomerRepo)(nil)
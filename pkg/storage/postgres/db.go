@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/Fanisabonu/http/pkg/storage"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// uniqueViolation - код ошибки Postgres при нарушении уникальности.
+const uniqueViolation = "23505"
+
+// querier - общее у *pgxpool.Pool и pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type txKey struct{}
+
+// DB - пул соединений, общий для всех репозиториев. Реализует storage.Transactor:
+// транзакция передаётся репозиториям через контекст.
+type DB struct {
+	pool *pgxpool.Pool
+}
+
+// NewDB создаёт DB.
+func NewDB(pool *pgxpool.Pool) *DB {
+	return &DB{pool: pool}
+}
+
+// InTx выполняет fn в транзакции. Если ctx уже несёт транзакцию,
+// fn выполняется в ней. Если fn паникует, транзакция откатывается,
+// а паника продолжается.
+func (db *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				log.Print(rerr)
+			}
+			panic(p)
+		}
+		if err != nil {
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				log.Print(rerr)
+			}
+			return
+		}
+		err = tx.Commit(ctx)
+	}()
+
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+// conn возвращает транзакцию из ctx или пул.
+func (db *DB) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db.pool
+}
+
+// inTx сообщает, выполняется ли запрос в транзакции.
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return ok
+}
+
+// notFound заменяет pgx.ErrNoRows на storage.ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// conflict заменяет нарушение уникальности на storage.ErrConflict.
+func conflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return storage.ErrConflict
+	}
+	return err
+}
+
+var _ storage.Transactor = (*DB)(nil)
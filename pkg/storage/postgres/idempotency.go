@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/idempotency"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// IdempotencyRepo хранит ключи идемпотентности в таблице idempotency_keys.
+type IdempotencyRepo struct {
+	db *DB
+}
+
+// NewIdempotencyRepo создаёт репозиторий.
+func NewIdempotencyRepo(db *DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+// Reserve заменяет существующую строку, только если она просрочена;
+// иначе ни одна строка не меняется и возвращается storage.ErrConflict.
+func (r *IdempotencyRepo) Reserve(ctx context.Context, item *idempotency.Record) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, expires)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4::INTERVAL)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint, status = NULL, content_type = NULL, body = NULL,
+			created = CURRENT_TIMESTAMP, expires = EXCLUDED.expires
+		WHERE idempotency_keys.expires <= CURRENT_TIMESTAMP
+	`, item.Scope, item.Key, item.Fingerprint, item.TTL)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrConflict
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) ByKey(ctx context.Context, scope string, key string) (*idempotency.Record, error) {
+	item := &idempotency.Record{Scope: scope, Key: key}
+	var status *int
+	var contentType *string
+	var body []byte
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT fingerprint, status, content_type, body FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND expires > CURRENT_TIMESTAMP
+	`, scope, key).Scan(&item.Fingerprint, &status, &contentType, &body)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if status != nil {
+		item.Response = &idempotency.Response{Status: *status, Body: body}
+		if contentType != nil {
+			item.Response.ContentType = *contentType
+		}
+	}
+	return item, nil
+}
+
+func (r *IdempotencyRepo) Complete(ctx context.Context, scope string, key string, response *idempotency.Response, ttl time.Duration) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5, expires = CURRENT_TIMESTAMP + $6::INTERVAL
+		WHERE scope = $1 AND key = $2
+	`, scope, key, response.Status, response.ContentType, response.Body, ttl)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) Remove(ctx context.Context, scope string, key string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE expires <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+var _ idempotency.KeyRepo = (*IdempotencyRepo)(nil)
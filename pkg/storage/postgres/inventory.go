@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Fanisabonu/http/pkg/inventory"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// InventoryRepo хранит журнал в таблице inventory_movements, а остатки - в products.qty.
+type InventoryRepo struct {
+	db *DB
+}
+
+// NewInventoryRepo создаёт репозиторий.
+func NewInventoryRepo(db *DB) *InventoryRepo {
+	return &InventoryRepo{db: db}
+}
+
+const movementColumns = `id, product_id, kind, delta, qty_after, reason, coalesce(actor_kind, ''), actor_id, sale_id, purchase_id, created`
+
+func scanMovement(row interface{ Scan(dest ...interface{}) error }) (*inventory.Movement, error) {
+	item := &inventory.Movement{}
+	err := row.Scan(&item.ID, &item.ProductID, &item.Kind, &item.Delta, &item.QtyAfter, &item.Reason,
+		&item.ActorKind, &item.ActorID, &item.SaleID, &item.PurchaseID, &item.Created)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// LockQty внутри транзакции блокирует строку товара до её конца.
+func (r *InventoryRepo) LockQty(ctx context.Context, productID int64) (int, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	var qty int
+	err := r.db.conn(ctx).QueryRow(ctx, `SELECT qty FROM products WHERE id = $1 `+lock, productID).Scan(&qty)
+	return qty, notFound(err)
+}
+
+func (r *InventoryRepo) SetQty(ctx context.Context, productID int64, qty int) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `UPDATE products SET qty = $2 WHERE id = $1`, productID, qty)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *InventoryRepo) Create(ctx context.Context, item *inventory.Movement) error {
+	var actorKind *string
+	if item.ActorKind != "" {
+		value := string(item.ActorKind)
+		actorKind = &value
+	}
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO inventory_movements (product_id, kind, delta, qty_after, reason, actor_kind, actor_id, sale_id, purchase_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created
+	`, item.ProductID, string(item.Kind), item.Delta, item.QtyAfter, item.Reason, actorKind, item.ActorID, item.SaleID, item.PurchaseID,
+	).Scan(&item.ID, &item.Created)
+}
+
+func (r *InventoryRepo) ByProduct(ctx context.Context, productID int64, before int64, limit int) ([]*inventory.Movement, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT `+movementColumns+` FROM inventory_movements
+		WHERE product_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC LIMIT $3
+	`, productID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*inventory.Movement, 0)
+	for rows.Next() {
+		item, err := scanMovement(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+var _ inventory.MovementRepo = (*InventoryRepo)(nil)
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ManagerRepo хранит менеджеров в таблице users, их роли - в таблице roles.
+type ManagerRepo struct {
+	db *DB
+}
+
+// NewManagerRepo создаёт репозиторий.
+func NewManagerRepo(db *DB) *ManagerRepo {
+	return &ManagerRepo{db: db}
+}
+
+func (r *ManagerRepo) Create(ctx context.Context, item *managers.Manager, passwordHash string) error {
+	var password *string
+	if passwordHash != "" {
+		password = &passwordHash
+	}
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO users (name, phone, password, roles, plan, salary, department, boss_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created
+	`, item.Name, item.Phone, password, item.Roles, item.Plan, item.Salary, item.Department, item.BossID).Scan(&item.ID, &item.Created)
+	return conflict(err)
+}
+
+const managerColumns = `id, name, phone, roles, plan, salary, department, boss_id, active, created`
+
+func scanManager(row interface{ Scan(dest ...interface{}) error }) (*managers.Manager, error) {
+	item := &managers.Manager{}
+	err := row.Scan(&item.ID, &item.Name, &item.Phone, &item.Roles, &item.Plan, &item.Salary, &item.Department, &item.BossID, &item.Active, &item.Created)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *ManagerRepo) ByID(ctx context.Context, id int64) (*managers.Manager, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	item, err := scanManager(r.db.conn(ctx).QueryRow(ctx, `
+		SELECT `+managerColumns+` FROM users WHERE id = $1 `+lock, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return item, nil
+}
+
+func (r *ManagerRepo) List(ctx context.Context) ([]*managers.Manager, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `SELECT `+managerColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Manager, 0)
+	for rows.Next() {
+		item, err := scanManager(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ManagerRepo) Update(ctx context.Context, item *managers.Manager) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		UPDATE users SET name = $2, phone = $3, roles = $4, plan = $5, salary = $6, department = $7, boss_id = $8
+		WHERE id = $1
+	`, item.ID, item.Name, item.Phone, item.Roles, item.Plan, item.Salary, item.Department, item.BossID)
+	if err != nil {
+		return conflict(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ManagerRepo) Deactivate(ctx context.Context, id int64) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `UPDATE users SET active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ManagerRepo) ReassignSubordinates(ctx context.Context, fromID int64, toID *int64) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `UPDATE users SET boss_id = $2 WHERE boss_id = $1`, fromID, toID)
+	return err
+}
+
+func (r *ManagerRepo) Subordinates(ctx context.Context, id int64) ([]*managers.TeamMember, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		WITH RECURSIVE team AS (
+			SELECT id, 1 AS level FROM users WHERE boss_id = $1
+			UNION
+			SELECT u.id, t.level + 1 FROM users u JOIN team t ON u.boss_id = t.id
+		)
+		SELECT `+managerColumns+`, level
+		FROM (SELECT id, min(level) AS level FROM team GROUP BY id) t
+		JOIN users USING (id)
+		ORDER BY level, id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.TeamMember, 0)
+	for rows.Next() {
+		item := &managers.TeamMember{}
+		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Roles, &item.Plan, &item.Salary, &item.Department, &item.BossID, &item.Active, &item.Created, &item.Level)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ManagerRepo) SetInvite(ctx context.Context, id int64, hash string, ttl time.Duration) (time.Time, error) {
+	var expires time.Time
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		UPDATE users SET invite_hash = $2, invite_expires = CURRENT_TIMESTAMP + $3::INTERVAL
+		WHERE id = $1 RETURNING invite_expires
+	`, id, hash, ttl).Scan(&expires)
+	return expires, notFound(err)
+}
+
+func (r *ManagerRepo) ByInvite(ctx context.Context, hash string) (int64, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	var id int64
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT id FROM users WHERE invite_hash = $1 AND invite_expires > CURRENT_TIMESTAMP AND active `+lock, hash).Scan(&id)
+	return id, notFound(err)
+}
+
+func (r *ManagerRepo) SetPassword(ctx context.Context, id int64, hash string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		UPDATE users SET password = $2, invite_hash = NULL, invite_expires = NULL WHERE id = $1
+	`, id, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ManagerRepo) Permissions(ctx context.Context, id int64) ([]string, error) {
+	permissions := make([]string, 0)
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT coalesce(array_agg(DISTINCT p.permission), '{}')
+		FROM users u
+		JOIN roles r ON r.name = ANY(u.roles)
+		CROSS JOIN unnest(r.permissions) AS p(permission)
+		WHERE u.id = $1 AND u.active
+	`, id).Scan(&permissions)
+	return permissions, err
+}
+
+func (r *ManagerRepo) RolesExist(ctx context.Context, roles []string) (bool, error) {
+	var unknown int
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT count(*) FROM unnest($1::TEXT[]) AS r(name)
+		WHERE NOT EXISTS (SELECT 1 FROM roles WHERE roles.name = r.name)
+	`, roles).Scan(&unknown)
+	return unknown == 0, err
+}
+
+var _ managers.ManagerRepo = (*ManagerRepo)(nil)
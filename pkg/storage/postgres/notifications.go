@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/notifications"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// OutboxRepo хранит уведомления в таблице notifications.
+type OutboxRepo struct {
+	db *DB
+}
+
+// NewOutboxRepo создаёт репозиторий.
+func NewOutboxRepo(db *DB) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+func (r *OutboxRepo) Add(ctx context.Context, item *notifications.Notification) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO notifications (event, recipient_kind, recipient_id, text)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, next_attempt, created
+	`, string(item.Event), string(item.Recipient), item.RecipientID, item.Text).Scan(
+		&item.ID, &item.Status, &item.NextAttempt, &item.Created)
+}
+
+func (r *OutboxRepo) Due(ctx context.Context, limit int) ([]*notifications.Pending, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE OF n SKIP LOCKED"
+	}
+
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT n.id, n.event, n.recipient_kind, n.recipient_id, n.text, n.status, n.attempts,
+			n.next_attempt, coalesce(n.last_error, ''), n.created, n.sent, coalesce(c.phone, u.phone, '')
+		FROM notifications n
+		LEFT JOIN customers c ON n.recipient_kind = 'customer' AND c.id = n.recipient_id
+		LEFT JOIN users u ON n.recipient_kind = 'manager' AND u.id = n.recipient_id
+		WHERE n.status = 'pending' AND n.next_attempt <= CURRENT_TIMESTAMP
+		ORDER BY n.id
+		LIMIT $1
+	`+lock, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*notifications.Pending, 0)
+	for rows.Next() {
+		item := &notifications.Pending{}
+		var event, recipient string
+		err = rows.Scan(&item.ID, &event, &recipient, &item.RecipientID, &item.Text, &item.Status, &item.Attempts,
+			&item.NextAttempt, &item.LastError, &item.Created, &item.Sent, &item.Phone)
+		if err != nil {
+			return nil, err
+		}
+		item.Event, item.Recipient = notifications.EventKind(event), notifications.Recipient(recipient)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *OutboxRepo) MarkSent(ctx context.Context, id int64) error {
+	return r.exec(ctx, `
+		UPDATE notifications SET status = 'sent', attempts = attempts + 1, sent = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id)
+}
+
+func (r *OutboxRepo) Retry(ctx context.Context, id int64, lastError string, delay time.Duration) error {
+	return r.exec(ctx, `
+		UPDATE notifications
+		SET attempts = attempts + 1, last_error = $2, next_attempt = CURRENT_TIMESTAMP + $3::INTERVAL
+		WHERE id = $1
+	`, id, lastError, delay)
+}
+
+func (r *OutboxRepo) Fail(ctx context.Context, id int64, lastError string) error {
+	return r.exec(ctx, `
+		UPDATE notifications SET status = 'failed', attempts = attempts + 1, last_error = $2 WHERE id = $1
+	`, id, lastError)
+}
+
+func (r *OutboxRepo) exec(ctx context.Context, sql string, args ...interface{}) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+var _ notifications.OutboxRepo = (*OutboxRepo)(nil)
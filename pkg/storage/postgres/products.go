@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/products"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// ProductRepo хранит товары в таблице products.
+type ProductRepo struct {
+	db *DB
+}
+
+// NewProductRepo создаёт репозиторий.
+func NewProductRepo(db *DB) *ProductRepo {
+	return &ProductRepo{db: db}
+}
+
+const productColumns = `id, name, price, qty, active`
+
+func scanProduct(row interface{ Scan(dest ...interface{}) error }) (*products.Product, error) {
+	item := &products.Product{}
+	err := row.Scan(&item.ID, &item.Name, &item.Price, &item.Qty, &item.Active)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// productSortColumns - колонки, по которым разрешена сортировка.
+var productSortColumns = map[string]string{
+	"id":    "id",
+	"name":  "name",
+	"price": "price",
+}
+
+// List строит запрос с keyset-пагинацией: следующая страница начинается
+// строго после (поле сортировки, id) последнего товара предыдущей.
+func (r *ProductRepo) List(ctx context.Context, filter *products.Filter) ([]*products.Product, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if !filter.IncludeInactive {
+		conditions = append(conditions, "active")
+	}
+	if filter.Query != "" {
+		conditions = append(conditions, "name ILIKE "+arg("%"+escapeLike(filter.Query)+"%"))
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, "price >= "+arg(*filter.MinPrice))
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, "price <= "+arg(*filter.MaxPrice))
+	}
+
+	column, ok := productSortColumns[filter.Sort.Field()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+	direction, compare := "ASC", ">"
+	if filter.Sort.Desc() {
+		direction, compare = "DESC", "<"
+	}
+	if filter.After != nil {
+		switch column {
+		case "id":
+			conditions = append(conditions, "id "+compare+" "+arg(filter.After.ID))
+		case "name":
+			conditions = append(conditions, "(name, id) "+compare+" ("+arg(filter.After.Name)+", "+arg(filter.After.ID)+")")
+		case "price":
+			conditions = append(conditions, "(price, id) "+compare+" ("+arg(filter.After.Price)+", "+arg(filter.After.ID)+")")
+		}
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	order := column + " " + direction
+	if column != "id" {
+		order += ", id " + direction
+	}
+
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT `+productColumns+` FROM products `+where+`
+		ORDER BY `+order+` LIMIT `+arg(filter.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*products.Product, 0)
+	for rows.Next() {
+		item, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ByID внутри транзакции блокирует строку до её конца.
+func (r *ProductRepo) ByID(ctx context.Context, id int64) (*products.Product, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	item, err := scanProduct(r.db.conn(ctx).QueryRow(ctx, `
+		SELECT `+productColumns+` FROM products WHERE id = $1 `+lock, id))
+	return item, notFound(err)
+}
+
+func (r *ProductRepo) Create(ctx context.Context, item *products.Product) (*products.Product, error) {
+	return scanProduct(r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO products (name, price, qty, active) VALUES ($1, $2, $3, $4) RETURNING `+productColumns,
+		item.Name, item.Price, item.Qty, item.Active))
+}
+
+// Update не меняет qty: остаток ведёт журнал движений.
+func (r *ProductRepo) Update(ctx context.Context, item *products.Product) (*products.Product, error) {
+	result, err := scanProduct(r.db.conn(ctx).QueryRow(ctx, `
+		UPDATE products SET name = $2, price = $3, active = $4 WHERE id = $1 RETURNING `+productColumns,
+		item.ID, item.Name, item.Price, item.Active))
+	return result, notFound(err)
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы поиск шёл по подстроке как есть.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// LockStock блокирует строки в порядке id, чтобы параллельные продажи не ловили deadlock.
+func (r *ProductRepo) LockStock(ctx context.Context, ids []int64) ([]*sales.Stock, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT id, qty, active FROM products WHERE id = ANY($1) ORDER BY id `+lock, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*sales.Stock, 0, len(ids))
+	for rows.Next() {
+		item := &sales.Stock{}
+		err = rows.Scan(&item.ProductID, &item.Qty, &item.Active)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+var (
+	_ products.ProductRepo = (*ProductRepo)(nil)
+	_ sales.StockRepo      = (*ProductRepo)(nil)
+)
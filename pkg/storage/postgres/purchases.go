@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Fanisabonu/http/pkg/purchases"
+)
+
+// PurchaseRepo хранит покупки в таблицах purchases и purchase_items.
+type PurchaseRepo struct {
+	db *DB
+}
+
+// NewPurchaseRepo создаёт репозиторий.
+func NewPurchaseRepo(db *DB) *PurchaseRepo {
+	return &PurchaseRepo{db: db}
+}
+
+func (r *PurchaseRepo) Create(ctx context.Context, item *purchases.Purchase) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO purchases (customer_id) VALUES ($1) RETURNING id, created
+	`, item.CustomerID).Scan(&item.ID, &item.Created)
+}
+
+func (r *PurchaseRepo) AddItem(ctx context.Context, item *purchases.Item) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO purchase_items (purchase_id, product_id, name, qty, price) VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`, item.PurchaseID, item.ProductID, item.Name, item.Qty, item.Price).Scan(&item.ID)
+}
+
+func (r *PurchaseRepo) ByCustomer(ctx context.Context, customerID int64) ([]*purchases.Purchase, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT p.id, p.created, i.id, i.product_id, i.name, i.qty, i.price
+		FROM purchases p
+		JOIN purchase_items i ON i.purchase_id = p.id
+		WHERE p.customer_id = $1
+		ORDER BY p.id, i.id
+	`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*purchases.Purchase, 0)
+	var current *purchases.Purchase
+	for rows.Next() {
+		purchase := &purchases.Purchase{CustomerID: customerID}
+		item := &purchases.Item{}
+		err = rows.Scan(&purchase.ID, &purchase.Created, &item.ID, &item.ProductID, &item.Name, &item.Qty, &item.Price)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != purchase.ID {
+			current = purchase
+			items = append(items, current)
+		}
+		item.PurchaseID = current.ID
+		current.Items = append(current.Items, item)
+	}
+	return items, rows.Err()
+}
+
+var _ purchases.PurchaseRepo = (*PurchaseRepo)(nil)
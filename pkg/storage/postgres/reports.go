@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/managers"
+	"github.com/Fanisabonu/http/pkg/sales"
+)
+
+// ReportRepo считает отчёты по таблицам sales и sale_positions за вычетом
+// возвратов. Отменённые продажи не учитываются.
+type ReportRepo struct {
+	db *DB
+}
+
+// NewReportRepo создаёт репозиторий.
+func NewReportRepo(db *DB) *ReportRepo {
+	return &ReportRepo{db: db}
+}
+
+// saleLines - позиции неотменённых продаж и возвраты по ним с обратным знаком:
+// возврат уменьшает количество и сумму той продажи, к которой относится.
+const saleLines = `
+	WITH lines AS (
+		SELECT s.id AS sale_id, s.manager_id, s.customer_id, s.created, sp.product_id,
+			sp.qty AS qty, sp.qty::BIGINT * sp.price AS amount
+		FROM sales s
+		JOIN sale_positions sp ON sp.sale_id = s.id
+		WHERE s.cancelled IS NULL
+		UNION ALL
+		SELECT s.id, s.manager_id, s.customer_id, s.created, sp.product_id, -ri.qty, -ri.refund
+		FROM return_items ri
+		JOIN sale_positions sp ON sp.id = ri.sale_position_id
+		JOIN sales s ON s.id = sp.sale_id
+		WHERE s.cancelled IS NULL
+	)`
+
+func (r *ReportRepo) Summary(ctx context.Context, filter *sales.ReportFilter) (*sales.Bucket, error) {
+	item := &sales.Bucket{}
+	err := r.db.conn(ctx).QueryRow(ctx, saleLines+`
+		SELECT count(DISTINCT l.sale_id), coalesce(sum(l.qty), 0), coalesce(sum(l.amount), 0)::BIGINT
+		FROM lines l
+		WHERE l.manager_id = $1 AND l.created >= $2 AND l.created < $3
+	`, filter.ManagerID, filter.From, filter.To).Scan(&item.Sales, &item.Qty, &item.Total)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Breakdown подставляет в запрос только выражения из switch, значения фильтра
+// передаются параметрами.
+func (r *ReportRepo) Breakdown(ctx context.Context, filter *sales.ReportFilter) ([]*sales.Bucket, error) {
+	key, name, join, order := "", "''", "", "total DESC, key"
+	switch filter.GroupBy {
+	case sales.GroupDay, sales.GroupWeek, sales.GroupMonth:
+		key = "to_char(date_trunc('" + filter.GroupBy.Period() + "', l.created), 'YYYY-MM-DD')"
+		order = "key"
+	case sales.GroupProduct:
+		key, name, join = "l.product_id::TEXT", "p.name", "JOIN products p ON p.id = l.product_id"
+	case sales.GroupCustomer:
+		key, name, join = "l.customer_id::TEXT", "coalesce(c.name, '')", "LEFT JOIN customers c ON c.id = l.customer_id"
+	default:
+		return nil, fmt.Errorf("unsupported grouping %q", filter.GroupBy)
+	}
+
+	rows, err := r.db.conn(ctx).Query(ctx, saleLines+`
+		SELECT `+key+` AS key, `+name+` AS name,
+			count(DISTINCT l.sale_id), sum(l.qty), sum(l.amount)::BIGINT AS total
+		FROM lines l
+		`+join+`
+		WHERE l.manager_id = $1 AND l.created >= $2 AND l.created < $3
+		GROUP BY 1, 2
+		ORDER BY `+order, filter.ManagerID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*sales.Bucket, 0)
+	for rows.Next() {
+		item := &sales.Bucket{}
+		err = rows.Scan(&item.Key, &item.Name, &item.Sales, &item.Qty, &item.Total)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ReportRepo) Leaderboard(ctx context.Context, from time.Time, to time.Time) ([]*sales.Standing, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, saleLines+`
+		SELECT u.id, u.name, u.plan, count(DISTINCT l.sale_id), coalesce(sum(l.amount), 0)::BIGINT AS total
+		FROM users u
+		LEFT JOIN lines l ON l.manager_id = u.id AND l.created >= $1 AND l.created < $2
+		WHERE u.active AND $3 = ANY(u.roles)
+		GROUP BY u.id
+		ORDER BY total DESC, u.id
+	`, from, to, managers.RoleManager)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*sales.Standing, 0)
+	for rows.Next() {
+		item := &sales.Standing{}
+		err = rows.Scan(&item.ManagerID, &item.Name, &item.MonthlyPlan, &item.Sales, &item.Total)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ReportRepo) Plan(ctx context.Context, managerID int64) (int64, error) {
+	var plan int64
+	err := r.db.conn(ctx).QueryRow(ctx, `SELECT plan FROM users WHERE id = $1`, managerID).Scan(&plan)
+	return plan, notFound(err)
+}
+
+var _ sales.ReportRepo = (*ReportRepo)(nil)
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// ResetRepo хранит коды сброса пароля в таблице password_resets.
+type ResetRepo struct {
+	db *DB
+}
+
+// NewResetRepo создаёт репозиторий.
+func NewResetRepo(db *DB) *ResetRepo {
+	return &ResetRepo{db: db}
+}
+
+func (r *ResetRepo) Save(ctx context.Context, kind auth.Kind, ownerID int64, hash string, ttl time.Duration) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `
+		INSERT INTO password_resets (kind, owner_id, code, expires)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4::INTERVAL)
+		ON CONFLICT (kind, owner_id) DO UPDATE
+		SET code = excluded.code, attempts = 0, created = CURRENT_TIMESTAMP, expires = excluded.expires
+	`, string(kind), ownerID, hash, ttl)
+	return err
+}
+
+func (r *ResetRepo) ByOwner(ctx context.Context, kind auth.Kind, ownerID int64) (*auth.ResetCode, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+
+	item := &auth.ResetCode{Kind: kind, OwnerID: ownerID}
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT code, attempts, expires <= CURRENT_TIMESTAMP
+		FROM password_resets WHERE kind = $1 AND owner_id = $2
+	`+lock, string(kind), ownerID).Scan(&item.Hash, &item.Attempts, &item.Expired)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return item, nil
+}
+
+func (r *ResetRepo) Fail(ctx context.Context, kind auth.Kind, ownerID int64) error {
+	return r.exec(ctx, `
+		UPDATE password_resets SET attempts = attempts + 1 WHERE kind = $1 AND owner_id = $2
+	`, kind, ownerID)
+}
+
+func (r *ResetRepo) Remove(ctx context.Context, kind auth.Kind, ownerID int64) error {
+	return r.exec(ctx, `DELETE FROM password_resets WHERE kind = $1 AND owner_id = $2`, kind, ownerID)
+}
+
+func (r *ResetRepo) exec(ctx context.Context, sql string, kind auth.Kind, ownerID int64) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, sql, string(kind), ownerID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ResetRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM password_resets WHERE expires <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+var _ auth.ResetRepo = (*ResetRepo)(nil)
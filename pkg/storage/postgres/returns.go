@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/returns"
+)
+
+// ReturnRepo хранит возвраты в таблицах returns и return_items.
+type ReturnRepo struct {
+	db *DB
+}
+
+// NewReturnRepo создаёт репозиторий.
+func NewReturnRepo(db *DB) *ReturnRepo {
+	return &ReturnRepo{db: db}
+}
+
+func (r *ReturnRepo) Sale(ctx context.Context, id int64) (*returns.Origin, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	origin := &returns.Origin{}
+	err := r.db.conn(ctx).QueryRow(ctx, `
+		SELECT cancelled IS NOT NULL FROM sales WHERE id = $1 `+lock, id).Scan(&origin.Cancelled)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	origin.Lines, err = r.lines(ctx, `
+		SELECT sp.id, sp.product_id, sp.qty, sp.price,
+			coalesce((SELECT sum(ri.qty) FROM return_items ri WHERE ri.sale_position_id = sp.id), 0)
+		FROM sale_positions sp WHERE sp.sale_id = $1 ORDER BY sp.id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return origin, nil
+}
+
+func (r *ReturnRepo) Purchase(ctx context.Context, id int64) (*returns.Origin, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	var found int64
+	err := r.db.conn(ctx).QueryRow(ctx, `SELECT id FROM purchases WHERE id = $1 `+lock, id).Scan(&found)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	lines, err := r.lines(ctx, `
+		SELECT pi.id, pi.product_id, pi.qty, pi.price,
+			coalesce((SELECT sum(ri.qty) FROM return_items ri WHERE ri.purchase_item_id = pi.id), 0)
+		FROM purchase_items pi WHERE pi.purchase_id = $1 ORDER BY pi.id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &returns.Origin{Lines: lines}, nil
+}
+
+func (r *ReturnRepo) lines(ctx context.Context, query string, id int64) ([]*returns.OriginLine, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*returns.OriginLine, 0)
+	for rows.Next() {
+		item := &returns.OriginLine{}
+		err = rows.Scan(&item.ID, &item.ProductID, &item.Qty, &item.Price, &item.Returned)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ReturnRepo) Create(ctx context.Context, item *returns.Return) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO returns (manager_id, sale_id, purchase_id, reason, refund) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created
+	`, item.ManagerID, item.SaleID, item.PurchaseID, item.Reason, item.Refund).Scan(&item.ID, &item.Created)
+}
+
+func (r *ReturnRepo) AddItem(ctx context.Context, item *returns.Item) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO return_items (return_id, sale_position_id, purchase_item_id, product_id, qty, refund)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`, item.ReturnID, item.SalePositionID, item.PurchaseItemID, item.ProductID, item.Qty, item.Refund).Scan(&item.ID)
+}
+
+const returnColumns = `id, manager_id, sale_id, purchase_id, reason, refund, created`
+
+func scanReturn(row interface{ Scan(dest ...interface{}) error }) (*returns.Return, error) {
+	item := &returns.Return{}
+	err := row.Scan(&item.ID, &item.ManagerID, &item.SaleID, &item.PurchaseID, &item.Reason, &item.Refund, &item.Created)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *ReturnRepo) ByID(ctx context.Context, id int64) (*returns.Return, error) {
+	item, err := scanReturn(r.db.conn(ctx).QueryRow(ctx, `
+		SELECT `+returnColumns+` FROM returns WHERE id = $1`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	err = r.items(ctx, []*returns.Return{item})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *ReturnRepo) List(ctx context.Context, filter *returns.Filter) ([]*returns.Return, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.SaleID != nil {
+		conditions = append(conditions, "sale_id = "+arg(*filter.SaleID))
+	}
+	if filter.PurchaseID != nil {
+		conditions = append(conditions, "purchase_id = "+arg(*filter.PurchaseID))
+	}
+	if filter.Before != 0 {
+		conditions = append(conditions, "id < "+arg(filter.Before))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT `+returnColumns+` FROM returns `+where+`
+		ORDER BY id DESC LIMIT `+arg(filter.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*returns.Return, 0)
+	for rows.Next() {
+		item, err := scanReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.items(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// items загружает позиции возвратов одним запросом.
+func (r *ReturnRepo) items(ctx context.Context, items []*returns.Return) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*returns.Return, len(items))
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		item.Items = make([]*returns.Item, 0)
+		byID[item.ID] = item
+		ids = append(ids, item.ID)
+	}
+
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT id, return_id, sale_position_id, purchase_item_id, product_id, qty, refund
+		FROM return_items WHERE return_id = ANY($1) ORDER BY id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &returns.Item{}
+		err = rows.Scan(&item.ID, &item.ReturnID, &item.SalePositionID, &item.PurchaseItemID, &item.ProductID, &item.Qty, &item.Refund)
+		if err != nil {
+			return err
+		}
+		value := byID[item.ReturnID]
+		value.Items = append(value.Items, item)
+	}
+	return rows.Err()
+}
+
+var _ returns.ReturnRepo = (*ReturnRepo)(nil)
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Fanisabonu/http/pkg/sales"
+	"github.com/Fanisabonu/http/pkg/storage"
+)
+
+// SaleRepo хранит продажи в таблицах sales и sale_positions.
+type SaleRepo struct {
+	db *DB
+}
+
+// NewSaleRepo создаёт репозиторий.
+func NewSaleRepo(db *DB) *SaleRepo {
+	return &SaleRepo{db: db}
+}
+
+func (r *SaleRepo) Create(ctx context.Context, item *sales.MakeSale) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO sales (manager_id, customer_id) VALUES ($1, $2) RETURNING id, created
+	`, item.ManagerID, item.CustomerID).Scan(&item.ID, &item.Created)
+}
+
+func (r *SaleRepo) AddPosition(ctx context.Context, item *sales.SalePosition) error {
+	return r.db.conn(ctx).QueryRow(ctx, `
+		INSERT INTO sale_positions (sale_id, product_id, qty, price) VALUES ($1, $2, $3, $4) RETURNING id
+	`, item.SaleID, item.ProductID, item.Qty, item.Price).Scan(&item.ID)
+}
+
+const saleColumns = `id, manager_id, customer_id, created, cancelled, cancelled_by, coalesce(cancel_reason, '')`
+
+func scanSale(row interface{ Scan(dest ...interface{}) error }) (*sales.MakeSale, error) {
+	item := &sales.MakeSale{}
+	err := row.Scan(&item.ID, &item.ManagerID, &item.CustomerID, &item.Created, &item.Cancelled, &item.CancelledBy, &item.CancelReason)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ByID внутри транзакции блокирует строку продажи до её конца.
+func (r *SaleRepo) ByID(ctx context.Context, id int64) (*sales.MakeSale, error) {
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE"
+	}
+	item, err := scanSale(r.db.conn(ctx).QueryRow(ctx, `
+		SELECT `+saleColumns+` FROM sales WHERE id = $1 `+lock, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	err = r.positions(ctx, []*sales.MakeSale{item})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *SaleRepo) List(ctx context.Context, filter *sales.SaleFilter) ([]*sales.MakeSale, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.ManagerID != 0 {
+		conditions = append(conditions, "manager_id = "+arg(filter.ManagerID))
+	}
+	if filter.CustomerID != nil {
+		conditions = append(conditions, "customer_id = "+arg(*filter.CustomerID))
+	}
+	if filter.ProductID != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM sale_positions sp WHERE sp.sale_id = sales.id AND sp.product_id = "+arg(*filter.ProductID)+")")
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created < "+arg(*filter.To))
+	}
+	if filter.Before != 0 {
+		conditions = append(conditions, "id < "+arg(filter.Before))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT `+saleColumns+` FROM sales `+where+`
+		ORDER BY id DESC LIMIT `+arg(filter.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*sales.MakeSale, 0)
+	for rows.Next() {
+		item, err := scanSale(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.positions(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// positions загружает позиции продаж одним запросом.
+func (r *SaleRepo) positions(ctx context.Context, items []*sales.MakeSale) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*sales.MakeSale, len(items))
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		item.Positions = make([]*sales.SalePosition, 0)
+		byID[item.ID] = item
+		ids = append(ids, item.ID)
+	}
+
+	rows, err := r.db.conn(ctx).Query(ctx, `
+		SELECT sp.id, sp.sale_id, sp.product_id, sp.qty, sp.price,
+			coalesce((SELECT sum(ri.qty) FROM return_items ri WHERE ri.sale_position_id = sp.id), 0)
+		FROM sale_positions sp WHERE sp.sale_id = ANY($1) ORDER BY sp.id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		position := &sales.SalePosition{}
+		err = rows.Scan(&position.ID, &position.SaleID, &position.ProductID, &position.Qty, &position.Price, &position.Returned)
+		if err != nil {
+			return err
+		}
+		sale := byID[position.SaleID]
+		sale.Positions = append(sale.Positions, position)
+	}
+	return rows.Err()
+}
+
+func (r *SaleRepo) Cancel(ctx context.Context, id int64, managerID int64, reason string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		UPDATE sales SET cancelled = CURRENT_TIMESTAMP, cancelled_by = $2, cancel_reason = $3 WHERE id = $1
+	`, id, managerID, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+var _ sales.SaleRepo = (*SaleRepo)(nil)
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fanisabonu/http/pkg/auth"
+)
+
+// tokenTable описывает таблицу токенов одного типа пользователей.
+type tokenTable struct {
+	table      string
+	owner      string
+	ownerTable string
+	// roles - выражение для ролей владельца (у покупателей ролей нет).
+	roles string
+}
+
+var (
+	customersTokens = tokenTable{table: "customers_tokens", owner: "customer_id", ownerTable: "customers", roles: "'{}'::TEXT[]"}
+	managersTokens  = tokenTable{table: "managers_tokens", owner: "manager_id", ownerTable: "users", roles: "o.roles"}
+)
+
+func tokenTableFor(kind auth.Kind) tokenTable {
+	if kind == auth.KindManager {
+		return managersTokens
+	}
+	return customersTokens
+}
+
+// TokenRepo хранит токены в customers_tokens и managers_tokens.
+type TokenRepo struct {
+	db *DB
+}
+
+// NewTokenRepo создаёт репозиторий.
+func NewTokenRepo(db *DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+func (r *TokenRepo) Create(ctx context.Context, item *auth.NewTokenRecord) (time.Time, error) {
+	table := tokenTableFor(item.Kind)
+	var expire time.Time
+	err := r.db.conn(ctx).QueryRow(ctx, fmt.Sprintf(`
+		INSERT INTO %s (token, %s, expire, refresh_token, refresh_expire)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + $3::INTERVAL, $4, CURRENT_TIMESTAMP + $5::INTERVAL)
+		RETURNING expire
+	`, table.table, table.owner), item.Hash, item.OwnerID, item.TTL, item.RefreshHash, item.RefreshTTL).Scan(&expire)
+	return expire, err
+}
+
+func (r *TokenRepo) ByToken(ctx context.Context, kind auth.Kind, hash string) (*auth.TokenRecord, error) {
+	return r.find(ctx, kind, "token", hash)
+}
+
+func (r *TokenRepo) ByRefreshToken(ctx context.Context, kind auth.Kind, hash string) (*auth.TokenRecord, error) {
+	return r.find(ctx, kind, "refresh_token", hash)
+}
+
+func (r *TokenRepo) find(ctx context.Context, kind auth.Kind, column string, hash string) (*auth.TokenRecord, error) {
+	table := tokenTableFor(kind)
+	lock := ""
+	if inTx(ctx) {
+		lock = "FOR UPDATE OF t"
+	}
+
+	item := &auth.TokenRecord{Kind: kind}
+	err := r.db.conn(ctx).QueryRow(ctx, fmt.Sprintf(`
+		SELECT t.id, t.%[2]s, %[4]s, t.expire, t.expire <= CURRENT_TIMESTAMP,
+			coalesce(t.refresh_expire <= CURRENT_TIMESTAMP, TRUE)
+		FROM %[1]s t
+		JOIN %[3]s o ON o.id = t.%[2]s
+		WHERE t.%[5]s = $1 AND o.active
+		%[6]s
+	`, table.table, table.owner, table.ownerTable, table.roles, column, lock), hash).Scan(
+		&item.ID, &item.OwnerID, &item.Roles, &item.Expire, &item.Expired, &item.RefreshExpired)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return item, nil
+}
+
+func (r *TokenRepo) Remove(ctx context.Context, kind auth.Kind, id int64, ownerID int64) error {
+	table := tokenTableFor(kind)
+	_, err := r.db.conn(ctx).Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE id = $1 AND %s = $2
+	`, table.table, table.owner), id, ownerID)
+	return err
+}
+
+func (r *TokenRepo) RemoveByOwner(ctx context.Context, kind auth.Kind, ownerID int64) (int64, error) {
+	table := tokenTableFor(kind)
+	tag, err := r.db.conn(ctx).Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE %s = $1
+	`, table.table, table.owner), ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *TokenRepo) RemoveExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for _, table := range []tokenTable{customersTokens, managersTokens} {
+		tag, err := r.db.conn(ctx).Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s WHERE coalesce(refresh_expire, expire) <= CURRENT_TIMESTAMP
+		`, table.table))
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+	}
+	return total, nil
+}
+
+var _ auth.TokenRepo = (*TokenRepo)(nil)
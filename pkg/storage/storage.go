@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound возвращается репозиториями, когда запись не найдена.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict возвращается, когда запись нарушает уникальность (например, телефон уже занят).
+var ErrConflict = errors.New("record already exists")
+
+// Transactor выполняет fn в транзакции. Репозитории, вызванные с переданным
+// в fn контекстом, работают в этой транзакции. Если fn вернула ошибку,
+// все изменения откатываются.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,4 @@
+-- Тестовые данные для разработки. Выполнять после `migrate up`,
+-- роли создаются миграцией 0002.
+
+INSERT INTO users (name, phone, password, roles) VALUES ('vasya', '+992000000001', '$2a$10$n.1kcMvH9iRNkapI6wObS.xmw2GHzMN/dql2gPnJHZcYwkgjhLvVW', '{"MANAGER", "ADMIN"}');